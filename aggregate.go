@@ -0,0 +1,154 @@
+package xmltree
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValueErrors collects the errors for each value that could not be
+// parsed by SumFloat or MinMax, in document order.
+type ValueErrors []*ValueError
+
+func (errs ValueErrors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = strings.TrimPrefix(err.Error(), "xmltree: ")
+	}
+	return fmt.Sprintf("xmltree: %d invalid values: %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// Count returns the number of elements matching selector, relative
+// to el. It returns an error only if the selector is invalid.
+func Count(el *Element, selector string) (int, error) {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return 0, err
+	}
+	return len(sel.MatchAll(el)), nil
+}
+
+// SumFloat returns the sum of the content of the elements matching
+// selector, relative to el, parsed as xs:double values. Content that
+// is not a number is left out of the sum, and reported in an error of
+// type ValueErrors, with the path of each element from el; the sum of
+// the other values is still returned.
+//
+//	total, err := xmltree.SumFloat(report, "//line/amount")
+func SumFloat(el *Element, selector string) (float64, error) {
+	values, err := floatValues(el, selector)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum, err
+}
+
+// MinMax returns the least and greatest of the content of the
+// elements matching selector, relative to el, parsed as in SumFloat.
+// Invalid values are reported in the same way. If there are no valid
+// values, MinMax returns an error wrapping ErrNotFound, unless there
+// were invalid ones.
+func MinMax(el *Element, selector string) (min, max float64, err error) {
+	values, err := floatValues(el, selector)
+	if len(values) == 0 {
+		if err == nil {
+			err = fmt.Errorf("xmltree: %s: %w", selector, ErrNotFound)
+		}
+		return 0, 0, err
+	}
+	min, max = values[0], values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return min, max, err
+}
+
+// floatValues parses the content of the elements matching selector.
+func floatValues(el *Element, selector string) ([]float64, error) {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var values []float64
+	var errs ValueErrors
+	for _, match := range sel.MatchAll(el) {
+		content, err := match.contentBytes()
+		if err != nil {
+			return values, err
+		}
+		s := strings.TrimSpace(string(content))
+		v, ok := parseDouble(s)
+		if !ok {
+			verr := match.valueError("double", s, nil)
+			verr.SetPath(el, match)
+			errs = append(errs, verr)
+			continue
+		}
+		values = append(values, v)
+	}
+	if errs != nil {
+		return values, errs
+	}
+	return values, nil
+}
+
+// parseDouble parses s in the lexical form of xs:double, which,
+// unlike the syntax accepted by strconv.ParseFloat, has no hex
+// digits or underscores, and spells infinity as INF.
+func parseDouble(s string) (float64, bool) {
+	switch s {
+	case "INF", "+INF":
+		return math.Inf(1), true
+	case "-INF":
+		return math.Inf(-1), true
+	case "NaN":
+		return math.NaN(), true
+	}
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		}
+		if i == start {
+			return 0, false
+		}
+	}
+	if i != len(s) {
+		return 0, false
+	}
+	// Out of range values round to infinity, as xs:double requires.
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && err.(*strconv.NumError).Err != strconv.ErrRange {
+		return 0, false
+	}
+	return v, true
+}
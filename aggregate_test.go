@@ -0,0 +1,56 @@
+package xmltree
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	root := parseDoc(t, []byte(`<r><line><amount>2.5</amount></line><line><amount> 4 </amount></line><line><amount>n/a</amount></line><line><amount>-1</amount></line><line><amount/></line></r>`))
+	if n, err := Count(root, "line"); n != 5 || err != nil {
+		t.Errorf("Count = %d, %v", n, err)
+	}
+	if _, err := Count(root, "["); err == nil {
+		t.Error("Count accepted an invalid selector")
+	}
+
+	sum, err := SumFloat(root, "//amount")
+	if sum != 5.5 {
+		t.Errorf("SumFloat = %v", sum)
+	}
+	var errs ValueErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want two ValueErrors", err)
+	}
+	if errs[0].Path != "/r/line[3]/amount" || errs[0].Value != "n/a" || errs[1].Path != "/r/line[5]/amount" {
+		t.Errorf("wrong errors: %v", err)
+	}
+
+	min, max, err := MinMax(root, "//amount")
+	if min != -1 || max != 4 || err == nil {
+		t.Errorf("MinMax = %v, %v, %v", min, max, err)
+	}
+	if _, _, err := MinMax(root, "//missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MinMax of nothing: %v", err)
+	}
+}
+
+func TestParseDouble(t *testing.T) {
+	for _, s := range []string{"1", "-1.5", "+.5", "5.", "1e3", "1.5E-3", "INF", "-INF", "1e400"} {
+		if _, ok := parseDouble(s); !ok {
+			t.Errorf("parseDouble(%q) failed", s)
+		}
+	}
+	for _, s := range []string{"", ".", "0x1p3", "1_000", "Inf", "inf", "Infinity", "nan", "1e", "e3", "1.5.3", "- 1"} {
+		if v, ok := parseDouble(s); ok {
+			t.Errorf("parseDouble(%q) = %v, want error", s, v)
+		}
+	}
+	if v, _ := parseDouble("NaN"); !math.IsNaN(v) {
+		t.Errorf("parseDouble(NaN) = %v", v)
+	}
+	if v, _ := parseDouble("-INF"); !math.IsInf(v, -1) {
+		t.Errorf("parseDouble(-INF) = %v", v)
+	}
+}
@@ -0,0 +1,262 @@
+// Package atompub provides typed views of Atom Publishing Protocol
+// (RFC 5023) documents: service documents, category documents and
+// media link entries.
+//
+// Each type wraps an *xmltree.Element, and reads from and writes to
+// that element directly, so that extension elements and attributes
+// not covered by this package are preserved when the tree is encoded.
+package atompub // import "github.com/mdejong/xmltree/atompub"
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+)
+
+// XML namespaces used in AtomPub documents.
+const (
+	AppNamespace  = "http://www.w3.org/2007/app"
+	AtomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+// children returns the children of el in the given namespace with the
+// given local name.
+func children(el *xmltree.Element, space, local string) []*xmltree.Element {
+	var result []*xmltree.Element
+	for i := range el.Children {
+		c := &el.Children[i]
+		if c.Name.Space == space && c.Name.Local == local {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// text returns the content of the first child of el with the given
+// name, or the empty string.
+func text(el *xmltree.Element, space, local string) string {
+	if c := children(el, space, local); len(c) > 0 {
+		return strings.TrimSpace(string(c[0].Content))
+	}
+	return ""
+}
+
+func parseRoot(data []byte, space, local string) (*xmltree.Element, error) {
+	root, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Name.Space != space || root.Name.Local != local {
+		return nil, fmt.Errorf("atompub: root element is {%s}%s, want {%s}%s",
+			root.Name.Space, root.Name.Local, space, local)
+	}
+	return root, nil
+}
+
+// A Service is an app:service document, listing the workspaces and
+// collections offered by a server.
+type Service struct {
+	*xmltree.Element
+}
+
+// ParseService parses a service document.
+func ParseService(data []byte) (Service, error) {
+	root, err := parseRoot(data, AppNamespace, "service")
+	return Service{root}, err
+}
+
+// Workspaces returns the workspaces of the service.
+func (s Service) Workspaces() []Workspace {
+	var result []Workspace
+	for _, el := range children(s.Element, AppNamespace, "workspace") {
+		result = append(result, Workspace{el})
+	}
+	return result
+}
+
+// A Workspace groups related collections.
+type Workspace struct {
+	*xmltree.Element
+}
+
+// Title returns the atom:title of the workspace.
+func (w Workspace) Title() string {
+	return text(w.Element, AtomNamespace, "title")
+}
+
+// Collections returns the collections in the workspace.
+func (w Workspace) Collections() []Collection {
+	var result []Collection
+	for _, el := range children(w.Element, AppNamespace, "collection") {
+		result = append(result, Collection{el})
+	}
+	return result
+}
+
+// A Collection is a set of resources that can be listed and added to.
+type Collection struct {
+	*xmltree.Element
+}
+
+// Title returns the atom:title of the collection.
+func (c Collection) Title() string {
+	return text(c.Element, AtomNamespace, "title")
+}
+
+// Href returns the IRI of the collection, as written in the document.
+func (c Collection) Href() string {
+	return c.Attr("", "href")
+}
+
+// Accept returns the media ranges the collection accepts. Following
+// RFC 5023, a collection with no app:accept element accepts Atom
+// entries only.
+func (c Collection) Accept() []string {
+	accepts := children(c.Element, AppNamespace, "accept")
+	if len(accepts) == 0 {
+		return []string{"application/atom+xml;type=entry"}
+	}
+	var result []string
+	for _, el := range accepts {
+		result = append(result, strings.TrimSpace(string(el.Content)))
+	}
+	return result
+}
+
+// Categories returns the category documents embedded in, or linked
+// from, the collection.
+func (c Collection) Categories() []Categories {
+	var result []Categories
+	for _, el := range children(c.Element, AppNamespace, "categories") {
+		result = append(result, Categories{el})
+	}
+	return result
+}
+
+// Categories is an app:categories document or element.
+type Categories struct {
+	*xmltree.Element
+}
+
+// ParseCategories parses a category document.
+func ParseCategories(data []byte) (Categories, error) {
+	root, err := parseRoot(data, AppNamespace, "categories")
+	return Categories{root}, err
+}
+
+// Fixed reports whether the list of categories is closed.
+func (c Categories) Fixed() bool {
+	return c.Attr("", "fixed") == "yes"
+}
+
+// Scheme returns the default scheme for the categories.
+func (c Categories) Scheme() string {
+	return c.Attr("", "scheme")
+}
+
+// Href returns the IRI of an out-of-line category document, or the
+// empty string if the categories are listed inline.
+func (c Categories) Href() string {
+	return c.Attr("", "href")
+}
+
+// A Category is an atom:category.
+type Category struct {
+	Term, Scheme, Label string
+}
+
+// List returns the categories. A category without a scheme inherits
+// the scheme of the list.
+func (c Categories) List() []Category {
+	var result []Category
+	for _, el := range children(c.Element, AtomNamespace, "category") {
+		cat := Category{
+			Term:   el.Attr("", "term"),
+			Scheme: el.Attr("", "scheme"),
+			Label:  el.Attr("", "label"),
+		}
+		if cat.Scheme == "" {
+			cat.Scheme = c.Scheme()
+		}
+		result = append(result, cat)
+	}
+	return result
+}
+
+// Add appends a category to the list.
+func (c Categories) Add(cat Category) {
+	el := xmltree.Element{
+		StartElement: xml.StartElement{Name: xml.Name{Space: AtomNamespace, Local: "category"}},
+		Scope:        c.Scope,
+	}
+	el.SetAttr("", "term", cat.Term)
+	if cat.Scheme != "" && cat.Scheme != c.Scheme() {
+		el.SetAttr("", "scheme", cat.Scheme)
+	}
+	if cat.Label != "" {
+		el.SetAttr("", "label", cat.Label)
+	}
+	c.Children = append(c.Children, el)
+}
+
+// A MediaEntry is an atom:entry describing a media resource, such
+// as an image, created by posting to a collection.
+type MediaEntry struct {
+	*xmltree.Element
+}
+
+// ParseMediaEntry parses a media link entry.
+func ParseMediaEntry(data []byte) (MediaEntry, error) {
+	root, err := parseRoot(data, AtomNamespace, "entry")
+	return MediaEntry{root}, err
+}
+
+func (m MediaEntry) link(rel string) string {
+	for _, el := range children(m.Element, AtomNamespace, "link") {
+		if el.Attr("", "rel") == rel {
+			return el.Attr("", "href")
+		}
+	}
+	return ""
+}
+
+// EditLink returns the IRI used to update or delete the entry.
+func (m MediaEntry) EditLink() string {
+	return m.link("edit")
+}
+
+// EditMediaLink returns the IRI used to update or delete the media
+// resource itself.
+func (m MediaEntry) EditMediaLink() string {
+	return m.link("edit-media")
+}
+
+// Content returns the src and type attributes of the atom:content
+// element, which refers to the media resource.
+func (m MediaEntry) Content() (src, mediaType string) {
+	if c := children(m.Element, AtomNamespace, "content"); len(c) > 0 {
+		return c[0].Attr("", "src"), c[0].Attr("", "type")
+	}
+	return "", ""
+}
+
+// Title returns the atom:title of the entry.
+func (m MediaEntry) Title() string {
+	return text(m.Element, AtomNamespace, "title")
+}
+
+// SetTitle replaces the atom:title of the entry, adding one if there
+// is none.
+func (m MediaEntry) SetTitle(title string) {
+	if c := children(m.Element, AtomNamespace, "title"); len(c) > 0 {
+		c[0].Content = []byte(title)
+		return
+	}
+	m.Children = append(m.Children, xmltree.Element{
+		StartElement: xml.StartElement{Name: xml.Name{Space: AtomNamespace, Local: "title"}},
+		Scope:        m.Scope,
+		Content:      []byte(title),
+	})
+}
@@ -0,0 +1,84 @@
+package atompub
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+const service = `<service xmlns="http://www.w3.org/2007/app" xmlns:atom="http://www.w3.org/2005/Atom">
+  <workspace>
+    <atom:title>Main Site</atom:title>
+    <collection href="http://example.org/blog/main">
+      <atom:title>My Blog Entries</atom:title>
+      <categories href="http://example.com/cats/forMain.cats"/>
+    </collection>
+    <collection href="http://example.org/blog/pic">
+      <atom:title>Pictures</atom:title>
+      <accept>image/png</accept>
+      <accept>image/jpeg</accept>
+      <categories fixed="yes" scheme="urn:s"><atom:category term="a"/><atom:category term="b" scheme="urn:t"/></categories>
+    </collection>
+  </workspace>
+</service>`
+
+func TestService(t *testing.T) {
+	s, err := ParseService([]byte(service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := s.Workspaces()
+	if len(ws) != 1 || ws[0].Title() != "Main Site" {
+		t.Fatalf("workspaces: %v", ws)
+	}
+	cols := ws[0].Collections()
+	if len(cols) != 2 || cols[1].Href() != "http://example.org/blog/pic" || cols[1].Title() != "Pictures" {
+		t.Fatalf("collections: %v", cols)
+	}
+	if got := cols[0].Accept(); !reflect.DeepEqual(got, []string{"application/atom+xml;type=entry"}) {
+		t.Errorf("default accept %v", got)
+	}
+	if got := cols[1].Accept(); !reflect.DeepEqual(got, []string{"image/png", "image/jpeg"}) {
+		t.Errorf("accept %v", got)
+	}
+	if href := cols[0].Categories()[0].Href(); href != "http://example.com/cats/forMain.cats" {
+		t.Errorf("categories href %q", href)
+	}
+	cats := cols[1].Categories()[0]
+	want := []Category{{Term: "a", Scheme: "urn:s"}, {Term: "b", Scheme: "urn:t"}}
+	if !cats.Fixed() || !reflect.DeepEqual(cats.List(), want) {
+		t.Errorf("categories %v", cats.List())
+	}
+	cats.Add(Category{Term: "c", Label: "See"})
+	if out := string(xmltree.Marshal(s.Element)); !strings.Contains(out, `<atom:category term="c" label="See" />`) {
+		t.Errorf("added category not encoded:\n%s", out)
+	}
+	if _, err := ParseService([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"/>`)); err == nil {
+		t.Error("parsed a feed as a service document")
+	}
+}
+
+func TestMediaEntry(t *testing.T) {
+	m, err := ParseMediaEntry([]byte(`<entry xmlns="http://www.w3.org/2005/Atom">
+  <id>urn:uuid:1</id>
+  <link rel="edit" href="http://example.org/media/edit/x.atom"/>
+  <link rel="edit-media" href="http://example.org/media/x.png"/>
+  <content type="image/png" src="http://example.org/media/x.png"/>
+</entry>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.EditLink() != "http://example.org/media/edit/x.atom" || m.EditMediaLink() != "http://example.org/media/x.png" {
+		t.Errorf("links %q %q", m.EditLink(), m.EditMediaLink())
+	}
+	if src, typ := m.Content(); src != "http://example.org/media/x.png" || typ != "image/png" {
+		t.Errorf("content %q %q", src, typ)
+	}
+	m.SetTitle("Beach")
+	m.SetTitle("Beach at dusk")
+	if m.Title() != "Beach at dusk" || len(m.Search("", "title")) != 1 {
+		t.Errorf("title %q", m.Title())
+	}
+}
@@ -0,0 +1,109 @@
+package xmltree
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// DecodeAttrs stores the attributes of el in the struct pointed to by
+// v. Each field to be set is tagged with the name of an attribute,
+// which may include a namespace prefix resolved in the scope of el:
+//
+//	var item struct {
+//		ID    string  `attr:"id"`
+//		Price float64 `attr:"price"`
+//		Lang  string  `attr:"xml:lang"`
+//		Count int     `attr:"count,required"`
+//	}
+//	err := xmltree.DecodeAttrs(el, &item)
+//
+// Fields may be strings, booleans, integers or floating point numbers,
+// or implement encoding.TextUnmarshaler. Booleans accept the lexical
+// forms of xs:boolean. Fields whose attribute is absent are left
+// unchanged, unless the tag includes the "required" option, in which
+// case DecodeAttrs returns an error. Values that cannot be converted
+// are reported with a *ValueError.
+func DecodeAttrs(el *Element, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("xmltree: DecodeAttrs requires a non-nil pointer to a struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("attr")
+		if !ok || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		value, found := el.lookupAttr(name)
+		if !found {
+			if opts == "required" {
+				return fmt.Errorf("xmltree: /%s: missing required attribute %s", el.Prefix(el.Name), name)
+			}
+			continue
+		}
+		if err := el.setField(rv.Field(i), name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (el *Element) setField(fv reflect.Value, name, value string) error {
+	fail := func(typ string, err error) error {
+		attr := el.Resolve(name)
+		if !strings.Contains(name, ":") {
+			attr.Space = ""
+		}
+		return el.attrValueError(typ, value, attr, err)
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		if err := fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return fail(fv.Type().String(), err)
+		}
+		return nil
+	}
+	trimmed := strings.TrimSpace(value)
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		switch trimmed {
+		case "true", "1":
+			fv.SetBool(true)
+		case "false", "0":
+			fv.SetBool(false)
+		default:
+			return fail("boolean", nil)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(trimmed, 10, fv.Type().Bits())
+		if err != nil {
+			return fail("integer", err.(*strconv.NumError).Err)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(trimmed, 10, fv.Type().Bits())
+		if err != nil {
+			return fail("nonNegativeInteger", err.(*strconv.NumError).Err)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(trimmed, fv.Type().Bits())
+		if err != nil {
+			return fail("double", err.(*strconv.NumError).Err)
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("xmltree: DecodeAttrs: unsupported field type %s for attribute %s", fv.Type(), name)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package xmltree
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestDecodeAttrs(t *testing.T) {
+	root := parseDoc(t, []byte(`<list xmlns:x="urn:x"><item id="a1" price=" 9.5 " count="3" ok="1" x:flag="false" ip="10.0.0.1" xml:lang="en"/></list>`))
+	item := &root.Children[0]
+	var v struct {
+		ID      string  `attr:"id"`
+		Price   float64 `attr:"price"`
+		Count   uint8   `attr:"count,required"`
+		OK      bool    `attr:"ok"`
+		Flag    bool    `attr:"x:flag"`
+		IP      net.IP  `attr:"ip"`
+		Lang    string  `attr:"xml:lang"`
+		Missing int     `attr:"missing"`
+		Ignored string
+	}
+	v.Missing = 7
+	if err := DecodeAttrs(item, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != "a1" || v.Price != 9.5 || v.Count != 3 || !v.OK || v.Flag ||
+		v.IP.String() != "10.0.0.1" || v.Lang != "en" || v.Missing != 7 {
+		t.Errorf("unexpected result %+v", v)
+	}
+
+	var bad struct {
+		Count int8 `attr:"count"`
+		Price int  `attr:"price"`
+	}
+	item.SetAttr("", "count", "300")
+	err := DecodeAttrs(item, &bad)
+	var valueErr *ValueError
+	if !errors.As(err, &valueErr) || valueErr.Attr.Local != "count" || !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("out of range value gave %v", err)
+	}
+
+	var required struct {
+		Name string `attr:"name,required"`
+	}
+	if err := DecodeAttrs(item, &required); err == nil {
+		t.Error("missing required attribute accepted")
+	}
+	if err := DecodeAttrs(item, required); err == nil {
+		t.Error("non-pointer accepted")
+	}
+}
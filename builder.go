@@ -0,0 +1,236 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// A Builder constructs a tree of Elements with a chain of method
+// calls, filling in names, namespaces and scopes, which are tedious
+// to get right by hand:
+//
+//	root, err := xmltree.New("x:catalog").NS("urn:example:catalog", "x").
+//		Child("x:item").Attr("id", "1").Text("first").End().
+//		Child("x:item").Attr("id", "2").Text("second").End().
+//		Build()
+//
+// Names may have a prefix, which is resolved in the scope of the
+// element once it is complete, so an element may use a prefix that
+// it declares itself. Unprefixed element names are in the default
+// namespace, if one is declared, and unprefixed attribute names are
+// in no namespace, as in a parsed document. Namespaces must be
+// declared on an element before any children are added to it.
+//
+// Once a method fails, the Builder ignores every later call, and the
+// first error is returned by Build.
+type Builder struct {
+	root  *Element
+	stack []buildFrame // the open elements, innermost last
+	err   error
+}
+
+// A buildFrame holds the names of an open element, which are
+// resolved when it is closed.
+type buildFrame struct {
+	el    *Element
+	name  string
+	attrs []string
+}
+
+// New returns a Builder for a tree whose root element has the given
+// name.
+func New(name string) *Builder {
+	b := &Builder{root: new(Element)}
+	b.stack = []buildFrame{{el: b.root, name: name}}
+	b.checkName(name)
+	return b
+}
+
+func (b *Builder) fail(format string, args ...interface{}) *Builder {
+	if b.err == nil {
+		b.err = fmt.Errorf("xmltree: Builder: "+format, args...)
+	}
+	return b
+}
+
+// current returns the innermost open element, or nil if the Builder
+// has failed or every element has been closed.
+func (b *Builder) current() *buildFrame {
+	if b.err != nil {
+		return nil
+	}
+	if len(b.stack) == 0 {
+		b.fail("no open element")
+		return nil
+	}
+	return &b.stack[len(b.stack)-1]
+}
+
+// checkName checks that qname is a valid name, with an optional
+// prefix.
+func (b *Builder) checkName(qname string) bool {
+	prefix, local := "", qname
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		prefix, local = qname[:i], qname[i+1:]
+		if !IsValidName(prefix) {
+			b.fail("invalid name %q", qname)
+			return false
+		}
+	}
+	if !IsValidName(local) {
+		b.fail("invalid name %q", qname)
+		return false
+	}
+	return true
+}
+
+// NS declares prefix for the namespace uri on the current element.
+// If prefix is empty, uri becomes the default namespace.
+func (b *Builder) NS(uri, prefix string) *Builder {
+	f := b.current()
+	if f == nil {
+		return b
+	}
+	if prefix != "" && !IsValidName(prefix) {
+		return b.fail("invalid prefix %q", prefix)
+	}
+	if len(f.el.Children) > 0 {
+		return b.fail("namespace %s declared after children of %s", uri, f.name)
+	}
+	ns := f.el.ns
+	f.el.ns = append(ns[:len(ns):len(ns)], xml.Name{Space: uri, Local: prefix})
+	return b
+}
+
+// Attr sets an attribute of the current element, replacing any
+// earlier value with the same name. Names are compared once their
+// prefixes are resolved, so a:x and b:x are the same attribute if a
+// and b are bound to the same namespace.
+func (b *Builder) Attr(name, value string) *Builder {
+	f := b.current()
+	if f == nil || !b.checkName(name) {
+		return b
+	}
+	f.attrs = append(f.attrs, name)
+	f.el.StartElement.Attr = append(f.el.StartElement.Attr, xml.Attr{Value: value})
+	return b
+}
+
+// Text sets the text content of the current element, which may not
+// have children.
+func (b *Builder) Text(text string) *Builder {
+	f := b.current()
+	if f == nil {
+		return b
+	}
+	if len(f.el.Children) > 0 {
+		return b.fail("text added to %s, which has children", f.name)
+	}
+	f.el.Content = []byte(text)
+	return b
+}
+
+// Child adds an element with the given name as the last child of the
+// current element, and makes it the current element, until End is
+// called.
+func (b *Builder) Child(name string) *Builder {
+	f := b.current()
+	if f == nil || !b.checkName(name) {
+		return b
+	}
+	if len(f.el.Content) > 0 {
+		return b.fail("child %s added to %s, which has text", name, f.name)
+	}
+	parent := f.el
+	parent.Children = append(parent.Children, Element{Scope: parent.Scope})
+	b.stack = append(b.stack, buildFrame{el: &parent.Children[len(parent.Children)-1], name: name})
+	return b
+}
+
+// Append adds a copy of el as the last child of the current element,
+// merging namespaces as AppendChild does.
+func (b *Builder) Append(el *Element) *Builder {
+	f := b.current()
+	if f == nil {
+		return b
+	}
+	if len(f.el.Content) > 0 {
+		return b.fail("child added to %s, which has text", f.name)
+	}
+	f.el.AppendChild(el)
+	return b
+}
+
+// End closes the current element, making its parent the current
+// element again.
+func (b *Builder) End() *Builder {
+	f := b.current()
+	if f == nil {
+		return b
+	}
+	if err := f.resolve(); err != nil {
+		b.err = err
+		return b
+	}
+	b.stack = b.stack[:len(b.stack)-1]
+	return b
+}
+
+// Build closes any elements that are still open and returns the
+// root of the tree, or the first error encountered while building
+// it.
+func (b *Builder) Build() (*Element, error) {
+	for b.err == nil && len(b.stack) > 0 {
+		b.End()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	b.root.link(0)
+	return b.root, nil
+}
+
+// MustBuild is like Build, but panics if the tree could not be built.
+func (b *Builder) MustBuild() *Element {
+	el, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return el
+}
+
+// resolve sets the names of the element and its attributes, by
+// resolving their prefixes in its scope. Of several attributes that
+// resolve to the same name, the first is kept, with the value of the
+// last.
+func (f *buildFrame) resolve() error {
+	el := f.el
+	name, ok := el.ResolveNS(f.name)
+	if !ok && strings.Contains(f.name, ":") {
+		return fmt.Errorf("xmltree: Builder: undeclared prefix in %q", f.name)
+	}
+	el.Name = name
+	attrs := el.StartElement.Attr[:0]
+	for i, qname := range f.attrs {
+		name := xml.Name{Local: qname}
+		if strings.Contains(qname, ":") {
+			if name, ok = el.ResolveNS(qname); !ok {
+				return fmt.Errorf("xmltree: Builder: undeclared prefix in %q on %s", qname, f.name)
+			}
+		}
+		value := el.StartElement.Attr[i].Value
+		dup := false
+		for j := range attrs {
+			if attrs[j].Name == name {
+				attrs[j].Value, dup = value, true
+				break
+			}
+		}
+		if !dup {
+			attrs = append(attrs, xml.Attr{Name: name, Value: value})
+		}
+	}
+	el.StartElement.Attr = attrs
+	return nil
+}
@@ -0,0 +1,59 @@
+package xmltree
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	root, err := New("x:catalog").NS("urn:catalog", "x").NS("urn:default", "").
+		Child("x:item").Attr("id", "1").Attr("x:state", "new").Text("first").End().
+		Child("note").Child("y:ref").NS("urn:y", "y").Text("r").End().End().
+		Append(MustParse([]byte(`<x:item xmlns:x="urn:other"/>`))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<x:catalog xmlns:x="urn:catalog" xmlns="urn:default">` +
+		`<x:item id="1" x:state="new">first</x:item>` +
+		`<note><y:ref xmlns:y="urn:y">r</y:ref></note>` +
+		`<x:item xmlns:x="urn:other" /></x:catalog>`
+	if got := root.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if note := root.Children[1]; note.Name.Space != "urn:default" || note.Children[0].Parent() != &root.Children[1] {
+		t.Errorf("note built as %+v", note.Name)
+	}
+	if a := root.Children[0].StartElement.Attr[1]; a.Name.Space != "urn:catalog" {
+		t.Errorf("x:state resolved to %v", a.Name)
+	}
+}
+
+func TestBuilderSameAttr(t *testing.T) {
+	root := New("a").NS("urn:x", "p").NS("urn:x", "q").
+		Attr("p:v", "1").Attr("id", "a").Attr("q:v", "2").Attr("id", "b").
+		MustBuild()
+	attrs := root.StartElement.Attr
+	if len(attrs) != 2 || attrs[0].Value != "2" || attrs[1].Value != "b" {
+		t.Errorf("got attributes %v", attrs)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		b    *Builder
+		want string
+	}{
+		{New("p:a"), "undeclared prefix"},
+		{New("a").Child("b").End().NS("urn:x", "x"), "after children"},
+		{New("a").Text("t").Child("b"), "has text"},
+		{New("a b"), "invalid name"},
+		{New("a").End().Child("b"), "no open element"},
+	}
+	for _, tt := range tests {
+		_, err := tt.b.Build()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("got error %v, want %q", err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package xmltree
+
+import "bytes"
+
+var (
+	cdataStart = []byte("<![CDATA[")
+	cdataEnd   = []byte("]]>")
+)
+
+// WithCDATA causes Parse to keep track of CDATA sections. When the
+// content of an element with no children consists only of CDATA
+// sections, its Content is set to their text, and its CDATA field
+// is set so that Marshal and Encode write the content as a CDATA
+// section again. This preserves the readability of embedded
+// scripts, SQL and markup. Content that mixes CDATA sections with
+// other text is unaffected. Content moved to a ContentStore by
+// WithContentSpill is always written as escaped text.
+func WithCDATA() ParseOption {
+	return func(o *parseOptions) {
+		o.cdata = true
+	}
+}
+
+// isCDATA reports whether the character data just scanned was a
+// CDATA section, by looking at the source document.
+func (s *scanner) isCDATA(data []byte) bool {
+	data = data[:cap(data)]
+	return s.tokStart < int64(len(data)) && bytes.HasPrefix(data[s.tokStart:], cdataStart)
+}
+
+// writeCDATA writes text to w as a CDATA section. A section cannot
+// contain "]]>", so the text is split into several sections around
+// each occurrence.
+func writeCDATA(w writer, text []byte) {
+	w.Write(cdataStart)
+	for {
+		i := bytes.Index(text, cdataEnd)
+		if i < 0 {
+			break
+		}
+		w.Write(text[:i+2])
+		w.WriteString("]]><![CDATA[")
+		text = text[i+2:]
+	}
+	w.Write(text)
+	w.Write(cdataEnd)
+}
+
+// WithAutoCDATA writes the content of an element as a CDATA section,
+// rather than as escaped text, when it contains at least min
+// characters that would have to be escaped, and they make up at least
+// ratio of its length. Embedded markup and code is then both smaller
+// and easier to read. A min of zero or less disables the option.
+//
+//	// write snippets of HTML as CDATA, but leave "A & B" alone
+//	xmltree.Marshal(el, xmltree.WithAutoCDATA(4, 0.02))
+func WithAutoCDATA(min int, ratio float64) EncodeOption {
+	return func(e *encoder) {
+		e.cdataMin, e.cdataRatio = min, ratio
+	}
+}
+
+// wantCDATA reports whether WithAutoCDATA applies to text.
+func (e *encoder) wantCDATA(text []byte) bool {
+	if e.cdataMin <= 0 || indexSpecial(text) < 0 {
+		return false
+	}
+	n := 0
+	for _, c := range text {
+		if escapes[c] != "" {
+			n++
+		}
+	}
+	return n >= e.cdataMin && float64(n) >= e.cdataRatio*float64(len(text))
+}
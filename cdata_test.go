@@ -0,0 +1,57 @@
+package xmltree
+
+import "testing"
+
+func TestWithCDATA(t *testing.T) {
+	input := `<page>` +
+		`<script><![CDATA[if (a < b && c) {}]]></script>` +
+		`<split><![CDATA[a]]]]><![CDATA[>b]]></split>` +
+		`<mixed>x &amp; <![CDATA[<y>]]></mixed>` +
+		`</page>`
+	el, err := Parse([]byte(input), WithCDATA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := el.Child("", "script")
+	if !script.CDATA || string(script.Content) != "if (a < b && c) {}" {
+		t.Errorf("script: CDATA %v, content %q", script.CDATA, script.Content)
+	}
+	if split := el.Child("", "split"); string(split.Content) != "a]]>b" {
+		t.Errorf("split: content %q", split.Content)
+	}
+	if mixed := el.Child("", "mixed"); mixed.CDATA {
+		t.Error("mixed content marked as CDATA")
+	}
+	want := `<page>` +
+		`<script><![CDATA[if (a < b && c) {}]]></script>` +
+		`<split><![CDATA[a]]]]><![CDATA[>b]]></split>` +
+		`<mixed>x &amp; &lt;![CDATA[&lt;y&gt;]]&gt;</mixed>` +
+		`</page>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := string(Marshal(el.Clone())); got != want {
+		t.Errorf("clone marshals as %s", got)
+	}
+
+	plain, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Child("", "script").CDATA {
+		t.Error("CDATA set without WithCDATA")
+	}
+}
+
+func TestWithAutoCDATA(t *testing.T) {
+	el := MustParse([]byte(`<doc><html>&lt;p&gt;&lt;b&gt;hi&lt;/b&gt;&lt;/p&gt;</html>` +
+		`<name>A &amp; B, a long name</name><edge>]]&gt;&lt;&lt;</edge></doc>`))
+	want := `<doc><html><![CDATA[<p><b>hi</b></p>]]></html>` +
+		`<name>A &amp; B, a long name</name><edge><![CDATA[]]]]><![CDATA[><<]]></edge></doc>`
+	if got := string(Marshal(el, WithAutoCDATA(3, 0.1))); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := MustParse(Marshal(el, WithAutoCDATA(3, 0.1)), WithCDATA()); string(got.Child("", "edge").Content) != "]]><<" {
+		t.Errorf("content changed: %q", got.Child("", "edge").Content)
+	}
+}
@@ -0,0 +1,115 @@
+package xmltree
+
+// Elements with fewer children than this are searched linearly by
+// Child and ChildrenNamed; building a map for them costs more than
+// it saves.
+const childIndexMin = 32
+
+// A childIndex maps the local names of an element's children to
+// their positions in its Children slice. It records the slice it
+// was built from, so that appending to, truncating or replacing
+// Children invalidates it without any help from the caller; the
+// methods that reorder or rename children in place discard it.
+type childIndex struct {
+	first  *Element
+	n      int
+	byName map[string][]int
+}
+
+func (el *Element) buildChildIndex() *childIndex {
+	idx := &childIndex{
+		first:  &el.Children[0],
+		n:      len(el.Children),
+		byName: make(map[string][]int),
+	}
+	for i := range el.Children {
+		local := el.Children[i].Name.Local
+		idx.byName[local] = append(idx.byName[local], i)
+	}
+	el.index.Store(idx)
+	return idx
+}
+
+// valid reports whether idx was built from the current Children
+// slice of el.
+func (idx *childIndex) valid(el *Element) bool {
+	return idx.first == &el.Children[0] && idx.n == len(el.Children)
+}
+
+// childIndex returns an up to date index of el's children, building
+// it if necessary, or nil if el has too few children to index.
+func (el *Element) childIndex() *childIndex {
+	if len(el.Children) < childIndexMin {
+		return nil
+	}
+	idx, _ := el.index.Load().(*childIndex)
+	if idx == nil || !idx.valid(el) {
+		idx = el.buildChildIndex()
+	}
+	return idx
+}
+
+// invalidateChildIndex discards the index of el's children, for
+// methods that reorder or rename children in place, and LinkParents.
+func (el *Element) invalidateChildIndex() {
+	if idx, _ := el.index.Load().(*childIndex); idx != nil {
+		el.index.Store((*childIndex)(nil))
+	}
+}
+
+// Child returns the first child of el whose name matches the space
+// and local arguments, or nil if there is none. As with Attr, if
+// space is the empty string only local names are compared.
+//
+// For elements with many children, Child builds an index of them by
+// name on its first call, which later lookups on the same element
+// reuse. The index is rebuilt when the Children slice is appended to,
+// truncated or replaced, and after the methods of this package that
+// add, remove, move or rename children. A program that renames or
+// reorders children in place by other means, such as by assigning
+// to their Name fields, must call LinkParents before the next lookup.
+// The index is replaced atomically, so concurrent lookups on an
+// Element that is not being modified are safe.
+func (el *Element) Child(space, local string) *Element {
+	if idx := el.childIndex(); idx != nil {
+		for _, i := range idx.byName[local] {
+			if space == "" || el.Children[i].Name.Space == space {
+				return &el.Children[i]
+			}
+		}
+		return nil
+	}
+	for i := range el.Children {
+		if el.Children[i].Name.Local != local {
+			continue
+		}
+		if space == "" || el.Children[i].Name.Space == space {
+			return &el.Children[i]
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed returns the children of el whose names match the
+// space and local arguments, in document order. It uses the same
+// index as Child.
+func (el *Element) ChildrenNamed(space, local string) []*Element {
+	var result []*Element
+	if idx := el.childIndex(); idx != nil {
+		for _, i := range idx.byName[local] {
+			if space == "" || el.Children[i].Name.Space == space {
+				result = append(result, &el.Children[i])
+			}
+		}
+		return result
+	}
+	for i := range el.Children {
+		if el.Children[i].Name.Local != local {
+			continue
+		}
+		if space == "" || el.Children[i].Name.Space == space {
+			result = append(result, &el.Children[i])
+		}
+	}
+	return result
+}
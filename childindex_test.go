@@ -0,0 +1,109 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func wideDoc(n int) []byte {
+	var b strings.Builder
+	b.WriteString(`<root xmlns:x="urn:x">`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "<item%d>%d</item%d>", i%10, i, i%10)
+	}
+	b.WriteString(`<x:item0>ns</x:item0></root>`)
+	return []byte(b.String())
+}
+
+func TestChild(t *testing.T) {
+	for _, n := range []int{5, 200} {
+		root := parseDoc(t, wideDoc(n))
+		if c := root.Child("", "item3"); c == nil || string(c.Content) != "3" {
+			t.Errorf("%d: Child(item3) = %v", n, c)
+		}
+		if c := root.Child("urn:x", "item0"); c == nil || string(c.Content) != "ns" {
+			t.Errorf("%d: Child(urn:x item0) = %v", n, c)
+		}
+		if c := root.Child("", "missing"); c != nil {
+			t.Errorf("%d: Child(missing) = %v", n, c)
+		}
+		want := (n+9)/10 + 1
+		if got := len(root.ChildrenNamed("", "item0")); got != want {
+			t.Errorf("%d: got %d item0 children, want %d", n, got, want)
+		}
+	}
+}
+
+func TestChildIndexInvalidation(t *testing.T) {
+	root := parseDoc(t, wideDoc(100))
+	if root.Child("", "extra") != nil {
+		t.Fatal("found extra before it was added")
+	}
+	root.Children = append(root.Children, Element{StartElement: xml.StartElement{Name: xml.Name{Local: "extra"}}})
+	if root.Child("", "extra") == nil {
+		t.Error("index not rebuilt after append")
+	}
+
+	root.Children[0].Name.Local = "renamed"
+	root.LinkParents()
+	if c := root.Child("", "item0"); c == nil || string(c.Content) != "10" {
+		t.Errorf("index not rebuilt after rename: %v", c)
+	}
+
+	// Renaming a child to a name that is already indexed, or to a
+	// new one, must be visible to lookups after LinkParents.
+	root.ChildrenNamed("", "item")
+	root.Children[3].Name.Local = "item"
+	root.Children[13].Name.Local = "item"
+	root.LinkParents()
+	if got := len(root.ChildrenNamed("", "item")); got != 2 {
+		t.Errorf("got %d children named item after rename, want 2", got)
+	}
+	root.Children[4].Name.Local = "zz"
+	root.Children[5].Name.Local = "zz"
+	root.LinkParents()
+	if got := len(root.ChildrenNamed("", "zz")); got != 2 {
+		t.Errorf("got %d children named zz after rename, want 2", got)
+	}
+
+	err := root.SortChildrenBy("", func(el *Element) string {
+		return strings.Repeat("z", 3-len(el.Content))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := root.Child("", "item1"); c == nil || string(c.Content) != "11" {
+		t.Errorf("index not rebuilt after sort: %v", c)
+	}
+}
+
+// BenchmarkChild compares a lookup of a name that matches no child,
+// which must examine every child when they are not indexed, with a
+// linear scan, and measures a lookup that finds the last child.
+func BenchmarkChild(b *testing.B) {
+	root, err := Parse(wideDoc(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			root.Child("", "missing")
+		}
+	})
+	b.Run("indexed-last", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			root.Child("urn:x", "item0")
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range root.Children {
+				if root.Children[j].Name.Local == "missing" {
+					break
+				}
+			}
+		}
+	})
+}
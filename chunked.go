@@ -0,0 +1,97 @@
+package xmltree
+
+import (
+	"bufio"
+	"encoding/xml"
+	"net/http"
+)
+
+// size of the chunks written by WriteResponse and WriteRecords
+const chunkSize = 32 * 1024
+
+// WriteResponse encodes el as the body of an HTTP response. The
+// output is sent in chunks as it is produced, rather than being
+// buffered in full, and each write waits for the client to accept
+// the previous chunk, so the memory used does not depend on the size
+// of the document. If no Content-Type has been set, it is set to
+// application/xml. Options are applied as for Encode.
+func WriteResponse(w http.ResponseWriter, el *Element, opts ...EncodeOption) error {
+	setContentType(w)
+	bw := bufio.NewWriterSize(&flushWriter{w: w}, chunkSize)
+	e := encoder{w: bw}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	bw.WriteString(xml.Header)
+	if err := e.run(el); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteRecords is like WriteResponse, but after the children of
+// envelope, and before its end tag, it writes each element returned
+// by next, until next returns nil or an error. This allows an export
+// endpoint to produce records lazily, for example from a database
+// cursor, without building the whole tree. If next returns an error,
+// the response is left incomplete, so that the client does not
+// mistake it for a complete document, and the error is returned.
+//
+//	envelope := xmltree.MustParse([]byte(`<export xmlns="urn:example:export"/>`))
+//	err := xmltree.WriteRecords(w, envelope, func() (*xmltree.Element, error) {
+//		if !rows.Next() {
+//			return nil, rows.Err()
+//		}
+//		return recordFromRow(rows), nil
+//	})
+func WriteRecords(w http.ResponseWriter, envelope *Element, next func() (*Element, error), opts ...EncodeOption) error {
+	setContentType(w)
+	fw := &flushWriter{w: w}
+	dw, err := newDocumentWriter(bufio.NewWriterSize(fw, chunkSize), envelope, opts)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := next()
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			break
+		}
+		if err := dw.AppendElement(record); err != nil {
+			return err
+		}
+		if fw.err != nil {
+			return fw.err
+		}
+	}
+	return dw.Close()
+}
+
+func setContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	}
+}
+
+// A flushWriter sends each write to the client immediately, as a
+// chunk of the response body, and remembers the first error.
+type flushWriter struct {
+	w   http.ResponseWriter
+	err error
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		if f.err == nil {
+			f.err = err
+		}
+		return n, err
+	}
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, nil
+}
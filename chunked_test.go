@@ -0,0 +1,56 @@
+package xmltree
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	el := MustParse([]byte(`<a><b>x &amp; y</b></a>`))
+	if err := WriteResponse(rec, el); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if got := rec.Body.String(); !strings.HasSuffix(got, "?>\n<a><b>x &amp; y</b></a>") {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestWriteRecords(t *testing.T) {
+	rec := httptest.NewRecorder()
+	envelope := MustParse([]byte(`<export xmlns="urn:x"><header>h</header></export>`))
+	n := 0
+	next := func() (*Element, error) {
+		if n == 3 {
+			return nil, nil
+		}
+		n++
+		return MustParse([]byte(fmt.Sprintf(`<row xmlns="urn:x" id="%d"/>`, n))), nil
+	}
+	if err := WriteRecords(rec, envelope, next); err != nil {
+		t.Fatal(err)
+	}
+	want := `<export xmlns="urn:x"><header>h</header><row id="1" /><row id="2" /><row id="3" /></export>`
+	if got := rec.Body.String(); !strings.HasSuffix(got, "?>\n"+want) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if !rec.Flushed {
+		t.Error("response was not flushed")
+	}
+
+	rec = httptest.NewRecorder()
+	boom := errors.New("cursor failed")
+	err := WriteRecords(rec, MustParse([]byte(`<export/>`)), func() (*Element, error) { return nil, boom })
+	if err != boom {
+		t.Errorf("expected %v, got %v", boom, err)
+	}
+	if strings.Contains(rec.Body.String(), "</export>") {
+		t.Error("incomplete response was closed")
+	}
+}
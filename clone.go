@@ -0,0 +1,36 @@
+package xmltree
+
+import "encoding/xml"
+
+// Clone returns a deep copy of el, including its attributes, content,
+// children, Scope and Misc items. The copy shares no memory with el
+// that can be modified through the Element API, so either tree may be
+// changed, or the copy grafted into another document, without
+// affecting the other. The copy has no parent.
+func (el *Element) Clone() *Element {
+	dup := new(Element)
+	el.cloneInto(dup, 0)
+	return dup
+}
+
+func (el *Element) cloneInto(dup *Element, depth int) {
+	dup.StartElement = el.StartElement.Copy()
+	dup.Scope = Scope{ns: append([]xml.Name(nil), el.ns...)}
+	if el.Content != nil {
+		dup.Content = append([]byte(nil), el.Content...)
+	}
+	dup.CDATA = el.CDATA
+	dup.spill = el.spill
+	dup.xmlns = append([]xml.Attr(nil), el.xmlns...)
+	dup.prefix = el.prefix
+	dup.attrPrefixes = el.attrPrefixes
+	dup.Misc = cloneMisc(el.Misc)
+	if depth > recursionLimit || len(el.Children) == 0 {
+		return
+	}
+	dup.Children = make([]Element, len(el.Children))
+	for i := range el.Children {
+		el.Children[i].cloneInto(&dup.Children[i], depth+1)
+		dup.Children[i].parent, dup.Children[i].pos = dup, i
+	}
+}
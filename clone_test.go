@@ -0,0 +1,24 @@
+package xmltree
+
+import "testing"
+
+func TestClone(t *testing.T) {
+	orig := MustParse([]byte(`<a xmlns:p="urn:p" id="1"><p:b>text</p:b><!--note--><c/></a>`), WithComments())
+	dup := orig.Clone()
+	if dup.String() != orig.String() {
+		t.Fatalf("clone differs: %s", dup)
+	}
+
+	dup.SetAttr("", "id", "2")
+	dup.Children[0].Content[0] = 'T'
+	dup.Children[1].Name.Local = "d"
+	dup.Scope.ns[0].Space = "urn:changed"
+	dup.Misc[0].Data[0] = 'N'
+	want := `<a id="1" xmlns:p="urn:p"><p:b>text</p:b><!--note--><c /></a>`
+	if got := orig.String(); got != want {
+		t.Errorf("original modified through clone:\n%s\nwant\n%s", got, want)
+	}
+	if dup.Children[0].Parent() != dup || dup.Parent() != nil {
+		t.Errorf("clone is not linked correctly")
+	}
+}
@@ -9,10 +9,38 @@ import (
 // Equal returns true if two xmltree.Elements are equal, ignoring
 // differences in white space, sub-element order, and namespace prefixes.
 func Equal(a, b *Element) bool {
-	return equal(a, b, 0)
+	var c comparison
+	return c.equal(a, b, 0)
 }
 
-type byName []Element
+// EqualPrefix is like Equal, but only compares elements up to depth
+// levels below a and b. Elements at the limit are compared by name
+// and attributes only; their content and children are ignored. This
+// is useful for comparing the envelope or header structure of large
+// documents.
+func EqualPrefix(a, b *Element, depth int) bool {
+	c := comparison{limit: depth, limited: true}
+	return c.equal(a, b, 0)
+}
+
+// EqualStructure is like Equal, but ignores text content and the values
+// of attributes. Two trees are structurally equal if they have the same
+// elements, with the same attribute names, in the same shape.
+func EqualStructure(a, b *Element) bool {
+	c := comparison{structural: true}
+	return c.equal(a, b, 0)
+}
+
+// A comparison holds the settings of the various Equal functions.
+type comparison struct {
+	limited    bool
+	limit      int
+	structural bool
+}
+
+// byName sorts pointers to the children of an element, so that the
+// children themselves are left in place.
+type byName []*Element
 
 func (l byName) Len() int { return len(l) }
 func (l byName) Less(i, j int) bool {
@@ -20,31 +48,46 @@ func (l byName) Less(i, j int) bool {
 }
 func (l byName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
 
-func equal(a, b *Element, depth int) bool {
+func (c *comparison) equal(a, b *Element, depth int) bool {
 	const maxDepth = 1000
 	if depth > maxDepth {
 		return false
 	}
-	if !equalElement(a, b) {
+	if !equalElement(a, b, c.structural) {
 		return false
 	}
+	if c.limited && depth >= c.limit {
+		return true
+	}
 	if len(a.Children) != len(b.Children) {
 		return false
 	}
 	if len(a.Children) == 0 {
-		return bytes.Equal(bytes.TrimSpace(a.Content), bytes.TrimSpace(b.Content))
+		return c.structural || bytes.Equal(bytes.TrimSpace(a.Content), bytes.TrimSpace(b.Content))
 	}
-	sort.Sort(byName(a.Children))
-	sort.Sort(byName(b.Children))
-	for i := range a.Children {
-		if !equal(&a.Children[i], &b.Children[i], depth+1) {
+	as, bs := sortedChildren(a), sortedChildren(b)
+	for i := range as {
+		if !c.equal(as[i], bs[i], depth+1) {
 			return false
 		}
 	}
 	return true
 }
 
-func equalElement(a, b *Element) bool {
+// sortedChildren returns pointers to the children of el, sorted by
+// name.
+func sortedChildren(el *Element) []*Element {
+	children := make([]*Element, len(el.Children))
+	for i := range el.Children {
+		children[i] = &el.Children[i]
+	}
+	sort.Sort(byName(children))
+	return children
+}
+
+// equalElement compares the names and attributes of two elements.
+// If ignoreValues is true, only attribute names are compared.
+func equalElement(a, b *Element, ignoreValues bool) bool {
 	if a.Name != b.Name {
 		return false
 	}
@@ -60,7 +103,7 @@ func equalElement(a, b *Element) bool {
 		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
 			continue
 		}
-		if v, ok := attrs[a.Name]; !ok || v != a.Value {
+		if v, ok := attrs[a.Name]; !ok || (v != a.Value && !ignoreValues) {
 			return false
 		}
 	}
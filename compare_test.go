@@ -0,0 +1,48 @@
+package xmltree
+
+import "testing"
+
+func TestEqualPrefix(t *testing.T) {
+	a := parseDoc(t, []byte(`<env><head id="1"><to>a</to></head><body><x>1</x></body></env>`))
+	b := parseDoc(t, []byte(`<env><head id="1"><to>a</to></head><body><y>2</y><z/></body></env>`))
+	if Equal(a, b) {
+		t.Error("Equal reported different documents as equal")
+	}
+	if !EqualPrefix(a, b, 1) {
+		t.Error("EqualPrefix(1) reported documents with the same envelope as different")
+	}
+	if EqualPrefix(a, b, 2) {
+		t.Error("EqualPrefix(2) reported documents with different bodies as equal")
+	}
+	c := parseDoc(t, []byte(`<env><head id="2"/><body/></env>`))
+	if EqualPrefix(a, c, 1) {
+		t.Error("EqualPrefix(1) ignored attribute difference")
+	}
+}
+
+func TestEqualStructure(t *testing.T) {
+	a := parseDoc(t, []byte(`<list n="1"><item id="a">one</item><item id="b">two</item></list>`))
+	b := parseDoc(t, []byte(`<list n="2"><item id="c">three</item><item id="d"/></list>`))
+	if !EqualStructure(a, b) {
+		t.Error("EqualStructure reported trees with the same shape as different")
+	}
+	c := parseDoc(t, []byte(`<list n="2"><item id="c">three</item><item key="d"/></list>`))
+	if EqualStructure(a, c) {
+		t.Error("EqualStructure ignored differing attribute names")
+	}
+}
+
+func TestEqualLeavesInputs(t *testing.T) {
+	const doc = `<r><!--about z--><z><c/></z><!--about a--><a/></r>`
+	a := MustParse([]byte(doc), WithComments())
+	b := MustParse([]byte(`<r><a/><z><c/></z></r>`))
+	if !Equal(a, b) {
+		t.Fatal("documents not equal")
+	}
+	if got := a.String(); got != `<r><!--about z--><z><c /></z><!--about a--><a /></r>` {
+		t.Errorf("Equal reordered its input: %s", got)
+	}
+	if b.Children[0].Name.Local != "a" || b.Children[1].Parent() != b {
+		t.Error("Equal modified its second input")
+	}
+}
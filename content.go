@@ -0,0 +1,184 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentBase64Reader returns a reader that decodes the text content
+// of el as base64 (RFC 4648, standard alphabet with padding), as it
+// is read. White space within the content is ignored, as permitted by
+// the xs:base64Binary type. This allows large attachments to be
+// streamed to their destination without holding a second, decoded
+// copy in memory, particularly in combination with WithContentSpill.
+// Decoding errors are returned by Read. The caller should close the
+// returned reader.
+func (el *Element) ContentBase64Reader() io.ReadCloser {
+	r, err := el.ContentReader()
+	if err != nil {
+		return errReader{err}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{base64.NewDecoder(base64.StdEncoding, skipSpace{r}), r}
+}
+
+// skipSpace removes XML white space from the underlying reader.
+type skipSpace struct {
+	r io.Reader
+}
+
+func (s skipSpace) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		j := 0
+		for _, c := range p[:n] {
+			if !isSpace(c) {
+				p[j] = c
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// errReader is an io.ReadCloser that always fails with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+func (r errReader) Close() error             { return nil }
+
+// A ValueError is returned when the content of an element is not a
+// valid lexical representation of the requested type.
+type ValueError struct {
+	Element xml.Name
+	Attr    xml.Name // the attribute containing the value, if any
+	Type    string   // an XML Schema type name, such as "hexBinary"
+	Value   string   // the offending value, possibly abbreviated
+	Err     error    // the underlying error, if any
+
+	// Path locates the element within its tree, as PathTo would
+	// from the root, found by following parent pointers; see
+	// Parent. SetPath may be used to locate it relative to another
+	// element.
+	Path string
+}
+
+func (e *ValueError) Error() string {
+	where := e.Path
+	if e.Attr.Local != "" {
+		where += "/@" + e.Attr.Local
+	}
+	msg := fmt.Sprintf("xmltree: %s: invalid %s %q", where, e.Type, e.Value)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ValueError) Unwrap() error { return e.Err }
+
+// SetPath sets the Path of the error to the location of its element
+// within the tree rooted at root, as returned by PathTo. Callers that
+// search a larger document for values may use it to produce more
+// precise error messages.
+func (e *ValueError) SetPath(root, el *Element) {
+	if path := root.PathTo(el); path != "" {
+		e.Path = path
+	}
+}
+
+func (el *Element) valueError(typ, value string, err error) *ValueError {
+	if len(value) > 64 {
+		value = value[:61] + "..."
+	}
+	return &ValueError{
+		Element: el.Name,
+		Type:    typ,
+		Value:   value,
+		Err:     err,
+		Path:    el.path(),
+	}
+}
+
+// contentBytes returns all of the content of el, reading it from a
+// ContentStore if necessary.
+func (el *Element) contentBytes() ([]byte, error) {
+	if el.spill == nil {
+		return el.Content, nil
+	}
+	r, err := el.ContentReader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// setContent replaces the content of el, discarding any content held
+// in a ContentStore.
+func (el *Element) setContent(content []byte) {
+	el.Content = content
+	el.spill = nil
+}
+
+// ContentHex decodes the content of el as an xs:hexBinary value: an
+// even number of hexadecimal digits, in either case, optionally
+// surrounded by white space. If the content is not valid, a
+// *ValueError is returned.
+func (el *Element) ContentHex() ([]byte, error) {
+	content, err := el.contentBytes()
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimSpace(content)
+	b := make([]byte, hex.DecodedLen(len(content)))
+	if _, err := hex.Decode(b, content); err != nil {
+		return nil, el.valueError("hexBinary", string(content), err)
+	}
+	return b, nil
+}
+
+// SetContentHex replaces the content of el with the canonical
+// (upper case) xs:hexBinary representation of b.
+func (el *Element) SetContentHex(b []byte) {
+	el.setContent([]byte(strings.ToUpper(hex.EncodeToString(b))))
+}
+
+// ContentBase64 decodes the content of el as an xs:base64Binary value,
+// ignoring white space. If the content is not valid, a *ValueError is
+// returned. For large content, consider ContentBase64Reader.
+func (el *Element) ContentBase64() ([]byte, error) {
+	content, err := el.contentBytes()
+	if err != nil {
+		return nil, err
+	}
+	compact := bytes.Map(func(r rune) rune {
+		if r < 0x80 && isSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, content)
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(compact)))
+	n, err := base64.StdEncoding.Decode(b, compact)
+	if err != nil {
+		return nil, el.valueError("base64Binary", string(content), err)
+	}
+	return b[:n], nil
+}
+
+// SetContentBase64 replaces the content of el with the base64
+// encoding of b.
+func (el *Element) SetContentBase64(b []byte) {
+	el.setContent([]byte(base64.StdEncoding.EncodeToString(b)))
+}
@@ -0,0 +1,76 @@
+package xmltree
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestContentBase64Reader(t *testing.T) {
+	payload := strings.Repeat("attachment data ", 200)
+	enc := base64.StdEncoding.EncodeToString([]byte(payload))
+	var wrapped strings.Builder
+	for len(enc) > 76 {
+		wrapped.WriteString(enc[:76] + "\r\n  ")
+		enc = enc[76:]
+	}
+	wrapped.WriteString(enc)
+	doc := []byte("<att>\n  " + wrapped.String() + "\n</att>")
+
+	for _, opts := range [][]ParseOption{nil, {WithContentSpill(10, &TempFileStore{Dir: t.TempDir()})}} {
+		root, err := Parse(doc, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := root.ContentBase64Reader()
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != payload {
+			t.Errorf("decoded %d bytes, want %d", len(data), len(payload))
+		}
+	}
+
+	root := parseDoc(t, []byte(`<att>not*base64</att>`))
+	if _, err := io.ReadAll(root.ContentBase64Reader()); err == nil {
+		t.Error("expected error decoding invalid base64")
+	}
+}
+
+func TestContentHex(t *testing.T) {
+	root := parseDoc(t, []byte(`<r><ok> 0fA9 </ok><odd>abc</odd><bad>zz</bad></r>`))
+	b, err := root.Children[0].ContentHex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "\x0f\xa9" {
+		t.Errorf("ContentHex = %x", b)
+	}
+	for _, el := range root.Children[1:] {
+		var verr *ValueError
+		if _, err := el.ContentHex(); !errors.As(err, &verr) || verr.Type != "hexBinary" {
+			t.Errorf("<%s>: expected *ValueError, got %v", el.Name.Local, err)
+		}
+	}
+	el := &root.Children[0]
+	el.SetContentHex([]byte{0xde, 0xad})
+	if string(el.Content) != "DEAD" {
+		t.Errorf("SetContentHex produced %s", el.Content)
+	}
+}
+
+func TestContentBase64(t *testing.T) {
+	root := parseDoc(t, []byte("<r>aGVs\n bG8=</r>"))
+	b, err := root.ContentBase64()
+	if err != nil || string(b) != "hello" {
+		t.Errorf("ContentBase64 = %q, %v", b, err)
+	}
+	root.SetContentBase64([]byte("bye"))
+	if string(root.Content) != "Ynll" {
+		t.Errorf("SetContentBase64 produced %s", root.Content)
+	}
+}
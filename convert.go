@@ -0,0 +1,294 @@
+package xmltree
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// A ValueOption configures the conventions used by FromValue and
+// ToValue to map between generic Go values and elements.
+type ValueOption func(*valueConventions)
+
+type valueConventions struct {
+	root       string
+	attrMarker string
+	textKey    string
+	itemName   string
+}
+
+func newValueConventions(opts []ValueOption) *valueConventions {
+	c := &valueConventions{
+		root:       "root",
+		attrMarker: "@",
+		textKey:    "#text",
+		itemName:   "item",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithRootName sets the name of the root element created by
+// FromValue. The default is "root". ToValue ignores it.
+func WithRootName(name string) ValueOption {
+	return func(c *valueConventions) { c.root = name }
+}
+
+// WithAttrMarker sets the prefix that marks a map key as an attribute
+// name rather than a child element. The default is "@".
+func WithAttrMarker(marker string) ValueOption {
+	return func(c *valueConventions) { c.attrMarker = marker }
+}
+
+// WithTextKey sets the map key whose value is the text content of an
+// element. The default is "#text".
+func WithTextKey(key string) ValueOption {
+	return func(c *valueConventions) { c.textKey = key }
+}
+
+// WithItemName sets the name of the elements created for the members
+// of a slice that is not the value of a map key. The default is
+// "item".
+func WithItemName(name string) ValueOption {
+	return func(c *valueConventions) { c.itemName = name }
+}
+
+// FromValue converts a generic Go value, such as one produced by
+// decoding JSON into an interface{}, to a tree of elements. The root
+// element is named as set by WithRootName, and holds the value:
+//
+//   - A map with string keys becomes a child element for each key,
+//     in sorted order. Keys beginning with the attribute marker
+//     ("@") become attributes instead, and the value of the text key
+//     ("#text") becomes the element's content, if it has no
+//     children.
+//   - A slice that is the value of a map key becomes one child
+//     element named by the key for each member. Any other slice
+//     becomes a child element for each member, named by the item
+//     name ("item").
+//   - A []byte becomes base64-encoded content.
+//   - nil becomes an empty element.
+//   - Any other value becomes content: strings as they are, numbers
+//     and booleans as formatted by the strconv package, and values
+//     implementing encoding.TextMarshaler or fmt.Stringer by those
+//     methods.
+//
+// Pointers and interfaces are followed. Element and attribute names
+// are derived from map keys with SanitizeName and ReplaceInvalid, and
+// are in no namespace.
+//
+//	var v interface{}
+//	json.Unmarshal([]byte(`{"@id": "7", "name": "ada", "tags": ["x", "y"]}`), &v)
+//	el := xmltree.FromValue(v, xmltree.WithRootName("user"))
+//	// <user id="7"><name>ada</name><tags>x</tags><tags>y</tags></user>
+func FromValue(v interface{}, opts ...ValueOption) *Element {
+	c := newValueConventions(opts)
+	el := SafeElement(c.root)
+	c.fill(el, reflect.ValueOf(v), 0)
+	el.LinkParents()
+	return el
+}
+
+// ToValue converts el to a generic Go value, for use with code that
+// works on data decoded from JSON, such as template engines. It is
+// the inverse of FromValue, using the same conventions:
+//
+//   - An element with neither attributes nor children becomes a
+//     string holding its content.
+//   - If el, or an element that is itself converted to a member of
+//     a slice, has no attributes and only children named by the
+//     item name ("item"), it becomes a []interface{} of their
+//     values.
+//   - Any other element becomes a map[string]interface{}, with a key
+//     for each attribute, made of the attribute marker ("@") and
+//     its local name, and a key for the local name of each of its
+//     children. The values of children that share a name are
+//     collected in a []interface{}, in document order. An element
+//     with attributes but no children stores its content under the
+//     text key ("#text"), if it is not empty.
+//
+// The name of el itself and namespaces are not represented. A map
+// produced by ToValue gives no order to its keys, so passing it to
+// FromValue yields children in sorted order, and a slice with a
+// single member cannot be told apart from its member.
+//
+//	root, _ := xmltree.Parse([]byte(`<user id="7"><name>ada</name><tag>x</tag><tag>y</tag></user>`))
+//	v := root.ToValue()
+//	// map[string]interface{}{"@id": "7", "name": "ada", "tag": []interface{}{"x", "y"}}
+func (el *Element) ToValue(opts ...ValueOption) interface{} {
+	return newValueConventions(opts).value(el, true, 0)
+}
+
+// value converts el. Elements holding lists of items are converted
+// to slices only if unnamed, as is the root or a member of a list;
+// otherwise FromValue would name the members after el.
+func (c *valueConventions) value(el *Element, unnamed bool, depth int) interface{} {
+	content := func() string {
+		b, err := el.contentBytes()
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	if len(el.StartElement.Attr) == 0 && len(el.Children) == 0 {
+		return content()
+	}
+	if depth > recursionLimit {
+		return nil
+	}
+	if unnamed && len(el.StartElement.Attr) == 0 && c.allItems(el) {
+		list := make([]interface{}, len(el.Children))
+		for i := range el.Children {
+			list[i] = c.value(&el.Children[i], true, depth+1)
+		}
+		return list
+	}
+	m := make(map[string]interface{}, len(el.StartElement.Attr)+len(el.Children))
+	for _, a := range el.StartElement.Attr {
+		m[c.attrMarker+a.Name.Local] = a.Value
+	}
+	if len(el.Children) == 0 {
+		if text := content(); text != "" {
+			m[c.textKey] = text
+		}
+		return m
+	}
+	count := make(map[string]int)
+	for i := range el.Children {
+		count[el.Children[i].Name.Local]++
+	}
+	for i := range el.Children {
+		child := &el.Children[i]
+		v := c.value(child, false, depth+1)
+		if count[child.Name.Local] == 1 {
+			m[child.Name.Local] = v
+			continue
+		}
+		list, _ := m[child.Name.Local].([]interface{})
+		m[child.Name.Local] = append(list, v)
+	}
+	return m
+}
+
+func (c *valueConventions) allItems(el *Element) bool {
+	for i := range el.Children {
+		if el.Children[i].Name.Local != c.itemName {
+			return false
+		}
+	}
+	return len(el.Children) > 0
+}
+
+// fill sets the attributes, children and content of el from v.
+func (c *valueConventions) fill(el *Element, v reflect.Value, depth int) {
+	v = indirect(v)
+	if !v.IsValid() || depth > recursionLimit {
+		return
+	}
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		var text reflect.Value
+		for _, k := range keys {
+			mv := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+			switch {
+			case k == c.textKey:
+				text = mv
+			case c.attrMarker != "" && len(k) > len(c.attrMarker) && k[:len(c.attrMarker)] == c.attrMarker:
+				el.StartElement.Attr = append(el.StartElement.Attr, SafeAttr(k[len(c.attrMarker):], scalarString(indirect(mv))))
+			case isList(indirect(mv)):
+				list := indirect(mv)
+				for i := 0; i < list.Len(); i++ {
+					c.addChild(el, k, list.Index(i), depth)
+				}
+			default:
+				c.addChild(el, k, mv, depth)
+			}
+		}
+		if text.IsValid() && len(el.Children) == 0 {
+			el.Content = []byte(scalarString(indirect(text)))
+		}
+	case isList(v):
+		for i := 0; i < v.Len(); i++ {
+			c.addChild(el, c.itemName, v.Index(i), depth)
+		}
+	default:
+		el.Content = []byte(scalarString(v))
+	}
+}
+
+func (c *valueConventions) addChild(el *Element, name string, v reflect.Value, depth int) {
+	child := SafeElement(name)
+	c.fill(child, v, depth+1)
+	el.Children = append(el.Children, *child)
+}
+
+// indirect follows pointers and interfaces, returning the zero Value
+// for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isList reports whether v is a slice or array other than a []byte.
+func isList(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.Type().Elem().Kind() != reflect.Uint8
+	case reflect.Array:
+		return true
+	}
+	return false
+}
+
+// scalarString formats v as the content of an element or the value
+// of an attribute.
+func scalarString(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case encoding.TextMarshaler:
+			if text, err := x.MarshalText(); err == nil {
+				return string(text)
+			}
+		case fmt.Stringer:
+			return x.String()
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes())
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
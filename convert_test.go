@@ -0,0 +1,75 @@
+package xmltree
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromValue(t *testing.T) {
+	var v interface{}
+	doc := `{"@id": 7, "name": "ada", "tags": ["x", "y"], "address": {"#text": "ignored", "city": "London"},
+		"note": {"@lang": "en", "#text": "hi"}, "empty": null, "bad key": true}`
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	got := string(Marshal(FromValue(v, WithRootName("user"))))
+	want := `<user id="7"><address><city>London</city></address><bad_key>true</bad_key><empty /><name>ada</name>` +
+		`<note lang="en">hi</note><tags>x</tags><tags>y</tags></user>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	got = string(Marshal(FromValue([]interface{}{1.5, []byte("hi"), []string{"a"}},
+		WithItemName("v"), WithAttrMarker("-"))))
+	want = `<root><v>1.5</v><v>aGk=</v><v><v>a</v></v></root>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	got = string(Marshal(FromValue(map[string]interface{}{"-at": &when, "$t": "x"},
+		WithAttrMarker("-"), WithTextKey("$t"))))
+	want = `<root at="2020-01-02T03:04:05Z">x</root>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestToValue(t *testing.T) {
+	root := parseDoc(t, []byte(`<user id="7"><name>ada</name><tag>x</tag><tag>y</tag>`+
+		`<note lang="en">hi</note><list><item>1</item><item><a>2</a></item></list><empty/></user>`))
+	got := root.ToValue()
+	want := map[string]interface{}{
+		"@id":   "7",
+		"name":  "ada",
+		"tag":   []interface{}{"x", "y"},
+		"note":  map[string]interface{}{"@lang": "en", "#text": "hi"},
+		"list":  map[string]interface{}{"item": []interface{}{"1", map[string]interface{}{"a": "2"}}},
+		"empty": "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %#v\nwant %#v", got, want)
+	}
+
+	back := FromValue(got, WithRootName("user"))
+	sorted := parseDoc(t, []byte(`<user id="7"><empty/><list><item>1</item><item><a>2</a></item></list><name>ada</name>`+
+		`<note lang="en">hi</note><tag>x</tag><tag>y</tag></user>`))
+	if !Equal(back, sorted) {
+		t.Errorf("round trip produced %s", Marshal(back))
+	}
+
+	list := parseDoc(t, []byte(`<list><item>1</item><item><item>2</item><item>3</item></item></list>`))
+	if got, want := list.ToValue(), []interface{}{"1", []interface{}{"2", "3"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if back := FromValue(list.ToValue(), WithRootName("list")); !Equal(back, list) {
+		t.Errorf("round trip produced %s", Marshal(back))
+	}
+
+	got = root.ToValue(WithAttrMarker("-"), WithTextKey("$"), WithItemName("tag"))
+	if m := got.(map[string]interface{}); m["-id"] != "7" || !reflect.DeepEqual(m["note"], map[string]interface{}{"-lang": "en", "$": "hi"}) {
+		t.Errorf("conventions not applied: %#v", got)
+	}
+}
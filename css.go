@@ -0,0 +1,509 @@
+package xmltree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A CSSSelector is a compiled CSS selector, an alternative to the
+// XPath-like syntax of Selector that will be familiar from HTML
+// tools. The following are supported:
+//
+//	item            elements named item, in any namespace
+//	ns|item         item in the namespace bound to prefix ns at the
+//	                element the selector is applied to
+//	|item  *|item   item in no namespace; in any namespace
+//	*               any element
+//	#x  .x          elements whose id attribute is x; whose class
+//	                attribute contains the word x
+//	[a] [a=v]       elements with an attribute a; whose value is v.
+//	                The operators ~=, |=, ^=, $= and *= are also
+//	                supported, and values may be quoted.
+//	:first-child  :last-child  :only-child  :empty
+//	:nth-child(2)  :nth-child(2n+1)  :nth-child(odd)  :not(.x)
+//	a b  a > b      b below a; b a child of a
+//	a + b  a ~ b    b immediately after a; b after a, among siblings
+//	a, b            elements matching either selector
+//
+// Attribute names may have a prefix, written ns|a. The element the
+// selector is applied to may match the parts of a selector to the
+// left of a combinator, but is never itself among the results.
+type CSSSelector struct {
+	expr  string
+	group []cssComplex
+}
+
+type cssComplex struct {
+	compounds   []cssCompound
+	combinators []byte // combinators[i] joins compounds[i] and compounds[i+1]
+}
+
+type cssCompound struct {
+	prefix   string
+	space    string
+	anySpace bool
+	local    string // "*" matches any name
+	attrs    []cssAttr
+	pseudos  []cssPseudo
+}
+
+type cssAttr struct {
+	prefix string
+	local  string
+	op     string // "" tests only for presence
+	value  string
+}
+
+type cssPseudo struct {
+	name string
+	a, b int          // for nth-child, positions a*n+b
+	not  *cssCompound // for not
+}
+
+// CompileCSS parses a CSS selector.
+func CompileCSS(expr string) (*CSSSelector, error) {
+	sel := &CSSSelector{expr: expr}
+	for _, part := range splitTop(expr, ",") {
+		c, err := parseCSSComplex(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("xmltree: invalid CSS selector %q: %v", expr, err)
+		}
+		sel.group = append(sel.group, c)
+	}
+	return sel, nil
+}
+
+// MustCompileCSS is like CompileCSS, but panics if the selector
+// cannot be parsed.
+func MustCompileCSS(expr string) *CSSSelector {
+	sel, err := CompileCSS(expr)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+func (sel *CSSSelector) String() string {
+	return sel.expr
+}
+
+// Select returns the elements below el matching the CSS selector, in
+// document order. See CSSSelector for the syntax. An invalid selector
+// matches nothing.
+//
+//	links := doc.Select("nav > ul li:not(.hidden) a[href^='https:']")
+func (el *Element) Select(selector string) []*Element {
+	sel, err := CompileCSS(selector)
+	if err != nil {
+		return nil
+	}
+	return sel.MatchAll(el)
+}
+
+// MatchAll returns the elements below el matching sel, in document
+// order.
+func (sel *CSSSelector) MatchAll(el *Element) []*Element {
+	m := cssMatcher{root: el, sel: sel}
+	m.path = []*Element{el}
+	m.index = []int{-1}
+	m.walk(0)
+	return m.results
+}
+
+type cssMatcher struct {
+	root    *Element
+	sel     *CSSSelector
+	path    []*Element // the current element and its ancestors, from root
+	index   []int      // the position of each element of path in its parent
+	results []*Element
+}
+
+func (m *cssMatcher) walk(depth int) {
+	if depth > recursionLimit {
+		return
+	}
+	parent := m.path[depth]
+	for i := range parent.Children {
+		m.path = append(m.path[:depth+1], &parent.Children[i])
+		m.index = append(m.index[:depth+1], i)
+		for _, c := range m.sel.group {
+			if m.matches(&c, len(c.compounds)-1, depth+1, i) {
+				m.results = append(m.results, &parent.Children[i])
+				break
+			}
+		}
+		m.walk(depth + 1)
+	}
+}
+
+// at returns the element at the given depth of the current path, or
+// its sibling at the given index.
+func (m *cssMatcher) at(depth, index int) (el, parent *Element) {
+	if depth == 0 {
+		return m.path[0], nil
+	}
+	parent = m.path[depth-1]
+	return &parent.Children[index], parent
+}
+
+// matches reports whether compound i of c, and the compounds to its
+// left, match the element at depth and index.
+func (m *cssMatcher) matches(c *cssComplex, i, depth, index int) bool {
+	el, parent := m.at(depth, index)
+	if !c.compounds[i].match(m.root, el, parent, index) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case '>':
+		return depth > 0 && m.matches(c, i-1, depth-1, m.index[depth-1])
+	case ' ':
+		for d := depth - 1; d >= 0; d-- {
+			if m.matches(c, i-1, d, m.index[d]) {
+				return true
+			}
+		}
+	case '+':
+		return index > 0 && m.matches(c, i-1, depth, index-1)
+	case '~':
+		for j := index - 1; j >= 0; j-- {
+			if m.matches(c, i-1, depth, j) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cc *cssCompound) match(root, el, parent *Element, index int) bool {
+	if cc.local != "*" && el.Name.Local != cc.local {
+		return false
+	}
+	if !cc.anySpace {
+		space := cc.space
+		if cc.prefix != "" {
+			name, ok := root.ResolveNS(cc.prefix + ":" + cc.local)
+			if !ok {
+				return false
+			}
+			space = name.Space
+		}
+		if el.Name.Space != space {
+			return false
+		}
+	}
+	for _, a := range cc.attrs {
+		if !a.match(root, el) {
+			return false
+		}
+	}
+	for _, p := range cc.pseudos {
+		if !p.match(root, el, parent, index) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *cssAttr) match(root, el *Element) bool {
+	space, anySpace := "", a.prefix == ""
+	if a.prefix != "" && a.prefix != "*" {
+		name, ok := root.ResolveNS(a.prefix + ":" + a.local)
+		if !ok {
+			return false
+		}
+		space = name.Space
+	}
+	anySpace = anySpace || a.prefix == "*"
+	for _, attr := range el.StartElement.Attr {
+		if attr.Name.Local != a.local || !anySpace && attr.Name.Space != space {
+			continue
+		}
+		v := attr.Value
+		switch a.op {
+		case "":
+			return true
+		case "=":
+			return v == a.value
+		case "~=":
+			for _, word := range strings.Fields(v) {
+				if word == a.value {
+					return true
+				}
+			}
+			return false
+		case "|=":
+			return v == a.value || strings.HasPrefix(v, a.value+"-")
+		case "^=":
+			return a.value != "" && strings.HasPrefix(v, a.value)
+		case "$=":
+			return a.value != "" && strings.HasSuffix(v, a.value)
+		case "*=":
+			return a.value != "" && strings.Contains(v, a.value)
+		}
+	}
+	return false
+}
+
+func (p *cssPseudo) match(root, el, parent *Element, index int) bool {
+	switch p.name {
+	case "empty":
+		return len(el.Children) == 0 && !el.hasContent()
+	case "not":
+		return !p.not.match(root, el, parent, index)
+	}
+	if parent == nil {
+		return false
+	}
+	switch p.name {
+	case "first-child":
+		return index == 0
+	case "last-child":
+		return index == len(parent.Children)-1
+	case "only-child":
+		return len(parent.Children) == 1
+	}
+	// nth-child: is index+1 = a*n+b for some n >= 0?
+	pos := index + 1
+	if p.a == 0 {
+		return pos == p.b
+	}
+	n := (pos - p.b) / p.a
+	return n >= 0 && (pos-p.b)%p.a == 0
+}
+
+func parseCSSComplex(s string) (cssComplex, error) {
+	var c cssComplex
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if s == "" {
+			return c, fmt.Errorf("missing selector")
+		}
+		compound, rest, err := parseCSSCompound(s)
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, compound)
+		trimmed := strings.TrimLeft(rest, " \t\n")
+		if trimmed == "" {
+			return c, nil
+		}
+		switch comb := trimmed[0]; comb {
+		case '>', '+', '~':
+			c.combinators = append(c.combinators, comb)
+			s = trimmed[1:]
+		default:
+			if trimmed == rest {
+				return c, fmt.Errorf("unexpected %q", rest)
+			}
+			c.combinators = append(c.combinators, ' ')
+			s = trimmed
+		}
+	}
+}
+
+func parseCSSCompound(s string) (cssCompound, string, error) {
+	cc := cssCompound{anySpace: true, local: "*"}
+	empty := true
+	// type selector, with optional namespace
+	if n := cssIdentLen(s, true); n > 0 || strings.HasPrefix(s, "|") {
+		empty = false
+		name := s[:n]
+		s = s[n:]
+		if strings.HasPrefix(s, "|") && !strings.HasPrefix(s, "|=") {
+			s = s[1:]
+			switch name {
+			case "*":
+			case "":
+				cc.anySpace = false
+			default:
+				cc.prefix, cc.anySpace = name, false
+			}
+			if n = cssIdentLen(s, true); n == 0 {
+				return cc, s, fmt.Errorf("missing element name after %s|", name)
+			}
+			name = s[:n]
+			s = s[n:]
+		}
+		cc.local = name
+	}
+	for s != "" {
+		var err error
+		switch s[0] {
+		case '#', '.':
+			n := cssIdentLen(s[1:], false)
+			if n == 0 {
+				return cc, s, fmt.Errorf("missing name after %c", s[0])
+			}
+			a := cssAttr{local: "id", op: "=", value: s[1 : 1+n]}
+			if s[0] == '.' {
+				a.local, a.op = "class", "~="
+			}
+			cc.attrs = append(cc.attrs, a)
+			s = s[1+n:]
+		case '[':
+			end := predEnd(s)
+			if end < 0 {
+				return cc, s, fmt.Errorf("unterminated attribute selector")
+			}
+			var a cssAttr
+			if a, err = parseCSSAttr(s[1:end]); err != nil {
+				return cc, s, err
+			}
+			cc.attrs = append(cc.attrs, a)
+			s = s[end+1:]
+		case ':':
+			var p cssPseudo
+			if p, s, err = parseCSSPseudo(s[1:]); err != nil {
+				return cc, s, err
+			}
+			cc.pseudos = append(cc.pseudos, p)
+		default:
+			if empty {
+				return cc, s, fmt.Errorf("unexpected %q", s)
+			}
+			return cc, s, nil
+		}
+		empty = false
+	}
+	return cc, s, nil
+}
+
+func parseCSSAttr(s string) (cssAttr, error) {
+	var a cssAttr
+	s = strings.TrimSpace(s)
+	n := strings.IndexAny(s, "=~|^$*")
+	if n < 0 {
+		n = len(s)
+	}
+	// a namespace prefix is separated by a "|" not followed by "="
+	if n < len(s) && s[n] == '|' && !strings.HasPrefix(s[n:], "|=") {
+		a.prefix = s[:n]
+		s = s[n+1:]
+		if n = strings.IndexAny(s, "=~|^$*"); n < 0 {
+			n = len(s)
+		}
+	} else if strings.HasPrefix(s, "*|") {
+		a.prefix, s = "*", s[2:]
+		if n = strings.IndexAny(s, "=~|^$*"); n < 0 {
+			n = len(s)
+		}
+	}
+	a.local = strings.TrimSpace(s[:n])
+	if a.local == "" {
+		return a, fmt.Errorf("missing attribute name")
+	}
+	s = s[n:]
+	if s == "" {
+		return a, nil
+	}
+	if s[0] == '=' {
+		a.op, s = "=", s[1:]
+	} else if len(s) > 1 && s[1] == '=' {
+		a.op, s = s[:2], s[2:]
+	} else {
+		return a, fmt.Errorf("invalid attribute operator in %q", s)
+	}
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		a.value = s[1 : len(s)-1]
+	} else if n := cssIdentLen(s, false); n == len(s) {
+		a.value = s
+	} else {
+		return a, fmt.Errorf("invalid attribute value %q", s)
+	}
+	return a, nil
+}
+
+func parseCSSPseudo(s string) (cssPseudo, string, error) {
+	var p cssPseudo
+	n := cssIdentLen(s, false)
+	p.name, s = s[:n], s[n:]
+	switch p.name {
+	case "first-child", "last-child", "only-child", "empty":
+		return p, s, nil
+	case "nth-child", "not":
+	default:
+		return p, s, fmt.Errorf("unsupported pseudo-class :%s", p.name)
+	}
+	if !strings.HasPrefix(s, "(") {
+		return p, s, fmt.Errorf(":%s requires an argument", p.name)
+	}
+	end := 1
+	for depth := 1; depth > 0; end++ {
+		if end == len(s) {
+			return p, s, fmt.Errorf("unterminated :%s", p.name)
+		}
+		switch s[end] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	arg := strings.TrimSpace(s[1 : end-1])
+	s = s[end:]
+	if p.name == "not" {
+		cc, rest, err := parseCSSCompound(arg)
+		if err != nil || rest != "" {
+			return p, s, fmt.Errorf("invalid argument to :not: %q", arg)
+		}
+		p.not = &cc
+		return p, s, nil
+	}
+	var err error
+	p.a, p.b, err = parseNth(arg)
+	return p, s, err
+}
+
+// parseNth parses the an+b argument of :nth-child.
+func parseNth(arg string) (a, b int, err error) {
+	arg = strings.ToLower(strings.ReplaceAll(arg, " ", ""))
+	switch arg {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	i := strings.IndexByte(arg, 'n')
+	if i < 0 {
+		b, err = strconv.Atoi(arg)
+		return 0, b, err
+	}
+	switch coef := arg[:i]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(coef); err != nil {
+			return 0, 0, fmt.Errorf("invalid :nth-child argument %q", arg)
+		}
+	}
+	if rest := arg[i+1:]; rest != "" {
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, fmt.Errorf("invalid :nth-child argument %q", arg)
+		}
+	}
+	return a, b, nil
+}
+
+// cssIdentLen returns the length of the identifier at the start of
+// s. If star is true, a lone "*" is accepted.
+func cssIdentLen(s string, star bool) int {
+	if star && strings.HasPrefix(s, "*") {
+		return 1
+	}
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if c != '-' && c != '_' && !('0' <= c && c <= '9') && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && c < 0x80 {
+			break
+		}
+		n++
+	}
+	return n
+}
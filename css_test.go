@@ -0,0 +1,68 @@
+package xmltree
+
+import (
+	"strings"
+	"testing"
+)
+
+const cssDoc = `<html xmlns:svg="urn:svg">
+	<nav id="top" class="main menu">
+		<ul><li class="hidden"><a href="http://x">1</a></li><li><a href="https://y">2</a></li><li lang="en-US"><a>3</a></li></ul>
+	</nav>
+	<p>one</p><div/><p>two</p><p class="x"/>
+	<svg:svg><svg:rect width="1"/></svg:svg>
+	<rect/>
+</html>`
+
+func cssNames(els []*Element) string {
+	var names []string
+	for _, el := range els {
+		name := el.Name.Local
+		if s := string(el.Content); len(el.Children) == 0 && s != "" {
+			name += "=" + s
+		}
+		if id := el.Attr("", "id"); id != "" {
+			name += "#" + id
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestSelect(t *testing.T) {
+	root := parseDoc(t, []byte(cssDoc))
+	tests := []struct {
+		sel, want string
+	}{
+		{"p", "p=one p=two p"},
+		{"#top", "nav#top"},
+		{"nav.menu li:not(.hidden) > a", "a=2 a=3"},
+		{"a[href^='https:']", "a=2"},
+		{"a[href*=x]", "a=1"},
+		{`li[lang|="en"] a`, "a=3"},
+		{"ul > li:first-child a, ul li:last-child a", "a=1 a=3"},
+		{"li:nth-child(2n+1)", "li li"},
+		{"li:nth-child(2) > *", "a=2"},
+		{"div + p", "p=two"},
+		{"div ~ p", "p=two p"},
+		{"p:empty", "p"},
+		{"svg|rect", "rect"},
+		{"svg|*", "svg rect"},
+		{"|rect", "rect"},
+		{"*|rect", "rect rect"},
+		{"html > p:only-child", ""},
+		{"html nav", "nav#top"},
+		{"[width]", "rect"},
+	}
+	for _, tt := range tests {
+		got := cssNames(root.Select(tt.sel))
+		if got != tt.want {
+			t.Errorf("Select(%q) = %q, want %q", tt.sel, got, tt.want)
+		}
+	}
+	for _, sel := range []string{"", "a >", "a[", ":hover", "li:nth-child(x)", "a,,b", "> a"} {
+		if _, err := CompileCSS(sel); err == nil {
+			t.Errorf("CompileCSS(%q) succeeded", sel)
+		}
+	}
+}
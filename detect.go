@@ -0,0 +1,209 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"unicode/utf16"
+)
+
+// A Format is the kind of document reported by Detect.
+type Format int
+
+const (
+	FormatUnknown Format = iota // not markup, or not recognized
+	FormatXML                   // an XML document
+	FormatHTML                  // an HTML document
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatXML:
+		return "xml"
+	case FormatHTML:
+		return "html"
+	}
+	return "unknown"
+}
+
+// A Detection describes the result of examining the start of a
+// document with Detect.
+type Detection struct {
+	Format Format
+	// The character encoding of the document, as given by a byte
+	// order mark or XML declaration, in lower case. If neither is
+	// present, Encoding is "utf-8".
+	Encoding string
+	// The name of the root element, if one could be found. The
+	// Space field is set if the root start tag declares the
+	// namespace of its own name.
+	Root xml.Name
+}
+
+// sniffLen is the number of bytes examined by Detect.
+const sniffLen = 1024
+
+// htmlRoots are common tags of HTML documents that lack a doctype
+// or <html> element.
+var htmlRoots = map[string]bool{
+	"html": true, "head": true, "body": true, "div": true, "p": true,
+	"table": true, "script": true, "style": true, "title": true,
+	"meta": true, "link": true, "a": true, "span": true, "br": true,
+}
+
+const xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+
+// Detect examines the first kilobyte of data and reports whether it
+// looks like an XML or HTML document, along with its character
+// encoding and root element name. The rest of data is ignored, so the
+// root of a document with a long prolog may not be reported. Detect
+// is a heuristic, intended for routing input before it is parsed; a
+// document that Detect reports as XML may still fail to Parse.
+func Detect(data []byte) Detection {
+	var d Detection
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		d.Encoding = "utf-8"
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		d.Encoding = "utf-16be"
+		data = decodeUTF16(data[2:], true)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		d.Encoding = "utf-16le"
+		data = decodeUTF16(data[2:], false)
+	}
+	data = bytes.TrimLeft(data, " \t\r\n")
+
+	hasDecl := false
+	if bytes.HasPrefix(data, []byte("<?xml")) && len(data) > 5 && isSpace(data[5]) {
+		hasDecl = true
+		end := bytes.Index(data, []byte("?>"))
+		if end < 0 {
+			end = len(data)
+		}
+		if enc := declAttr(string(data[5:end]), "encoding"); enc != "" && d.Encoding == "" {
+			d.Encoding = strings.ToLower(enc)
+		}
+	}
+	if d.Encoding == "" {
+		d.Encoding = "utf-8"
+	}
+
+	doctypeHTML := false
+	for len(data) > 0 {
+		data = bytes.TrimLeft(data, " \t\r\n")
+		switch {
+		case bytes.HasPrefix(data, []byte("<?")):
+			data = skipPast(data, "?>")
+		case bytes.HasPrefix(data, []byte("<!--")):
+			data = skipPast(data, "-->")
+		case bytes.HasPrefix(data, []byte("<!")):
+			if len(data) >= 14 && strings.EqualFold(string(data[:9]), "<!DOCTYPE") &&
+				strings.EqualFold(strings.TrimSpace(string(data[9:14])), "html") {
+				doctypeHTML = true
+			}
+			data = skipPast(data, ">")
+		case len(data) > 1 && data[0] == '<' && isNameStart(data[1]):
+			d.Root = sniffRoot(data)
+			switch {
+			case doctypeHTML && d.Root.Space != xhtmlNamespace:
+				d.Format = FormatHTML
+			case hasDecl || d.Root.Space != "":
+				d.Format = FormatXML
+			case htmlRoots[strings.ToLower(d.Root.Local)]:
+				d.Format = FormatHTML
+			default:
+				d.Format = FormatXML
+			}
+			return d
+		default:
+			if doctypeHTML {
+				d.Format = FormatHTML
+			} else if hasDecl {
+				d.Format = FormatXML
+			}
+			return d
+		}
+	}
+	if doctypeHTML {
+		d.Format = FormatHTML
+	} else if hasDecl {
+		d.Format = FormatXML
+	}
+	return d
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+func skipPast(data []byte, end string) []byte {
+	n := bytes.Index(data, []byte(end))
+	if n < 0 {
+		return nil
+	}
+	return data[n+len(end):]
+}
+
+// declAttr extracts a pseudo-attribute from the body of an XML
+// declaration.
+func declAttr(decl, name string) string {
+	i := strings.Index(decl, name)
+	if i < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(decl[i+len(name):], " \t\r\n")
+	if !strings.HasPrefix(rest, "=") {
+		return ""
+	}
+	rest = strings.TrimLeft(rest[1:], " \t\r\n")
+	if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+		return ""
+	}
+	end := strings.IndexByte(rest[1:], rest[0])
+	if end < 0 {
+		return ""
+	}
+	return rest[1 : end+1]
+}
+
+// sniffRoot extracts the name of the start tag at the beginning of
+// data, resolving its namespace if the tag declares it.
+func sniffRoot(data []byte) xml.Name {
+	end := bytes.IndexByte(data, '>')
+	if end > 0 {
+		d := xml.NewDecoder(bytes.NewReader(data[:end+1]))
+		d.Strict = false
+		if tok, err := d.Token(); err == nil {
+			if start, ok := tok.(xml.StartElement); ok {
+				return start.Name
+			}
+		}
+	}
+	n := 1
+	for n < len(data) && !isNameDelim(data[n]) {
+		n++
+	}
+	return xml.Name{Local: string(data[1:n])}
+}
+
+// decodeUTF16 converts UTF-16 text to UTF-8, ignoring a trailing
+// odd byte.
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			u[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(u)))
+}
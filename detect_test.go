@@ -0,0 +1,33 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Detection
+	}{
+		{`<?xml version="1.0" encoding="ISO-8859-1"?><a/>`,
+			Detection{FormatXML, "iso-8859-1", xml.Name{Local: "a"}}},
+		{"\xEF\xBB\xBF<!-- c --><feed xmlns=\"http://www.w3.org/2005/Atom\">",
+			Detection{FormatXML, "utf-8", xml.Name{Space: "http://www.w3.org/2005/Atom", Local: "feed"}}},
+		{`<!DOCTYPE html><html lang=en><body>`,
+			Detection{FormatHTML, "utf-8", xml.Name{Local: "html"}}},
+		{`  <div class="x">hi</div>`,
+			Detection{FormatHTML, "utf-8", xml.Name{Local: "div"}}},
+		{`<html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`,
+			Detection{FormatXML, "utf-8", xml.Name{Space: xhtmlNamespace, Local: "html"}}},
+		{`{"json": true}`,
+			Detection{FormatUnknown, "utf-8", xml.Name{}}},
+		{"\xFF\xFE<\x00r\x00/\x00>\x00",
+			Detection{FormatXML, "utf-16le", xml.Name{Local: "r"}}},
+	}
+	for _, tt := range tests {
+		if got := Detect([]byte(tt.input)); got != tt.want {
+			t.Errorf("Detect(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// A Document is an alternative representation of an XML tree, in
+// which elements refer to their children by pointer. Because the
+// Children of an Element are stored by value, appending to them may
+// move existing children in memory, invalidating any *Element that
+// refers to them. The *Node values in a Document, on the other hand,
+// remain valid for as long as the Document is in use, no matter how
+// it is modified.
+//
+// Nodes are allocated by the Document in chunks, to reduce the
+// overhead of allocating each element separately.
+type Document struct {
+	Root *Node
+	free []Node
+}
+
+// A Node is a single element in a Document. The fields of a Node
+// have the same meaning as those of an Element.
+type Node struct {
+	xml.StartElement
+	Scope
+	Content  []byte
+	CDATA    bool
+	Children []*Node
+	Misc     []Misc
+
+	parent *Node
+	spill  *spilled
+	xmlns  []xml.Attr
+	prefix string
+
+	attrPrefixes []xml.Attr
+}
+
+// number of Nodes allocated at once by a Document
+const nodeChunk = 64
+
+// ParseDocument is like Parse, but returns a Document.
+func ParseDocument(doc []byte, opts ...ParseOption) (*Document, error) {
+	root, err := Parse(doc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewDocument(root), nil
+}
+
+// NewDocument creates a Document from a tree of Elements. The
+// attributes, scope and content of the Elements are shared with
+// the Document, and should not be modified.
+func NewDocument(root *Element) *Document {
+	d := new(Document)
+	d.Root = d.fromElement(root, nil, 0)
+	return d
+}
+
+func (d *Document) fromElement(el *Element, parent *Node, depth int) *Node {
+	n := d.NewNode(el.StartElement)
+	n.Scope = el.Scope
+	n.Content = el.Content
+	n.CDATA = el.CDATA
+	n.Misc = el.Misc
+	n.spill = el.spill
+	n.xmlns = el.xmlns
+	n.prefix = el.prefix
+	n.attrPrefixes = el.attrPrefixes
+	n.parent = parent
+	if depth > recursionLimit {
+		return n
+	}
+	n.Children = make([]*Node, len(el.Children))
+	for i := range el.Children {
+		n.Children[i] = d.fromElement(&el.Children[i], n, depth+1)
+	}
+	return n
+}
+
+// NewNode allocates a new Node with the given start tag, that may be
+// added to the Document with AppendChild or InsertChild.
+func (d *Document) NewNode(start xml.StartElement) *Node {
+	if len(d.free) == 0 {
+		d.free = make([]Node, nodeChunk)
+	}
+	n := &d.free[0]
+	d.free = d.free[1:]
+	n.StartElement = start
+	return n
+}
+
+// Element converts the Document to a tree of Elements, which can be
+// searched and encoded with the functions in this package. The
+// returned tree is a copy; modifying it does not modify the Document.
+func (d *Document) Element() *Element {
+	if d.Root == nil {
+		return nil
+	}
+	return d.Root.Element()
+}
+
+// Encode writes the XML encoding of the Document to w.
+func (d *Document) Encode(w io.Writer, opts ...EncodeOption) error {
+	el := d.Element()
+	defer ReleaseElement(el)
+	return Encode(w, el, opts...)
+}
+
+// WriteTo writes the XML encoding of the Document to w, implementing
+// the io.WriterTo interface.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	el := d.Element()
+	defer ReleaseElement(el)
+	return el.WriteTo(w)
+}
+
+// ReadFrom reads an XML document from r until EOF and replaces the
+// contents of d with it, implementing the io.ReaderFrom interface.
+// It returns the number of bytes read. If the document cannot be
+// parsed, d is left unchanged.
+//
+//	var doc xmltree.Document
+//	if _, err := doc.ReadFrom(conn); err != nil {
+//		// ...
+//	}
+func (d *Document) ReadFrom(r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	parsed, err := ParseDocument(data)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	*d = *parsed
+	return int64(len(data)), nil
+}
+
+// Element converts the Node and its descendants to a tree of Elements.
+// The root of the tree comes from AcquireElement, and may be passed to
+// ReleaseElement once the tree is no longer needed; the rest of the
+// tree is not pooled.
+func (n *Node) Element() *Element {
+	el := AcquireElement()
+	n.toElement(el, 0)
+	return el
+}
+
+// toElement fills in el, reusing the storage of its attributes and
+// children if it has any, as an Element from AcquireElement may.
+func (n *Node) toElement(el *Element, depth int) {
+	el.StartElement.Name = n.Name
+	if n.StartElement.Attr != nil {
+		el.StartElement.Attr = append(el.StartElement.Attr[:0], n.StartElement.Attr...)
+	}
+	el.Scope = n.Scope
+	el.Content = n.Content
+	el.CDATA = n.CDATA
+	el.Misc = n.Misc
+	el.spill = n.spill
+	el.xmlns = n.xmlns
+	el.prefix = n.prefix
+	el.attrPrefixes = n.attrPrefixes
+	if depth > recursionLimit || len(n.Children) == 0 {
+		return
+	}
+	if cap(el.Children) >= len(n.Children) {
+		el.Children = el.Children[:len(n.Children)]
+	} else {
+		el.Children = make([]Element, len(n.Children))
+	}
+	for i, c := range n.Children {
+		c.toElement(&el.Children[i], depth+1)
+		el.Children[i].parent, el.Children[i].pos = el, i
+	}
+}
+
+// Parent returns the Node that contains n, or nil if n is the root
+// of its Document or has not been added to it. Parent is maintained
+// by ParseDocument, NewDocument, AppendChild, InsertChild and
+// RemoveChild; it is not updated if the Children field is modified
+// directly.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// AppendChild adds child as the last child of n.
+func (n *Node) AppendChild(child *Node) {
+	child.parent = n
+	n.Children = append(n.Children, child)
+}
+
+// InsertChild adds child to n at index i of its Children. InsertChild
+// panics if i is out of range.
+func (n *Node) InsertChild(i int, child *Node) {
+	child.parent = n
+	n.Children = append(n.Children, nil)
+	copy(n.Children[i+1:], n.Children[i:])
+	n.Children[i] = child
+}
+
+// RemoveChild removes child from the children of n, reporting whether
+// it was found. The removed child becomes the root of its own tree.
+func (n *Node) RemoveChild(child *Node) bool {
+	for i, c := range n.Children {
+		if c == child {
+			last := len(n.Children) - 1
+			copy(n.Children[i:], n.Children[i+1:])
+			// Drop the reference left in the slice's spare capacity.
+			n.Children[last] = nil
+			n.Children = n.Children[:last]
+			child.parent = nil
+			return true
+		}
+	}
+	return false
+}
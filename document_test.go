@@ -0,0 +1,76 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+func TestDocumentStableReferences(t *testing.T) {
+	doc, err := ParseDocument([]byte(`<list><item>first</item></list>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := doc.Root.Children[0]
+	for i := 0; i < 1000; i++ {
+		n := doc.NewNode(xml.StartElement{Name: xml.Name{Local: "item"}})
+		doc.Root.AppendChild(n)
+	}
+	doc.Root.InsertChild(0, doc.NewNode(xml.StartElement{Name: xml.Name{Local: "head"}}))
+	if string(first.Content) != "first" || first.Parent() != doc.Root {
+		t.Errorf("reference to first item no longer valid")
+	}
+	if doc.Root.Children[1] != first {
+		t.Errorf("first item moved to unexpected position")
+	}
+	last := doc.Root.Children[len(doc.Root.Children)-1]
+	if !doc.Root.RemoveChild(first) || first.Parent() != nil || first.parent != nil {
+		t.Errorf("RemoveChild failed")
+	}
+	if spare := doc.Root.Children[:len(doc.Root.Children)+1]; spare[len(spare)-1] != nil {
+		t.Errorf("removed slot still refers to a node")
+	}
+	if doc.Root.Children[len(doc.Root.Children)-1] != last {
+		t.Errorf("last child moved")
+	}
+	if n := len(doc.Element().Children); n != 1001 {
+		t.Errorf("expected 1001 children, got %d", n)
+	}
+}
+
+func TestDocumentRoundTrip(t *testing.T) {
+	root := parseDoc(t, exampleDoc)
+	doc := NewDocument(root)
+	if !Equal(doc.Element(), parseDoc(t, exampleDoc)) {
+		t.Errorf("document does not match source tree:\n%s", Marshal(doc.Element()))
+	}
+}
+
+func TestDocumentReadWrite(t *testing.T) {
+	var doc Document
+	n, err := doc.ReadFrom(bytes.NewReader(exampleDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(exampleDoc)) {
+		t.Errorf("ReadFrom read %d bytes, want %d", n, len(exampleDoc))
+	}
+	var buf bytes.Buffer
+	n, err = doc.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+	if !Equal(parseDoc(t, buf.Bytes()), parseDoc(t, exampleDoc)) {
+		t.Errorf("document changed after round trip:\n%s", buf.Bytes())
+	}
+}
+
+var (
+	_ io.WriterTo   = (*Document)(nil)
+	_ io.ReaderFrom = (*Document)(nil)
+	_ io.WriterTo   = (*Element)(nil)
+)
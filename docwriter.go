@@ -0,0 +1,126 @@
+package xmltree
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// A DocumentWriter writes a document one element at a time, for
+// export jobs that produce more records than can be held in memory.
+// It is the counterpart of ParseStream. The root element is opened
+// by NewDocumentWriter, each call to AppendElement adds a child to it,
+// and Close ends the document.
+//
+//	dw, err := xmltree.NewDocumentWriter(f, envelope)
+//	if err != nil {
+//		// ...
+//	}
+//	for _, rec := range records {
+//		if err := dw.AppendElement(rec); err != nil {
+//			// ...
+//		}
+//	}
+//	err = dw.Close()
+type DocumentWriter struct {
+	bw      *bufio.Writer
+	e       encoder
+	root    *Element
+	visited map[*Element]struct{}
+	flush   bool // flush after each element
+	closed  bool
+}
+
+// NewDocumentWriter writes an XML declaration and the start tag of
+// root to w, followed by any children root already has, and returns
+// a DocumentWriter that appends elements to root. Each element is
+// written to w before AppendElement returns. Options are applied as
+// for Encode, to root and to each appended element.
+func NewDocumentWriter(w io.Writer, root *Element, opts ...EncodeOption) (*DocumentWriter, error) {
+	d, err := newDocumentWriter(bufio.NewWriter(w), root, opts)
+	if err != nil {
+		return nil, err
+	}
+	d.flush = true
+	return d, d.bw.Flush()
+}
+
+func newDocumentWriter(bw *bufio.Writer, root *Element, opts []EncodeOption) (*DocumentWriter, error) {
+	d := &DocumentWriter{bw: bw, e: encoder{w: bw}}
+	for _, opt := range opts {
+		opt(&d.e)
+	}
+	e := &d.e
+	root, err := e.prepare(root)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, errors.New("xmltree: root element dropped by WithFilter")
+	}
+	if e.progress != nil {
+		e.counter = &progressWriter{writer: e.w}
+		e.w = e.counter
+	}
+	d.root = root
+	d.visited = map[*Element]struct{}{root: {}}
+
+	// The start tag is written in full even if root has no
+	// children, as elements may follow.
+	e.w.WriteString(xml.Header)
+	e.encodeTagStart(root, nil, e.scope(root, nil), 0)
+	e.w.WriteByte('>')
+	if e.pretty {
+		e.w.WriteByte('\n')
+	}
+	for i := range root.Children {
+		if child := e.visible(&root.Children[i]); child != nil {
+			if err := e.encode(child, root, d.visited); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// AppendElement writes el as the next child of the root element.
+func (d *DocumentWriter) AppendElement(el *Element) error {
+	if d.closed {
+		return errors.New("xmltree: AppendElement on closed DocumentWriter")
+	}
+	e := &d.e
+	el, err := e.prepare(el)
+	if err != nil || el == nil {
+		return err
+	}
+	if err := e.encode(el, d.root, d.visited); err != nil {
+		return err
+	}
+	if e.progress != nil {
+		if err := e.progress.update(e.counter.n); err != nil {
+			return err
+		}
+	}
+	if d.flush {
+		return d.bw.Flush()
+	}
+	return nil
+}
+
+// Close writes the end tag of the root element and flushes the
+// output. It does not close the underlying io.Writer.
+func (d *DocumentWriter) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	e := &d.e
+	e.w.WriteString("</")
+	e.w.WriteString(e.elementName(d.root, e.tagRenames(d.root)))
+	e.w.WriteByte('>')
+	if err := d.bw.Flush(); err != nil {
+		return err
+	}
+	return e.finish()
+}
@@ -0,0 +1,40 @@
+package xmltree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDocumentWriter(t *testing.T) {
+	var buf bytes.Buffer
+	dw, err := NewDocumentWriter(&buf, MustParse([]byte(`<feed xmlns="urn:f" xmlns:x="urn:x"/>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), `<feed xmlns="urn:f" xmlns:x="urn:x">`) {
+		t.Errorf("start tag not written: %s", buf.String())
+	}
+	for _, rec := range []string{`<entry xmlns="urn:f">1</entry>`, `<x:entry xmlns:x="urn:x">2</x:entry>`} {
+		before := buf.Len()
+		if err := dw.AppendElement(MustParse([]byte(rec))); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() == before {
+			t.Error("element not flushed")
+		}
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `<feed xmlns="urn:f" xmlns:x="urn:x"><entry>1</entry><x:entry xmlns:x="urn:x">2</x:entry></feed>`
+	if got := buf.String(); !strings.HasSuffix(got, "?>\n"+want) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if _, err := Parse(buf.Bytes()); err != nil {
+		t.Errorf("output does not parse: %v", err)
+	}
+	if err := dw.AppendElement(MustParse([]byte(`<entry/>`))); err == nil {
+		t.Error("AppendElement after Close succeeded")
+	}
+}
@@ -0,0 +1,229 @@
+// Package epub reads and rewrites EPUB publications, exposing the
+// package document (OPF) and navigation files as xmltree Elements.
+//
+// Only the parts of the package document touched by a helper are
+// modified; other metadata, including vendor extensions, is written
+// back as it was read.
+package epub // import "github.com/mdejong/xmltree/epub"
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/mdejong/xmltree"
+	"github.com/mdejong/xmltree/xmlzip"
+)
+
+const (
+	// MimeType is the content of the mimetype member of every EPUB.
+	MimeType = "application/epub+zip"
+	// ContainerFile names the package documents in a publication.
+	ContainerFile = "META-INF/container.xml"
+	// DCNamespace is the Dublin Core namespace used for metadata.
+	DCNamespace = "http://purl.org/dc/elements/1.1/"
+)
+
+// ErrNoPackage is returned by Open when the container does not name
+// a package document.
+var ErrNoPackage = errors.New("epub: container has no rootfile")
+
+// A Book is an EPUB publication.
+type Book struct {
+	*xmlzip.Archive
+	opf string
+}
+
+// Open reads an EPUB publication from r, which is size bytes long.
+func Open(r io.ReaderAt, size int64) (*Book, error) {
+	a, err := xmlzip.Open(r, size)
+	if err != nil {
+		return nil, err
+	}
+	container, err := a.Tree(ContainerFile)
+	if err != nil {
+		return nil, err
+	}
+	rootfiles := container.Search("", "rootfile")
+	if len(rootfiles) == 0 || rootfiles[0].Attr("", "full-path") == "" {
+		return nil, ErrNoPackage
+	}
+	return &Book{Archive: a, opf: rootfiles[0].Attr("", "full-path")}, nil
+}
+
+// PackagePath returns the name of the package document within the
+// archive, such as "OEBPS/content.opf".
+func (b *Book) PackagePath() string {
+	return b.opf
+}
+
+// Package returns the package document.
+func (b *Book) Package() (*xmltree.Element, error) {
+	return b.Tree(b.opf)
+}
+
+// child returns the first child of el with the given local name.
+func child(el *xmltree.Element, local string) *xmltree.Element {
+	for i := range el.Children {
+		if el.Children[i].Name.Local == local {
+			return &el.Children[i]
+		}
+	}
+	return nil
+}
+
+func (b *Book) section(local string) (*xmltree.Element, error) {
+	opf, err := b.Package()
+	if err != nil {
+		return nil, err
+	}
+	if el := child(opf, local); el != nil {
+		return el, nil
+	}
+	return nil, fmt.Errorf("epub: %s: no %s element", b.opf, local)
+}
+
+// Metadata returns the content of the first Dublin Core element with
+// the given local name, such as "title" or "language", and whether
+// it was found.
+func (b *Book) Metadata(name string) (string, bool) {
+	meta, err := b.section("metadata")
+	if err != nil {
+		return "", false
+	}
+	for _, el := range meta.Children {
+		if el.Name.Space == DCNamespace && el.Name.Local == name {
+			return string(el.Content), true
+		}
+	}
+	return "", false
+}
+
+// SetMetadata replaces the content of the first Dublin Core element
+// with the given local name, adding one to the end of the metadata
+// if there is none. The Dublin Core namespace must be declared in
+// the package document.
+func (b *Book) SetMetadata(name, value string) error {
+	meta, err := b.section("metadata")
+	if err != nil {
+		return err
+	}
+	for i := range meta.Children {
+		if el := &meta.Children[i]; el.Name.Space == DCNamespace && el.Name.Local == name {
+			el.Content = []byte(value)
+			return nil
+		}
+	}
+	el := xmltree.Element{
+		StartElement: xml.StartElement{Name: xml.Name{Space: DCNamespace, Local: name}},
+		Scope:        meta.Scope,
+		Content:      []byte(value),
+	}
+	if el.Prefix(el.Name) == name {
+		return fmt.Errorf("epub: %s: Dublin Core namespace is not declared", b.opf)
+	}
+	meta.Children = append(meta.Children, el)
+	return nil
+}
+
+// Spine returns the idrefs of the spine items, in reading order.
+func (b *Book) Spine() ([]string, error) {
+	spine, err := b.section("spine")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, ref := range spine.Children {
+		if ref.Name.Local == "itemref" {
+			ids = append(ids, ref.Attr("", "idref"))
+		}
+	}
+	return ids, nil
+}
+
+// SetSpine reorders the spine to follow ids. Existing itemrefs keep
+// their other attributes, such as linear="no"; ids not already in the
+// spine are added if the manifest has an item with that id. Spine
+// items not listed in ids are removed.
+func (b *Book) SetSpine(ids []string) error {
+	spine, err := b.section("spine")
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]xmltree.Element)
+	for _, ref := range spine.Children {
+		if ref.Name.Local == "itemref" {
+			existing[ref.Attr("", "idref")] = ref
+		}
+	}
+	var refs []xmltree.Element
+	for _, id := range ids {
+		ref, ok := existing[id]
+		if !ok {
+			if _, err := b.manifestItem(id); err != nil {
+				return err
+			}
+			ref = xmltree.Element{
+				StartElement: xml.StartElement{Name: xml.Name{Space: spine.Name.Space, Local: "itemref"}},
+				Scope:        spine.Scope,
+			}
+			ref.SetAttr("", "idref", id)
+		}
+		refs = append(refs, ref)
+	}
+	spine.Children = refs
+	return nil
+}
+
+func (b *Book) manifestItem(id string) (*xmltree.Element, error) {
+	manifest, err := b.section("manifest")
+	if err != nil {
+		return nil, err
+	}
+	for i := range manifest.Children {
+		if item := &manifest.Children[i]; item.Attr("", "id") == id {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("epub: %s: no manifest item with id %q", b.opf, id)
+}
+
+// ItemPath returns the archive member name of the manifest item with
+// the given id. Manifest hrefs are relative to the package document.
+func (b *Book) ItemPath(id string) (string, error) {
+	item, err := b.manifestItem(id)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(b.opf), item.Attr("", "href")), nil
+}
+
+// NCX returns the EPUB 2 navigation control file named by the toc
+// attribute of the spine.
+func (b *Book) NCX() (*xmltree.Element, error) {
+	spine, err := b.section("spine")
+	if err != nil {
+		return nil, err
+	}
+	id := spine.Attr("", "toc")
+	if id == "" {
+		return nil, fmt.Errorf("epub: %s: spine has no toc attribute", b.opf)
+	}
+	name, err := b.ItemPath(id)
+	if err != nil {
+		return nil, err
+	}
+	return b.Tree(name)
+}
+
+// WriteTo writes the publication to w, with the mimetype member first
+// and uncompressed, as the EPUB specification requires.
+func (b *Book) WriteTo(w io.Writer) (int64, error) {
+	b.SetBytes("mimetype", []byte(MimeType))
+	b.File("mimetype").Method = zip.Store
+	b.MoveFirst("mimetype")
+	return b.Archive.WriteTo(w)
+}
@@ -0,0 +1,91 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testOPF = `<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Old</dc:title><meta name="vendor:x" content="keep"/></metadata>
+<manifest><item id="c1" href="c1.xhtml"/><item id="c2" href="c2.xhtml"/><item id="c3" href="c3.xhtml"/><item id="ncx" href="toc.ncx"/></manifest>
+<spine toc="ncx"><itemref idref="c1"/><itemref idref="c2" linear="no"/></spine>
+</package>`
+
+func makeEPUB(t *testing.T) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, data string }{
+		{"META-INF/container.xml", `<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`},
+		{"OEBPS/content.opf", testOPF},
+		{"OEBPS/toc.ncx", `<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/"><navMap/></ncx>`},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestBook(t *testing.T) {
+	r := makeEPUB(t)
+	b, err := Open(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title, ok := b.Metadata("title"); !ok || title != "Old" {
+		t.Errorf("title = %q, %v", title, ok)
+	}
+	if err := b.SetMetadata("title", "New"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetMetadata("language", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetSpine([]string{"c2", "c3", "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetSpine([]string{"nothing"}); err == nil {
+		t.Error("SetSpine accepted an unknown id")
+	}
+	if ncx, err := b.NCX(); err != nil || ncx.Name.Local != "ncx" {
+		t.Errorf("NCX: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Files()[0].Name != "mimetype" {
+		t.Error("mimetype is not the first member")
+	}
+	spine, err := c.Spine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c2", "c3", "c1"}; !reflect.DeepEqual(spine, want) {
+		t.Errorf("spine = %v, want %v", spine, want)
+	}
+	data, err := c.File(c.PackagePath()).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opf := string(data)
+	for _, want := range []string{`<dc:title>New</dc:title>`, `<dc:language>en</dc:language>`, `content="keep"`, `idref="c2" linear="no"`} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document lacks %s:\n%s", want, opf)
+		}
+	}
+}
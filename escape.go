@@ -0,0 +1,87 @@
+package xmltree
+
+import (
+	"bytes"
+	"strings"
+)
+
+// The characters replaced by escapeText, and their entity references,
+// as in vContentMappings.
+const specialChars = `&<>"`
+
+var escapes = [256]string{
+	'&': "&amp;",
+	'<': "&lt;",
+	'>': "&gt;",
+	'"': "&quot;",
+}
+
+// escapeText writes text to w, replacing special characters with
+// XML entity references as xmlEncodeString does, but without
+// creating an intermediate copy. Most text contains no special
+// characters; it is found with bytes.IndexByte, which is vectorized
+// on most platforms, and written in one piece.
+func escapeText(w writer, text []byte) {
+	i := indexSpecial(text)
+	if i < 0 {
+		w.Write(text)
+		return
+	}
+	last := 0
+	for ; i < len(text); i++ {
+		if esc := escapes[text[i]]; esc != "" {
+			w.Write(text[last:i])
+			w.WriteString(esc)
+			last = i + 1
+		}
+	}
+	w.Write(text[last:])
+}
+
+// escapeString is like escapeText, but for strings.
+func escapeString(w writer, s string) {
+	i := indexSpecialString(s)
+	if i < 0 {
+		w.WriteString(s)
+		return
+	}
+	last := 0
+	for ; i < len(s); i++ {
+		if esc := escapes[s[i]]; esc != "" {
+			w.WriteString(s[last:i])
+			w.WriteString(esc)
+			last = i + 1
+		}
+	}
+	w.WriteString(s[last:])
+}
+
+// indexSpecial returns the index of the first special character in
+// text, or -1 if there is none. Each search is limited to the text
+// before the earliest character found so far.
+func indexSpecial(text []byte) int {
+	first := -1
+	for i := 0; i < len(specialChars); i++ {
+		if j := bytes.IndexByte(text, specialChars[i]); j >= 0 {
+			first, text = j, text[:j]
+		}
+	}
+	return first
+}
+
+// indexSpecialString is like indexSpecial, but for strings.
+func indexSpecialString(s string) int {
+	first := -1
+	for i := 0; i < len(specialChars); i++ {
+		if j := strings.IndexByte(s, specialChars[i]); j >= 0 {
+			first, s = j, s[:j]
+		}
+	}
+	return first
+}
+
+// escapeChar returns the entity reference for one of the characters
+// in vContentMappings, or the empty string for any other character.
+func escapeChar(c byte) string {
+	return escapes[c]
+}
@@ -0,0 +1,53 @@
+package xmltree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []string{
+		"",
+		"plain text",
+		"&",
+		`a < b && c > "d"`,
+		"trailing >",
+		"<<>>",
+		"ünïcode & more",
+	}
+	for _, text := range tests {
+		want, _ := xmlEncodeString(text)
+		var buf bytes.Buffer
+		escapeText(&buf, []byte(text))
+		if got := buf.String(); got != want {
+			t.Errorf("escapeText(%q) = %q, want %q", text, got, want)
+		}
+		buf.Reset()
+		escapeString(&buf, text)
+		if got := buf.String(); got != want {
+			t.Errorf("escapeString(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func benchmarkEscapeText(b *testing.B, text []byte) {
+	var buf bytes.Buffer
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		escapeText(&buf, text)
+	}
+}
+
+func BenchmarkEscapeTextClean(b *testing.B) {
+	benchmarkEscapeText(b, []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100)))
+}
+
+func BenchmarkEscapeTextSparse(b *testing.B) {
+	benchmarkEscapeText(b, []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 99)+"A & B"))
+}
+
+func BenchmarkEscapeTextMarkup(b *testing.B) {
+	benchmarkEscapeText(b, []byte(strings.Repeat(`<p class="x">a &amp; b</p>`, 100)))
+}
@@ -0,0 +1,159 @@
+package xmltree
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Materialize may be returned by the StartElement method of an
+// EventHandler to have the element and its descendants parsed into
+// an Element and passed to the handler's Element method, in place of
+// the events for them. It is not returned as an error by
+// ParseEvents.
+var Materialize = errors.New("materialize element")
+
+// An EventHandler receives the events read by ParseEvents. If a
+// method returns an error other than Materialize, ParseEvents stops
+// and returns it.
+//
+// Names are resolved to namespaces, as with the xml.Decoder Token
+// method. The data passed to CharData is only valid until the
+// method returns; it must be copied to be retained.
+type EventHandler interface {
+	StartElement(start xml.StartElement) error
+	EndElement(end xml.EndElement) error
+	CharData(data xml.CharData) error
+
+	// Element is called with an element for which StartElement
+	// returned Materialize. The Element may be retained.
+	Element(el *Element) error
+}
+
+// EventFuncs is an EventHandler that calls the function in the
+// corresponding field for each event. Events for which the field
+// is nil are ignored.
+type EventFuncs struct {
+	OnStartElement func(start xml.StartElement) error
+	OnEndElement   func(end xml.EndElement) error
+	OnCharData     func(data xml.CharData) error
+	OnElement      func(el *Element) error
+}
+
+// StartElement calls f.OnStartElement, if set.
+func (f EventFuncs) StartElement(start xml.StartElement) error {
+	if f.OnStartElement == nil {
+		return nil
+	}
+	return f.OnStartElement(start)
+}
+
+// EndElement calls f.OnEndElement, if set.
+func (f EventFuncs) EndElement(end xml.EndElement) error {
+	if f.OnEndElement == nil {
+		return nil
+	}
+	return f.OnEndElement(end)
+}
+
+// CharData calls f.OnCharData, if set.
+func (f EventFuncs) CharData(data xml.CharData) error {
+	if f.OnCharData == nil {
+		return nil
+	}
+	return f.OnCharData(data)
+}
+
+// Element calls f.OnElement, if set.
+func (f EventFuncs) Element(el *Element) error {
+	if f.OnElement == nil {
+		return nil
+	}
+	return f.OnElement(el)
+}
+
+// ParseEvents reads an XML document from r, calling the methods of
+// handler for each start tag, end tag and run of character data, in
+// document order. Comments, processing instructions and directives
+// are not reported. Only the namespace declarations of the elements
+// that are open are kept in memory, so documents of any size may be
+// skimmed; the handler may ask for the fragments it needs to be
+// built into trees by returning Materialize from StartElement. Such
+// trees are parsed with the given options, in the scope of their
+// ancestors. As with ParseStream, the input must be encoded in
+// UTF-8.
+//
+//	// collect the orders over 1000, ignoring everything else
+//	err := xmltree.ParseEvents(r, xmltree.EventFuncs{
+//		OnStartElement: func(start xml.StartElement) error {
+//			if start.Name.Local == "order" {
+//				return xmltree.Materialize
+//			}
+//			return nil
+//		},
+//		OnElement: func(el *xmltree.Element) error {
+//			if total, _ := strconv.ParseFloat(el.Attr("", "total"), 64); total > 1000 {
+//				orders = append(orders, el)
+//			}
+//			return nil
+//		},
+//	})
+func ParseEvents(r io.Reader, handler EventHandler, opts ...ParseOption) error {
+	rec := recorder{r: bufio.NewReader(r)}
+	d := newStreamDecoder(&rec)
+	var (
+		base int64        // input offset of rec.buf[0]
+		ns   [][]xml.Attr // namespace declarations of open elements
+	)
+	for {
+		n := d.InputOffset() - base
+		rec.buf = append(rec.buf[:0], rec.buf[n:]...)
+		base += n
+
+		begin := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			if len(ns) > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			err := handler.StartElement(tok)
+			if err == Materialize {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				fragment := rec.buf[begin-base : d.InputOffset()-base]
+				el, err := parseInScope(fragment, ns, opts)
+				if err != nil {
+					return err
+				}
+				if el == nil {
+					return fmt.Errorf("xmltree: malformed element at offset %d", begin)
+				}
+				if err := handler.Element(el); err != nil {
+					return err
+				}
+				break
+			} else if err != nil {
+				return err
+			}
+			ns = append(ns, namespaceDecls(tok.Attr))
+		case xml.EndElement:
+			ns = ns[:len(ns)-1]
+			if err := handler.EndElement(tok); err != nil {
+				return err
+			}
+		case xml.CharData:
+			if err := handler.CharData(tok); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseEvents(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<orders xmlns="urn:shop" xmlns:p="urn:pay">
+	<order id="1"><p:total>50</p:total></order>
+	<note>skip <b>me</b></note>
+	<order id="2"><p:total>1500</p:total></order>
+</orders>`
+
+	var events []string
+	var orders []*Element
+	err := ParseEvents(strings.NewReader(input), EventFuncs{
+		OnStartElement: func(start xml.StartElement) error {
+			events = append(events, "<"+start.Name.Local)
+			if start.Name.Local == "order" {
+				return Materialize
+			}
+			return nil
+		},
+		OnEndElement: func(end xml.EndElement) error {
+			events = append(events, "</"+end.Name.Local)
+			return nil
+		},
+		OnCharData: func(data xml.CharData) error {
+			if s := strings.TrimSpace(string(data)); s != "" {
+				events = append(events, s)
+			}
+			return nil
+		},
+		OnElement: func(el *Element) error {
+			orders = append(orders, el)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<orders <order <note skip <b me </b </note <order </orders"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("got events  %s\nwant events %s", got, want)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("got %d orders, want 2", len(orders))
+	}
+	total := orders[1].Child("urn:pay", "total")
+	if total == nil || string(total.Content) != "1500" {
+		t.Errorf("second order has total %v", total)
+	}
+	if got := string(Marshal(orders[0])); got != `<order id="1" xmlns:p="urn:pay" xmlns="urn:shop"><p:total>50</p:total></order>` {
+		t.Errorf("first order marshals as %s", got)
+	}
+}
+
+func TestParseEventsErrors(t *testing.T) {
+	stop := errors.New("stop")
+	err := ParseEvents(strings.NewReader(`<a><b/></a>`), EventFuncs{
+		OnEndElement: func(xml.EndElement) error { return stop },
+	})
+	if err != stop {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if err := ParseEvents(strings.NewReader(`<a><b></a>`), EventFuncs{}); err == nil {
+		t.Error("expected error for mismatched tags")
+	}
+	if err := ParseEvents(strings.NewReader(`<a><b>`), EventFuncs{}); err == nil {
+		t.Error("expected error for truncated document")
+	}
+}
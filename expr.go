@@ -0,0 +1,478 @@
+package xmltree
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An Expr is a compiled expression, for rules and reports that are
+// configured at run time. Expressions are pipelines of stages
+// separated by "|", evaluated from left to right, beginning with the
+// element the expression is evaluated on:
+//
+//	//order                          a selector; see Selector
+//	//order | item                   a selector applied to each element
+//	//order | where(@status='open')  elements for which a condition holds
+//	//order | @id                    the values of an attribute
+//	//order/total | text             the content of each element
+//	//order | count                  the number of elements or values
+//	//order/total | sum              sum, min, max or avg of numeric
+//	                                 content or values
+//	//order | first                  first or last element or value
+//	.                                the element itself
+//
+// A name that is also that of a stage, such as count, may be
+// selected by writing it with a leading slash, as in "/count".
+//
+// Conditions compare the results of two pipelines, or a pipeline and
+// a number or quoted string, with =, !=, <, <=, > or >=, and may be
+// combined with "and" and "or"; "and" binds more tightly. Pipelines
+// within a where stage are evaluated on each element in turn. A
+// comparison holds if it holds for any value on each side, where the
+// values of elements are their content; values are compared as
+// numbers if both are numeric, and as strings otherwise. A pipeline
+// used as a condition holds if its result is not empty, zero or
+// false. An expression that is itself a condition evaluates to a
+// boolean:
+//
+//	//order | where(item | count > 2 and @status != 'void') | count >= 10
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// The kinds of Value produced by an Expr.
+type ValueKind int
+
+const (
+	KindElements ValueKind = iota
+	KindStrings
+	KindNumber
+	KindBool
+)
+
+// A Value is the result of evaluating an Expr. Only the field for
+// its Kind is set.
+type Value struct {
+	Kind     ValueKind
+	Elements []*Element
+	Strings  []string
+	Number   float64
+	Bool     bool
+}
+
+// String formats v: the content of each element, or each string, on
+// a line of its own, or the number or boolean.
+func (v Value) String() string {
+	switch v.Kind {
+	case KindNumber:
+		return strconv.FormatFloat(v.Number, 'g', -1, 64)
+	case KindBool:
+		return strconv.FormatBool(v.Bool)
+	}
+	return strings.Join(v.texts(), "\n")
+}
+
+// texts returns the values compared in conditions.
+func (v Value) texts() []string {
+	switch v.Kind {
+	case KindElements:
+		texts := make([]string, 0, len(v.Elements))
+		for _, el := range v.Elements {
+			content, _ := el.contentBytes()
+			texts = append(texts, string(content))
+		}
+		return texts
+	case KindStrings:
+		return v.Strings
+	case KindNumber:
+		return []string{strconv.FormatFloat(v.Number, 'g', -1, 64)}
+	}
+	return []string{strconv.FormatBool(v.Bool)}
+}
+
+func (v Value) truth() bool {
+	switch v.Kind {
+	case KindElements:
+		return len(v.Elements) > 0
+	case KindStrings:
+		return len(v.Strings) > 0
+	case KindNumber:
+		return v.Number != 0 && !math.IsNaN(v.Number)
+	}
+	return v.Bool
+}
+
+// CompileExpr parses an expression.
+func CompileExpr(src string) (*Expr, error) {
+	root, err := parseCondition(src)
+	if err != nil {
+		return nil, fmt.Errorf("xmltree: invalid expression %q: %v", src, err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// MustCompileExpr is like CompileExpr, but panics if the expression
+// cannot be parsed.
+func MustCompileExpr(src string) *Expr {
+	expr, err := CompileExpr(src)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+func (expr *Expr) String() string {
+	return expr.src
+}
+
+// Eval evaluates the expression on el. Errors are returned for
+// numeric stages given values that are not numbers, and for min,
+// max and avg of nothing.
+func (expr *Expr) Eval(el *Element) (Value, error) {
+	v, err := expr.root.eval(el)
+	if err != nil {
+		return Value{}, fmt.Errorf("xmltree: %s: %v", expr.src, err)
+	}
+	return v, nil
+}
+
+// Eval compiles expr and evaluates it on el. Callers that evaluate
+// the same expression many times should use CompileExpr.
+func Eval(expr string, el *Element) (Value, error) {
+	compiled, err := CompileExpr(expr)
+	if err != nil {
+		return Value{}, err
+	}
+	return compiled.Eval(el)
+}
+
+type exprNode interface {
+	eval(el *Element) (Value, error)
+}
+
+// A logic node combines conditions with "and" or "or".
+type logicNode struct {
+	and   bool
+	terms []exprNode
+}
+
+func (n *logicNode) eval(el *Element) (Value, error) {
+	for _, term := range n.terms {
+		v, err := term.eval(el)
+		if err != nil {
+			return Value{}, err
+		}
+		if v.truth() != n.and {
+			return Value{Kind: KindBool, Bool: !n.and}, nil
+		}
+	}
+	return Value{Kind: KindBool, Bool: n.and}, nil
+}
+
+// A compareNode compares two operands.
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(el *Element) (Value, error) {
+	left, err := n.left.eval(el)
+	if err != nil {
+		return Value{}, err
+	}
+	right, err := n.right.eval(el)
+	if err != nil {
+		return Value{}, err
+	}
+	for _, a := range left.texts() {
+		for _, b := range right.texts() {
+			if compareValues(n.op, a, b) {
+				return Value{Kind: KindBool, Bool: true}, nil
+			}
+		}
+	}
+	return Value{Kind: KindBool}, nil
+}
+
+func compareValues(op, a, b string) bool {
+	c := strings.Compare(a, b)
+	x, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	y, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		default:
+			c = 0
+		}
+	}
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// A literalNode is a number or quoted string in a condition.
+type literalNode struct {
+	v Value
+}
+
+func (n literalNode) eval(*Element) (Value, error) { return n.v, nil }
+
+// A pipeline applies its stages in turn.
+type pipeline []stage
+
+type stage func(v Value) (Value, error)
+
+func (p pipeline) eval(el *Element) (Value, error) {
+	v := Value{Kind: KindElements, Elements: []*Element{el}}
+	for _, s := range p {
+		var err error
+		if v, err = s(v); err != nil {
+			return Value{}, err
+		}
+	}
+	return v, nil
+}
+
+func parseCondition(s string) (exprNode, error) {
+	if terms := splitTop(s, " or "); len(terms) > 1 {
+		return parseLogic(false, terms)
+	}
+	if terms := splitTop(s, " and "); len(terms) > 1 {
+		return parseLogic(true, terms)
+	}
+	for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if parts := splitTop(s, op); len(parts) == 2 {
+			left, err := parseOperand(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			right, err := parseOperand(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			return &compareNode{op: op, left: left, right: right}, nil
+		} else if len(parts) > 2 {
+			return nil, fmt.Errorf("%q used more than once", op)
+		}
+	}
+	return parseOperand(s)
+}
+
+func parseLogic(and bool, terms []string) (exprNode, error) {
+	n := &logicNode{and: and}
+	for _, t := range terms {
+		term, err := parseCondition(t)
+		if err != nil {
+			return nil, err
+		}
+		n.terms = append(n.terms, term)
+	}
+	return n, nil
+}
+
+func parseOperand(s string) (exprNode, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("missing operand")
+	}
+	if q := s[0]; q == '\'' || q == '"' {
+		if len(s) < 2 || s[len(s)-1] != q {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return literalNode{Value{Kind: KindStrings, Strings: []string{s[1 : len(s)-1]}}}, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return literalNode{Value{Kind: KindNumber, Number: f}}, nil
+	}
+	var p pipeline
+	for i, part := range splitTop(s, "|") {
+		st, err := parseStage(strings.TrimSpace(part), i == 0)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, st)
+	}
+	return p, nil
+}
+
+func parseStage(s string, first bool) (stage, error) {
+	switch s {
+	case "":
+		return nil, fmt.Errorf("empty stage")
+	case ".":
+		return func(v Value) (Value, error) { return v, nil }, nil
+	case "count":
+		return func(v Value) (Value, error) {
+			n := len(v.Elements) + len(v.Strings)
+			if v.Kind == KindNumber || v.Kind == KindBool {
+				n = 1
+			}
+			return Value{Kind: KindNumber, Number: float64(n)}, nil
+		}, nil
+	case "sum", "min", "max", "avg":
+		return aggregateStage(s), nil
+	case "text":
+		return func(v Value) (Value, error) {
+			return Value{Kind: KindStrings, Strings: v.texts()}, nil
+		}, nil
+	case "first", "last":
+		last := s == "last"
+		return func(v Value) (Value, error) {
+			switch v.Kind {
+			case KindElements:
+				if len(v.Elements) > 1 {
+					i := 0
+					if last {
+						i = len(v.Elements) - 1
+					}
+					v.Elements = v.Elements[i : i+1]
+				}
+			case KindStrings:
+				if len(v.Strings) > 1 {
+					i := 0
+					if last {
+						i = len(v.Strings) - 1
+					}
+					v.Strings = v.Strings[i : i+1]
+				}
+			}
+			return v, nil
+		}, nil
+	}
+	if strings.HasPrefix(s, "where(") && strings.HasSuffix(s, ")") {
+		cond, err := parseCondition(s[len("where(") : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		return elementStage(s, func(in []*Element) (Value, error) {
+			var out []*Element
+			for _, el := range in {
+				ok, err := cond.eval(el)
+				if err != nil {
+					return Value{}, err
+				}
+				if ok.truth() {
+					out = append(out, el)
+				}
+			}
+			return Value{Kind: KindElements, Elements: out}, nil
+		}), nil
+	}
+	if strings.HasPrefix(s, "@") {
+		name := s[1:]
+		return elementStage(s, func(in []*Element) (Value, error) {
+			out := Value{Kind: KindStrings}
+			for _, el := range in {
+				if v, ok := el.lookupAttr(name); ok {
+					out.Strings = append(out.Strings, v)
+				}
+			}
+			return out, nil
+		}), nil
+	}
+	sel, err := CompileSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	return elementStage(s, func(in []*Element) (Value, error) {
+		out := Value{Kind: KindElements}
+		for _, el := range in {
+			out.Elements = append(out.Elements, sel.MatchAll(el)...)
+		}
+		return out, nil
+	}), nil
+}
+
+// elementStage wraps a stage that accepts only elements.
+func elementStage(name string, fn func([]*Element) (Value, error)) stage {
+	return func(v Value) (Value, error) {
+		if v.Kind != KindElements {
+			return Value{}, fmt.Errorf("%s: input is not a list of elements", name)
+		}
+		return fn(v.Elements)
+	}
+}
+
+func aggregateStage(name string) stage {
+	return func(v Value) (Value, error) {
+		if v.Kind == KindNumber {
+			return v, nil
+		}
+		texts := v.texts()
+		if len(texts) == 0 && name != "sum" {
+			return Value{}, fmt.Errorf("%s of no values", name)
+		}
+		result := 0.0
+		for i, text := range texts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return Value{}, fmt.Errorf("%s: %q is not a number", name, text)
+			}
+			switch {
+			case name == "sum" || name == "avg":
+				result += f
+			case i == 0, name == "min" && f < result, name == "max" && f > result:
+				result = f
+			}
+		}
+		if name == "avg" {
+			result /= float64(len(texts))
+		}
+		return Value{Kind: KindNumber, Number: result}, nil
+	}
+}
+
+// splitTop splits s around sep, ignoring occurrences of sep within
+// quotes, brackets or parentheses.
+func splitTop(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '\'' || c == '"':
+			quote = c
+			continue
+		case c == '[' || c == '(' || c == '{':
+			depth++
+			continue
+		case c == ']' || c == ')' || c == '}':
+			depth--
+			continue
+		}
+		if depth == 0 && strings.HasPrefix(s[i:], sep) {
+			// "<" and ">" must not match the start of "<=" and ">="
+			if len(sep) == 1 && (sep == "<" || sep == ">" || sep == "=") {
+				if i+1 < len(s) && s[i+1] == '=' || i > 0 && strings.ContainsRune("!<>", rune(s[i-1])) {
+					continue
+				}
+			}
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
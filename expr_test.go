@@ -0,0 +1,67 @@
+package xmltree
+
+import "testing"
+
+const ordersDoc = `<orders>
+	<order id="1" status="open"><item/><item/><item/><total>10.5</total></order>
+	<order id="2" status="void"><item/><total>3</total></order>
+	<order id="3" status="open"><item/><total>1.5</total></order>
+	<count>n</count>
+</orders>`
+
+func TestEval(t *testing.T) {
+	root := parseDoc(t, []byte(ordersDoc))
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"//order | count", "3"},
+		{"order/total | sum", "15"},
+		{"order/total | max", "10.5"},
+		{"order/total | min", "1.5"},
+		{"order | @id | count", "3"},
+		{"order | where(@status='open') | @id", "1\n3"},
+		{"order | where(total > 2) | @id", "1\n2"},
+		{"order | where(item | count >= 2 or @id = 3) | @id", "1\n3"},
+		{"order | where(item | count < 3 and @status != 'void') | @id", "3"},
+		{"order | where(@missing) | count", "0"},
+		{"order | last | @id", "3"},
+		{"order[@status='void'] | total | text", "3"},
+		{"/count | text", "n"},
+		{"order | count > 2", "true"},
+		{"order | where(@status = \"open\") | total | avg", "6"},
+		{". | count", "1"},
+	}
+	for _, tt := range tests {
+		v, err := Eval(tt.expr, root)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "order |", "order | where(@a = )", "order[", "a = 'b"} {
+		if _, err := CompileExpr(expr); err == nil {
+			t.Errorf("CompileExpr(%q) succeeded", expr)
+		}
+	}
+	for _, expr := range []string{"order | @status | sum", "order | @id | @x", "nothing | max", "/count | sum"} {
+		if _, err := Eval(expr, root); err == nil {
+			t.Errorf("Eval(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestExprReuse(t *testing.T) {
+	expr := MustCompileExpr("item | count")
+	root := parseDoc(t, []byte(ordersDoc))
+	for i, want := range []float64{3, 1, 1} {
+		v, err := expr.Eval(&root.Children[i])
+		if err != nil || v.Kind != KindNumber || v.Number != want {
+			t.Errorf("order %d: got %v, %v", i, v, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A PathOption configures the path syntax used by FlattenPaths and
+// UnflattenPaths.
+type PathOption func(*pathSyntax)
+
+type pathSyntax struct {
+	sep        string
+	attrPrefix string
+}
+
+func newPathSyntax(opts []PathOption) *pathSyntax {
+	syntax := &pathSyntax{sep: "/", attrPrefix: "@"}
+	for _, opt := range opts {
+		opt(syntax)
+	}
+	return syntax
+}
+
+// WithPathSeparator sets the string placed between path steps. The
+// default is "/". A separator of "." produces keys suitable for
+// Java-style property files.
+func WithPathSeparator(sep string) PathOption {
+	return func(p *pathSyntax) { p.sep = sep }
+}
+
+// WithAttrPrefix sets the string that marks the final step of a path
+// as an attribute name. The default is "@".
+func WithAttrPrefix(prefix string) PathOption {
+	return func(p *pathSyntax) { p.attrPrefix = prefix }
+}
+
+// FlattenPaths converts the tree rooted at el to a map from paths to
+// values. Each leaf element is stored under its path, in the form
+// produced by PathTo, and each attribute under the path of its
+// element followed by a step naming the attribute, such as
+// "/config/server[2]/@port". Names are written with the prefixes in
+// scope at each element; namespace declarations are not recorded.
+//
+// The result may be compared with another flattened document, or
+// converted back into a tree with UnflattenPaths. FlattenPaths is
+// unrelated to the Flatten method, which lists the descendants of an
+// element.
+func FlattenPaths(el *Element, opts ...PathOption) map[string]string {
+	syntax := newPathSyntax(opts)
+	m := make(map[string]string)
+	syntax.flatten(m, el, syntax.sep+el.Prefix(el.Name), 0)
+	return m
+}
+
+func (p *pathSyntax) flatten(m map[string]string, el *Element, path string, depth int) {
+	for _, a := range el.StartElement.Attr {
+		m[path+p.sep+p.attrPrefix+el.Prefix(a.Name)] = a.Value
+	}
+	if len(el.Children) == 0 {
+		if content, err := el.contentBytes(); err == nil {
+			m[path] = string(content)
+		}
+		return
+	}
+	if depth > recursionLimit {
+		return
+	}
+	count := make(map[xml.Name]int)
+	for _, c := range el.Children {
+		count[c.Name]++
+	}
+	seen := make(map[xml.Name]int)
+	for i := range el.Children {
+		child := &el.Children[i]
+		step := child.Prefix(child.Name)
+		if count[child.Name] > 1 {
+			seen[child.Name]++
+			step += fmt.Sprintf("[%d]", seen[child.Name])
+		}
+		p.flatten(m, child, path+p.sep+step, depth+1)
+	}
+}
+
+// UnflattenPaths builds a tree from a map in the format produced by
+// FlattenPaths. Siblings of the same name are ordered by their index;
+// otherwise, children are added in the sorted order of their paths.
+// Names are not resolved to namespaces, so the result is suitable for
+// encoding but not for namespace-aware comparison. UnflattenPaths
+// returns an error if the paths do not share a single root element,
+// or if a path is malformed.
+func UnflattenPaths(m map[string]string, opts ...PathOption) (*Element, error) {
+	syntax := newPathSyntax(opts)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var root *Element
+	for _, key := range keys {
+		steps := strings.Split(strings.TrimPrefix(key, syntax.sep), syntax.sep)
+		var attr string
+		if last := steps[len(steps)-1]; strings.HasPrefix(last, syntax.attrPrefix) && syntax.attrPrefix != "" {
+			attr = strings.TrimPrefix(last, syntax.attrPrefix)
+			steps = steps[:len(steps)-1]
+		}
+		if len(steps) == 0 || steps[0] == "" || strings.Contains(steps[0], "[") {
+			return nil, fmt.Errorf("xmltree: invalid root in path %q", key)
+		}
+		if root == nil {
+			root = &Element{StartElement: xml.StartElement{Name: xml.Name{Local: steps[0]}}}
+		} else if root.Name.Local != steps[0] {
+			return nil, fmt.Errorf("xmltree: path %q is not below /%s", key, root.Name.Local)
+		}
+		el := root
+		for _, step := range steps[1:] {
+			name, index, err := parsePathStep(step)
+			if err != nil {
+				return nil, fmt.Errorf("xmltree: path %q: %v", key, err)
+			}
+			el = el.nthChild(xml.Name{Local: name}, index)
+		}
+		if attr != "" {
+			el.SetAttr("", attr, m[key])
+		} else {
+			el.Content = []byte(m[key])
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("xmltree: no paths to unflatten")
+	}
+	return root, nil
+}
+
+func parsePathStep(step string) (string, int, error) {
+	name, index := step, 1
+	if i := strings.IndexByte(step, '['); i >= 0 && strings.HasSuffix(step, "]") {
+		n, err := strconv.Atoi(step[i+1 : len(step)-1])
+		if err != nil || n < 1 {
+			return "", 0, fmt.Errorf("invalid index in %q", step)
+		}
+		name, index = step[:i], n
+	}
+	if name == "" {
+		return "", 0, fmt.Errorf("empty step")
+	}
+	return name, index, nil
+}
+
+// nthChild returns the index'th child of el with the given name,
+// counting from 1, adding children as needed. If name.Space is empty,
+// children in any namespace match.
+func (el *Element) nthChild(name xml.Name, index int) *Element {
+	seen, last := 0, -1
+	for i := range el.Children {
+		if c := &el.Children[i]; c.Name.Local == name.Local && (name.Space == "" || c.Name.Space == name.Space) {
+			seen++
+			last = i
+			if seen == index {
+				return &el.Children[i]
+			}
+		}
+	}
+	for ; seen < index; seen++ {
+		if last < 0 {
+			last = len(el.Children)
+		} else {
+			last++
+		}
+		el.insertAt(last, &Element{StartElement: xml.StartElement{Name: name}, Scope: el.Scope})
+	}
+	return &el.Children[last]
+}
+
+// ApplyOverrides sets values in the tree rooted at el from a map in
+// the format produced by FlattenPaths, so that individual settings in
+// an XML configuration file can be overridden, for example from
+// environment variables, without templating the file. Each key
+// refers to an attribute or to the content of an element; elements
+// and attributes that do not exist are created. Prefixes in a path
+// are resolved in the scope of the element they appear on. Overrides
+// are applied in the sorted order of their keys.
+//
+// ApplyOverrides returns an error, without applying any overrides, if
+// a path is malformed, does not begin with the name of el, or uses a
+// prefix that is not in scope. It is an error to set the content of
+// an element that has children.
+//
+//	err := xmltree.ApplyOverrides(cfg, map[string]string{
+//		"/config/db/@host":       os.Getenv("DB_HOST"),
+//		"/config/server[2]/port": "8443",
+//	})
+func ApplyOverrides(el *Element, overrides map[string]string, opts ...PathOption) error {
+	syntax := newPathSyntax(opts)
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	type override struct {
+		steps []string
+		index []int
+		attr  string
+		value string
+	}
+	parsed := make([]override, 0, len(keys))
+	for _, key := range keys {
+		o := override{value: overrides[key]}
+		steps := strings.Split(strings.TrimPrefix(key, syntax.sep), syntax.sep)
+		if last := steps[len(steps)-1]; syntax.attrPrefix != "" && strings.HasPrefix(last, syntax.attrPrefix) {
+			o.attr = strings.TrimPrefix(last, syntax.attrPrefix)
+			steps = steps[:len(steps)-1]
+		}
+		if len(steps) == 0 || steps[0] != el.Prefix(el.Name) {
+			return fmt.Errorf("xmltree: override %q is not below /%s", key, el.Prefix(el.Name))
+		}
+		for _, step := range steps[1:] {
+			name, index, err := parsePathStep(step)
+			if err != nil {
+				return fmt.Errorf("xmltree: override %q: %v", key, err)
+			}
+			o.steps = append(o.steps, name)
+			o.index = append(o.index, index)
+		}
+		parsed = append(parsed, o)
+	}
+
+	// Check the overrides against a copy first, so that a failure
+	// leaves el unchanged.
+	for _, tree := range []*Element{el.Clone(), el} {
+		for i, o := range parsed {
+			target := tree
+			for j, step := range o.steps {
+				// Unprefixed steps are in the default namespace, if any.
+				name, ok := target.ResolveNS(step)
+				if !ok && !strings.Contains(step, ":") {
+					name, ok = xml.Name{Local: step}, true
+				}
+				if !ok {
+					return fmt.Errorf("xmltree: override %q: prefix of %s is not in scope", keys[i], step)
+				}
+				target = target.nthChild(name, o.index[j])
+			}
+			if o.attr != "" {
+				name, err := target.resolveAttrName(o.attr)
+				if err != nil {
+					return err
+				}
+				target.SetAttr(name.Space, name.Local, o.value)
+				continue
+			}
+			if len(target.Children) > 0 {
+				return fmt.Errorf("xmltree: override %q: element has children", keys[i])
+			}
+			target.setContent([]byte(o.value))
+		}
+	}
+	return nil
+}
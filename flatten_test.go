@@ -0,0 +1,112 @@
+package xmltree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenPaths(t *testing.T) {
+	root := parseDoc(t, []byte(`<config v="1"><server port="80">a</server><server port="81">b</server><log/></config>`))
+	want := map[string]string{
+		"/config/@v":              "1",
+		"/config/server[1]/@port": "80",
+		"/config/server[1]":       "a",
+		"/config/server[2]/@port": "81",
+		"/config/server[2]":       "b",
+		"/config/log":             "",
+	}
+	got := FlattenPaths(root)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+	if back, err := UnflattenPaths(got); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(FlattenPaths(back), want) {
+		t.Errorf("round trip gave %s", Marshal(back))
+	}
+
+	props := FlattenPaths(root, WithPathSeparator("."), WithAttrPrefix("#"))
+	if props[".config.server[2].#port"] != "81" {
+		t.Errorf("custom syntax gave %v", props)
+	}
+}
+
+func TestUnflattenPaths(t *testing.T) {
+	el, err := UnflattenPaths(map[string]string{
+		"/a/b[3]": "third",
+		"/a/b[1]": "first",
+		"/a/@id":  "x",
+		"/a/c/d":  "deep",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<a id="x"><b>first</b><b /><b>third</b><c><d>deep</d></c></a>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	for _, bad := range []map[string]string{
+		{},
+		{"/a/b": "", "/z/b": ""},
+		{"/a/b[0]": ""},
+		{"/a//b": ""},
+	} {
+		if _, err := UnflattenPaths(bad); err == nil {
+			t.Errorf("UnflattenPaths(%v) succeeded", bad)
+		}
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	root := parseDoc(t, []byte(`<config xmlns="urn:cfg" xmlns:t="urn:tls">`+
+		`<server port="80"><host>a</host></server><server port="81"/>`+
+		`</config>`))
+	err := ApplyOverrides(root, map[string]string{
+		"/config/server[2]/@port":   "8443",
+		"/config/server[2]/@t:cert": "x.pem",
+		"/config/server[1]/host":    "b",
+		"/config/db/name":           "prod",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<config xmlns="urn:cfg" xmlns:t="urn:tls">` +
+		`<server port="80"><host>b</host></server><server port="8443" t:cert="x.pem" />` +
+		`<db><name>prod</name></db></config>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if db := root.Child("urn:cfg", "db"); db == nil {
+		t.Error("new element not in default namespace")
+	}
+
+	for _, bad := range []map[string]string{
+		{"/other/x": "1"},
+		{"/config/server[0]": "1"},
+		{"/config/u:x": "1"},
+		{"/config/db/@u:x": "1"},
+		{"/config/host": "1", "/config": "2"},
+	} {
+		before := string(Marshal(root))
+		if err := ApplyOverrides(root, bad); err == nil {
+			t.Errorf("ApplyOverrides(%v) succeeded", bad)
+		}
+		if after := string(Marshal(root)); after != before {
+			t.Errorf("ApplyOverrides(%v) modified the tree on error", bad)
+		}
+	}
+}
+
+func TestApplyOverridesMisc(t *testing.T) {
+	root := MustParse([]byte(`<config><server/><!--db settings--><db/></config>`), WithComments())
+	if err := ApplyOverrides(root, map[string]string{"/config/server[2]/@port": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `<config><server /><server port="1" /><!--db settings--><db /></config>`
+	if got := root.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if s := &root.Children[1]; s.Parent() != root || root.Children[2].Parent() != root {
+		t.Error("parents not linked after insertion")
+	}
+}
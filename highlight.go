@@ -0,0 +1,177 @@
+package xmltree
+
+import "bytes"
+
+// A Theme assigns ANSI escape sequences to the different kinds of
+// token in the output of MarshalColored. An empty string leaves
+// tokens of that kind uncolored.
+type Theme struct {
+	Tag     string // element names
+	Attr    string // attribute names
+	Value   string // quoted attribute values
+	Text    string // character data
+	Comment string // comments
+	Punct   string // angle brackets, slashes and equal signs
+}
+
+// DefaultTheme uses the 16 standard terminal colors, and should be
+// readable on both light and dark backgrounds.
+var DefaultTheme = Theme{
+	Tag:     "\x1b[34m",
+	Attr:    "\x1b[36m",
+	Value:   "\x1b[32m",
+	Comment: "\x1b[90m",
+	Punct:   "\x1b[90m",
+}
+
+const ansiReset = "\x1b[0m"
+
+func (t *Theme) style(kind tokenKind) string {
+	switch kind {
+	case tagToken:
+		return t.Tag
+	case attrToken:
+		return t.Attr
+	case valueToken:
+		return t.Value
+	case textToken:
+		return t.Text
+	case commentToken:
+		return t.Comment
+	case punctToken:
+		return t.Punct
+	}
+	return ""
+}
+
+// MarshalColored is like Marshal, but surrounds tag names, attribute
+// names, attribute values, comments and other tokens with the ANSI
+// escape sequences in theme, for display on a terminal.
+func MarshalColored(el *Element, theme Theme, opts ...EncodeOption) []byte {
+	var buf bytes.Buffer
+	lexXML(Marshal(el, opts...), func(kind tokenKind, text []byte) {
+		if style := theme.style(kind); style != "" {
+			buf.WriteString(style)
+			buf.Write(text)
+			buf.WriteString(ansiReset)
+		} else {
+			buf.Write(text)
+		}
+	})
+	return buf.Bytes()
+}
+
+type tokenKind int
+
+const (
+	punctToken tokenKind = iota
+	tagToken
+	attrToken
+	valueToken
+	textToken
+	spaceToken
+	commentToken
+)
+
+// lexXML splits XML produced by the encoder into tokens for syntax
+// highlighting. It is not a general purpose XML tokenizer; it is only
+// expected to handle well-formed input, and passes through anything
+// it does not understand as text.
+func lexXML(data []byte, emit func(tokenKind, []byte)) {
+	for len(data) > 0 {
+		switch {
+		case bytes.HasPrefix(data, []byte("<!--")):
+			data = lexUntil(data, "-->", commentToken, emit)
+		case bytes.HasPrefix(data, []byte("<?")):
+			data = lexUntil(data, "?>", commentToken, emit)
+		case bytes.HasPrefix(data, []byte("<![CDATA[")):
+			data = lexUntil(data, "]]>", textToken, emit)
+		case data[0] == '<':
+			data = lexTag(data, emit)
+		default:
+			n := bytes.IndexByte(data, '<')
+			if n < 0 {
+				n = len(data)
+			}
+			emit(textToken, data[:n])
+			data = data[n:]
+		}
+	}
+}
+
+func lexUntil(data []byte, end string, kind tokenKind, emit func(tokenKind, []byte)) []byte {
+	n := bytes.Index(data, []byte(end))
+	if n < 0 {
+		n = len(data)
+	} else {
+		n += len(end)
+	}
+	emit(kind, data[:n])
+	return data[n:]
+}
+
+func isNameDelim(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '=', '/', '>':
+		return true
+	}
+	return false
+}
+
+func lexTag(data []byte, emit func(tokenKind, []byte)) []byte {
+	open := 1
+	if len(data) > 1 && data[1] == '/' {
+		open = 2
+	}
+	emit(punctToken, data[:open])
+	data = data[open:]
+
+	n := 0
+	for n < len(data) && !isNameDelim(data[n]) {
+		n++
+	}
+	emit(tagToken, data[:n])
+	data = data[n:]
+
+	for len(data) > 0 {
+		switch c := data[0]; {
+		case c == '>':
+			emit(punctToken, data[:1])
+			return data[1:]
+		case c == '/' && len(data) > 1 && data[1] == '>':
+			emit(punctToken, data[:2])
+			return data[2:]
+		case c == '=':
+			emit(punctToken, data[:1])
+			data = data[1:]
+		case c == '"' || c == '\'':
+			n := bytes.IndexByte(data[1:], c)
+			if n < 0 {
+				n = len(data)
+			} else {
+				n += 2
+			}
+			emit(valueToken, data[:n])
+			data = data[n:]
+		case isNameDelim(c):
+			n := 0
+			for n < len(data) && (data[n] == ' ' || data[n] == '\t' || data[n] == '\r' || data[n] == '\n') {
+				n++
+			}
+			if n == 0 {
+				// stray '/'
+				n = 1
+			}
+			emit(spaceToken, data[:n])
+			data = data[n:]
+		default:
+			n := 0
+			for n < len(data) && !isNameDelim(data[n]) {
+				n++
+			}
+			emit(attrToken, data[:n])
+			data = data[n:]
+		}
+	}
+	return data
+}
@@ -0,0 +1,34 @@
+package xmltree
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalColored(t *testing.T) {
+	root := parseDoc(t, []byte(`<a x="1"><b>text &amp; more</b><c/></a>`))
+	theme := Theme{Tag: "[T]", Attr: "[A]", Value: "[V]", Punct: "[P]"}
+	out := string(MarshalColored(root, theme))
+	want := `[P]<` + ansiReset + `[T]a` + ansiReset + ` [A]x` + ansiReset +
+		`[P]=` + ansiReset + `[V]"1"` + ansiReset + `[P]>` + ansiReset +
+		`[P]<` + ansiReset + `[T]b` + ansiReset + `[P]>` + ansiReset +
+		`text &amp; more` +
+		`[P]</` + ansiReset + `[T]b` + ansiReset + `[P]>` + ansiReset +
+		`[P]<` + ansiReset + `[T]c` + ansiReset + ` [P]/>` + ansiReset +
+		`[P]</` + ansiReset + `[T]a` + ansiReset + `[P]>` + ansiReset
+	if out != want {
+		t.Errorf("got\n%q\nwant\n%q", out, want)
+	}
+}
+
+func TestLexXMLRoundTrip(t *testing.T) {
+	root := parseDoc(t, exampleDoc)
+	src := MarshalIndent(root, "", "  ")
+	var buf bytes.Buffer
+	lexXML(src, func(_ tokenKind, text []byte) {
+		buf.Write(text)
+	})
+	if !bytes.Equal(buf.Bytes(), src) {
+		t.Errorf("lexXML did not preserve input:\n%s", buf.Bytes())
+	}
+}
@@ -0,0 +1,116 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// WithHoistNamespaces declares every namespace prefix used in the
+// document once, on the root element, rather than on the elements
+// where it was declared. Many people, and many diff tools, find
+// documents in this style easier to read. Declarations of the
+// default namespace are left in place, as moving them would change
+// the namespace of unprefixed names. If the same prefix is bound to
+// two different namespaces within the document, the declarations
+// cannot be merged, and encoding fails with an error, unless
+// WithRenamePrefixes is also given.
+func WithHoistNamespaces() EncodeOption {
+	return func(e *encoder) {
+		e.hoist = true
+	}
+}
+
+// hoistNamespaces collects the prefixes bound in the tree rooted at
+// root, as it will be written, into the declarations of the root
+// element.
+func (e *encoder) hoistNamespaces(root *Element) error {
+	e.hoisted = make(map[string]string)
+	ns := append([]xml.Name(nil), root.Scope.ns...)
+	for _, b := range effectiveBindings(root) {
+		e.hoisted[b.Local] = b.Space
+	}
+	var visit func(el *Element, depth int) error
+	visit = func(el *Element, depth int) error {
+		for _, b := range effectiveBindings(el) {
+			uri, ok := e.hoisted[b.Local]
+			if !ok {
+				e.hoisted[b.Local] = b.Space
+				ns = append(ns, b)
+			} else if uri != b.Space && e.renamed != nil {
+				if _, ok := e.renamed[b]; !ok {
+					to := freshPrefix(b.Local, func(p string) bool {
+						_, ok := e.hoisted[p]
+						return ok
+					})
+					e.renamed[b] = to
+					e.hoisted[to] = b.Space
+					ns = append(ns, xml.Name{Space: b.Space, Local: to})
+					e.rename(b, to)
+				}
+			} else if uri != b.Space {
+				return fmt.Errorf("xmltree: cannot hoist namespaces: prefix %q is bound to both %s and %s",
+					b.Local, uri, b.Space)
+			}
+		}
+		if depth > recursionLimit {
+			return nil
+		}
+		for i := range el.Children {
+			if child := e.visible(&el.Children[i]); child != nil {
+				if err := visit(child, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(root, 0); err != nil {
+		return err
+	}
+	e.hoistedRoot = Scope{ns: ns}
+	return nil
+}
+
+// effectiveBindings returns the prefixed namespace bindings in effect
+// for el, ignoring those shadowed by a later binding of the same
+// prefix.
+func effectiveBindings(el *Element) []xml.Name {
+	var bindings []xml.Name
+	for i, b := range el.ns {
+		if b.Local != "" && b.Space != "" && !el.shadowed(i) {
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings
+}
+
+// scope returns the namespace declarations to write on the start
+// tag of el.
+func (e *encoder) scope(el, parent *Element) Scope {
+	if e.hoisted == nil {
+		return diffScope(parent, el)
+	}
+	if parent == nil {
+		return e.hoistedRoot
+	}
+	// Only the bindings that differ from those in effect, which
+	// are the hoisted ones unless an ancestor rebound the prefix,
+	// are declared again.
+	var ns []xml.Name
+	for _, b := range diffScope(parent, el).ns {
+		if _, ok := e.renamed[b]; ok {
+			continue
+		}
+		if b.Local != "" {
+			uri, ok := parent.Scope.binding(b.Local)
+			if _, renamed := e.renamed[xml.Name{Space: uri, Local: b.Local}]; !ok || renamed {
+				uri = e.hoisted[b.Local]
+			}
+			if uri == b.Space {
+				continue
+			}
+		}
+		ns = append(ns, b)
+	}
+	return Scope{ns: ns}
+}
@@ -0,0 +1,42 @@
+package xmltree
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHoistNamespaces(t *testing.T) {
+	root := MustParse([]byte(`<doc xmlns:a="urn:a"><a:x xmlns:b="urn:b"><b:y/></a:x>` +
+		`<z xmlns="urn:z" xmlns:c="urn:c"><c:w/></z><v xmlns:b="urn:b"/></doc>`))
+	got := string(Marshal(root, WithHoistNamespaces()))
+	want := `<doc xmlns:a="urn:a" xmlns:b="urn:b" xmlns:c="urn:c"><a:x><b:y /></a:x>` +
+		`<z xmlns="urn:z"><c:w /></z><v /></doc>`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if reparsed := MustParse([]byte(got)); !Equal(reparsed, root) {
+		t.Errorf("hoisted document differs from the original")
+	}
+
+	conflict := MustParse([]byte(`<doc><p:x xmlns:p="urn:one"/><p:x xmlns:p="urn:two"/></doc>`))
+	err := Encode(new(strings.Builder), conflict, WithHoistNamespaces())
+	if err == nil || !strings.Contains(err.Error(), `prefix "p"`) {
+		t.Errorf("expected a prefix conflict, got %v", err)
+	}
+}
+
+func TestHoistNamespacesDocumentWriter(t *testing.T) {
+	var buf strings.Builder
+	root := MustParse([]byte(`<doc xmlns:a="urn:a"><a:x/></doc>`))
+	dw, err := NewDocumentWriter(&buf, root, WithHoistNamespaces())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dw.AppendElement(MustParse([]byte(`<a:y xmlns:a="urn:other"/>`)))
+	if err := dw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<a:x /><a:y xmlns:a="urn:other" /></doc>`) {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+}
@@ -0,0 +1,147 @@
+package xmltree
+
+import (
+	"bufio"
+	"bytes"
+	"html"
+	"io"
+)
+
+// An HTMLTheme assigns CSS class names to the different kinds of
+// token in the output of RenderHTML. Tokens whose class is the empty
+// string are not wrapped in a <span>.
+type HTMLTheme struct {
+	Pre     string // class of the enclosing <pre> element
+	Tag     string
+	Attr    string
+	Value   string
+	Text    string
+	Comment string
+	Punct   string
+
+	// If Collapsible is true, every element with child elements
+	// is wrapped in a <details> element whose <summary> is the
+	// start tag, so that subtrees may be collapsed by the reader.
+	Collapsible bool
+}
+
+// DefaultHTMLTheme uses class names prefixed with "xml-". No
+// style sheet is provided; callers are expected to supply their own.
+var DefaultHTMLTheme = HTMLTheme{
+	Pre:     "xml",
+	Tag:     "xml-tag",
+	Attr:    "xml-attr",
+	Value:   "xml-value",
+	Text:    "xml-text",
+	Comment: "xml-comment",
+	Punct:   "xml-punct",
+}
+
+func (t *HTMLTheme) class(kind tokenKind) string {
+	switch kind {
+	case tagToken:
+		return t.Tag
+	case attrToken:
+		return t.Attr
+	case valueToken:
+		return t.Value
+	case textToken:
+		return t.Text
+	case commentToken:
+		return t.Comment
+	case punctToken:
+		return t.Punct
+	}
+	return ""
+}
+
+// An htmlToken is a token of the XML being rendered. The structure of
+// the elements is recorded as the tokens are read, so that RenderHTML
+// can tell where each collapsible subtree begins and ends.
+type htmlToken struct {
+	kind tokenKind
+	text []byte
+
+	children bool // on the < of a start tag, whether the element has child elements
+	start    int  // on a > ending a tag, the index of the < of the start tag, plus one
+	endTag   bool // on a > ending a tag, whether the tag is an end tag
+}
+
+// RenderHTML writes the XML encoding of el to w as an HTML <pre>
+// block, with each token wrapped in a <span> whose class is taken
+// from theme. The XML is indented as by MarshalIndent unless other
+// EncodeOptions are given. RenderHTML returns any error encountered
+// encoding el, in which case nothing is written, or writing to w.
+func RenderHTML(w io.Writer, el *Element, theme HTMLTheme, opts ...EncodeOption) error {
+	if len(opts) == 0 {
+		opts = []EncodeOption{WithIndent("", "  ")}
+	}
+	var encoded bytes.Buffer
+	if err := EncodeTo(&encoded, el, opts...); err != nil {
+		return err
+	}
+	var toks []htmlToken
+	var open []int // the < of each open start tag
+	tag, endTag := 0, false
+	lexXML(encoded.Bytes(), func(kind tokenKind, text []byte) {
+		tok := htmlToken{kind: kind, text: text}
+		if kind == punctToken {
+			switch string(text) {
+			case "<":
+				if len(open) > 0 {
+					toks[open[len(open)-1]].children = true
+				}
+				tag, endTag = len(toks), false
+			case "</":
+				tag, endTag = len(toks), true
+			case ">":
+				tok.endTag = endTag
+				if !endTag {
+					open = append(open, tag)
+					tok.start = tag + 1
+				} else if len(open) > 0 {
+					tok.start = open[len(open)-1] + 1
+					open = open[:len(open)-1]
+				}
+			}
+		}
+		toks = append(toks, tok)
+	})
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<pre")
+	writeClass(bw, theme.Pre)
+	bw.WriteString(">")
+	for _, tok := range toks {
+		if theme.Collapsible && tok.children {
+			bw.WriteString("<details open><summary>")
+		}
+		if class := theme.class(tok.kind); class != "" {
+			bw.WriteString("<span")
+			writeClass(bw, class)
+			bw.WriteString(">")
+			bw.WriteString(html.EscapeString(string(tok.text)))
+			bw.WriteString("</span>")
+		} else {
+			bw.WriteString(html.EscapeString(string(tok.text)))
+		}
+		if !theme.Collapsible || tok.start == 0 || !toks[tok.start-1].children {
+			continue
+		}
+		if tok.endTag {
+			bw.WriteString("</details>")
+		} else {
+			bw.WriteString("</summary>")
+		}
+	}
+	bw.WriteString("</pre>\n")
+	return bw.Flush()
+}
+
+func writeClass(w *bufio.Writer, class string) {
+	if class != "" {
+		w.WriteString(` class="`)
+		w.WriteString(html.EscapeString(class))
+		w.WriteString(`"`)
+	}
+}
@@ -0,0 +1,71 @@
+package xmltree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	root := parseDoc(t, []byte(`<a x="&lt;1&gt;"><b>text</b><c/></a>`))
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, root, HTMLTheme{Tag: "t", Value: "v"}, WithIndent("", "")); err != nil {
+		t.Fatal(err)
+	}
+	want := `<pre>&lt;<span class="t">a</span> x=<span class="v">&#34;&amp;lt;1&amp;gt;&#34;</span>&gt;` + "\n" +
+		`&lt;<span class="t">b</span>&gt;text&lt;/<span class="t">b</span>&gt;` + "\n" +
+		`&lt;<span class="t">c</span> /&gt;` + "\n" +
+		`&lt;/<span class="t">a</span>&gt;` + "\n" +
+		"</pre>\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestRenderHTMLCollapsible(t *testing.T) {
+	root := parseDoc(t, []byte(`<a><b><c>text</c><d/></b><e>more</e></a>`))
+	var buf bytes.Buffer
+	theme := HTMLTheme{Collapsible: true}
+	if err := RenderHTML(&buf, root, theme, WithIndent("", "")); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "<details open>"); n != 2 {
+		t.Errorf("expected 2 collapsible subtrees, got %d in\n%s", n, out)
+	}
+	if n := strings.Count(out, "</details>"); n != 2 {
+		t.Errorf("expected 2 closed subtrees, got %d in\n%s", n, out)
+	}
+	if !strings.HasSuffix(out, "&lt;/a&gt;</details>\n</pre>\n") {
+		t.Errorf("root subtree not closed at end of output:\n%s", out)
+	}
+}
+
+func TestRenderHTMLCollapsibleNesting(t *testing.T) {
+	root := parseDoc(t, []byte(`<a><b><c/></b><d/></a>`))
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, root, HTMLTheme{Collapsible: true}, WithIndent("", "")); err != nil {
+		t.Fatal(err)
+	}
+	want := `<pre><details open><summary>&lt;a&gt;</summary>` + "\n" +
+		`<details open><summary>&lt;b&gt;</summary>` + "\n" +
+		`&lt;c /&gt;` + "\n" +
+		`&lt;/b&gt;</details>` + "\n" +
+		`&lt;d /&gt;` + "\n" +
+		`&lt;/a&gt;</details>` + "\n" +
+		"</pre>\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestRenderHTMLError(t *testing.T) {
+	root, err := Parse([]byte(`<doc>`+strings.Repeat("x", 200)+`</doc>`), WithContentSpill(100, failStore{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, root, DefaultHTMLTheme); err == nil || buf.Len() != 0 {
+		t.Errorf("RenderHTML returned %v and wrote %q", err, buf.String())
+	}
+}
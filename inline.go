@@ -0,0 +1,94 @@
+package xmltree
+
+// MathMLNamespace is the namespace of MathML, which is treated as an
+// inline namespace unless others are given.
+const MathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+
+// Elements in inline namespaces hold content whose whitespace and
+// child order are significant, such as MathML expressions or inline
+// HTML markup. The parse option WithInlineNamespaces keeps the white
+// space within such elements, and the encoder option WithInlineSafe
+// writes it out unchanged, and the transform option WithInlineOrder
+// stops SortChildrenBy and GroupChildrenBy from reordering their
+// children. Each option takes the same list of namespaces, and
+// defaults to MathML alone.
+
+// inlineSet returns the set of namespaces in uris, or MathMLNamespace
+// alone if uris is empty.
+func inlineSet(uris []string) map[string]bool {
+	if len(uris) == 0 {
+		uris = []string{MathMLNamespace}
+	}
+	set := make(map[string]bool, len(uris))
+	for _, uri := range uris {
+		set[uri] = true
+	}
+	return set
+}
+
+// WithInlineNamespaces causes Parse to keep the text between the
+// children of elements in the given namespaces, or in MathMLNamespace
+// if none are given, as WithMixedContent does for every element. By
+// default, text between children is discarded, so that the space in
+//
+//	<m:mi>x</m:mi> <m:mo>+</m:mo>
+//
+// would be lost. Together with WithInlineSafe, this lets such content
+// be written out exactly as it was read.
+func WithInlineNamespaces(uris ...string) ParseOption {
+	return func(o *parseOptions) {
+		o.inline = inlineSet(uris)
+	}
+}
+
+// keepText reports whether the text between the children of el is
+// kept.
+func (o *parseOptions) keepText(el *Element) bool {
+	return o.mixed || o.inline[el.Name.Space]
+}
+
+// WithInlineSafe stops the encoder from changing the content of
+// elements in the given namespaces, or in MathMLNamespace if none are
+// given. Within such an element, WithIndent adds no line breaks or
+// indentation, and WithOmitEmpty drops no elements. White space
+// between children is written only if it was kept when parsing; see
+// WithInlineNamespaces.
+func WithInlineSafe(uris ...string) EncodeOption {
+	return func(e *encoder) {
+		e.inline = inlineSet(uris)
+	}
+}
+
+// isInline reports whether the encoder must leave el as it is.
+func (e *encoder) isInline(el *Element) bool {
+	return e.inline[el.Name.Space]
+}
+
+// A TransformOption modifies the behavior of SortChildrenBy and
+// GroupChildrenBy.
+type TransformOption func(*transformOptions)
+
+type transformOptions struct {
+	// Namespaces whose children keep their order
+	inline map[string]bool
+}
+
+// WithInlineOrder stops SortChildrenBy and GroupChildrenBy from
+// reordering the children of elements in the given namespaces, or in
+// MathMLNamespace if none are given. It replaces the default set,
+// which holds MathMLNamespace alone, so the namespaces given to
+// WithInlineNamespaces may be passed here unchanged.
+func WithInlineOrder(uris ...string) TransformOption {
+	return func(o *transformOptions) {
+		o.inline = inlineSet(uris)
+	}
+}
+
+// transformConfig applies opts to the default transform options.
+func transformConfig(opts []TransformOption) transformOptions {
+	o := transformOptions{inline: inlineSet(nil)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
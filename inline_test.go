@@ -0,0 +1,91 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const inlineDoc = `<doc xmlns:m="http://www.w3.org/1998/Math/MathML"><p>x</p><m:math><m:mi>b</m:mi><m:mo>+</m:mo><m:mi>a</m:mi><m:mspace/></m:math></doc>`
+
+func TestWithInlineSafe(t *testing.T) {
+	root := parseDoc(t, []byte(inlineDoc))
+	got := string(Marshal(root, WithIndent("", " "), WithInlineSafe()))
+	want := "<doc xmlns:m=\"http://www.w3.org/1998/Math/MathML\">\n" +
+		" <p>x</p>\n" +
+		" <m:math><m:mi>b</m:mi><m:mo>+</m:mo><m:mi>a</m:mi><m:mspace /></m:math>\n" +
+		"</doc>\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	got = string(Marshal(root, WithOmitEmpty(), WithInlineSafe()))
+	if want := `<m:mspace />`; !strings.Contains(got, want) {
+		t.Errorf("WithOmitEmpty dropped inline content: %s", got)
+	}
+	if got := string(Marshal(root, WithOmitEmpty())); strings.Contains(got, "mspace") {
+		t.Errorf("WithOmitEmpty kept empty element without WithInlineSafe: %s", got)
+	}
+}
+
+func TestInlineTransforms(t *testing.T) {
+	root := parseDoc(t, []byte(inlineDoc))
+	byName := func(el *Element) string { return string(el.Content) }
+	if err := root.SortChildrenBy("//*", byName); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Marshal(&root.Children[1])); got != `<m:math xmlns:m="http://www.w3.org/1998/Math/MathML"><m:mi>b</m:mi><m:mo>+</m:mo><m:mi>a</m:mi><m:mspace /></m:math>` {
+		t.Errorf("inline children were sorted: %s", got)
+	}
+}
+
+func TestInlineOrder(t *testing.T) {
+	const doc = `<doc xmlns:s="http://www.w3.org/2000/svg" xmlns:m="http://www.w3.org/1998/Math/MathML">` +
+		`<s:g><s:rect id="b"/><s:rect id="a"/></s:g><m:math><m:mi id="d"/><m:mi id="c"/></m:math></doc>`
+	byID := func(el *Element) string { return el.Attr("", "id") }
+	root := parseDoc(t, []byte(doc))
+	if err := root.SortChildrenBy("//*", byID, WithInlineOrder("http://www.w3.org/2000/svg")); err != nil {
+		t.Fatal(err)
+	}
+	want := `<doc xmlns:m="http://www.w3.org/1998/Math/MathML" xmlns:s="http://www.w3.org/2000/svg">` +
+		`<s:g><s:rect id="b" /><s:rect id="a" /></s:g><m:math><m:mi id="c" /><m:mi id="d" /></m:math></doc>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	root = parseDoc(t, []byte(doc))
+	err := root.GroupChildrenBy("//*", func(*Element) string { return "x" }, func(string) xml.StartElement {
+		return xml.StartElement{Name: xml.Name{Local: "group"}}
+	}, WithInlineOrder("http://www.w3.org/2000/svg", MathMLNamespace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Marshal(root)); !strings.Contains(got, `<s:g><s:rect id="b" />`) || !strings.Contains(got, `<m:math><m:mi id="d" />`) {
+		t.Errorf("inline children were grouped: %s", got)
+	}
+}
+
+func TestInlineWhitespace(t *testing.T) {
+	const doc = `<doc xmlns:m="http://www.w3.org/1998/Math/MathML" xmlns:h="urn:test:inline">` +
+		`<p> <b>x</b> </p><m:math><m:mi>x</m:mi> <m:mo>+</m:mo> <m:mi>y</m:mi></m:math>` +
+		`<h:span><h:b>bold</h:b> text</h:span></doc>`
+	want := "<doc xmlns:m=\"http://www.w3.org/1998/Math/MathML\" xmlns:h=\"urn:test:inline\">\n" +
+		" <p>\n  <b>x</b>\n </p>\n" +
+		" <m:math><m:mi>x</m:mi> <m:mo>+</m:mo> <m:mi>y</m:mi></m:math>\n" +
+		" <h:span><h:b>bold</h:b> text</h:span>\n" +
+		"</doc>\n"
+	root, err := Parse([]byte(doc), WithInlineNamespaces(MathMLNamespace, "urn:test:inline"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(Marshal(root, WithIndent("", " "), WithInlineSafe(MathMLNamespace, "urn:test:inline")))
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Without WithInlineNamespaces, the space between children is
+	// not kept.
+	got = string(Marshal(parseDoc(t, []byte(doc)), WithInlineSafe()))
+	if !strings.Contains(got, "<m:mi>x</m:mi><m:mo>+</m:mo>") {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,80 @@
+package xmltree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// BuildManifest returns a manifest describing a set of documents,
+// keyed by file name. The manifest has the form
+//
+//	<manifest>
+//	  <file name="content.xml" size="1024" digest="sha256:..." />
+//	  ...
+//	</manifest>
+//
+// with one file element for each document, sorted by name, so that
+// the same set of documents always produces the same manifest, which
+// may then be signed. The size is the length of the document as
+// encoded by Marshal. The digest is the SHA-256 hash of the document
+// encoded by Marshal with the attributes of each element sorted by
+// name, so that it does not depend on attribute order. It is not an
+// XML canonicalization in the sense of the W3C C14N specifications.
+//
+// If a document cannot be encoded, BuildManifest returns the error,
+// with the name of the document, and no manifest.
+func BuildManifest(docs map[string]*Element) (*Element, error) {
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := &Element{
+		StartElement: xml.StartElement{Name: xml.Name{Local: "manifest"}},
+	}
+	var buf []byte // reused for each encoding
+	var err error
+	for _, name := range names {
+		doc := docs[name]
+		if buf, err = MarshalAppend(buf[:0], doc); err != nil {
+			return nil, fmt.Errorf("xmltree: %s: %w", name, err)
+		}
+		size := len(buf)
+		if buf, err = MarshalAppend(buf[:0], doc, WithFilter(sortedAttrs)); err != nil {
+			return nil, fmt.Errorf("xmltree: %s: %w", name, err)
+		}
+		digest := sha256.Sum256(buf)
+		manifest.Children = append(manifest.Children, Element{
+			StartElement: xml.StartElement{
+				Name: xml.Name{Local: "file"},
+				Attr: []xml.Attr{
+					{Name: xml.Name{Local: "name"}, Value: name},
+					{Name: xml.Name{Local: "size"}, Value: strconv.Itoa(size)},
+					{Name: xml.Name{Local: "digest"}, Value: "sha256:" + hex.EncodeToString(digest[:])},
+				},
+			},
+		})
+	}
+	manifest.LinkParents()
+	return manifest, nil
+}
+
+// sortedAttrs returns a copy of el with its attributes sorted by
+// namespace and local name.
+func sortedAttrs(el *Element) *Element {
+	sorted := *el
+	sorted.StartElement = el.StartElement.Copy()
+	sort.SliceStable(sorted.StartElement.Attr, func(i, j int) bool {
+		a, b := sorted.StartElement.Attr[i].Name, sorted.StartElement.Attr[j].Name
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+	return &sorted
+}
@@ -0,0 +1,48 @@
+package xmltree
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuildManifest(t *testing.T) {
+	a := parseDoc(t, []byte(`<doc x="1" y="2"><p>text</p></doc>`))
+	b := parseDoc(t, []byte(`<other/>`))
+	manifest, err := BuildManifest(map[string]*Element{"b.xml": b, "a.xml": a})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Children) != 2 {
+		t.Fatalf("manifest has %d entries", len(manifest.Children))
+	}
+	first := manifest.Children[0]
+	if name := first.Attr("", "name"); name != "a.xml" {
+		t.Errorf("first entry is %q", name)
+	}
+	if size := first.Attr("", "size"); size != strconv.Itoa(len(Marshal(a))) {
+		t.Errorf("size = %s", size)
+	}
+
+	reordered := parseDoc(t, []byte(`<doc y="2" x="1"><p>text</p></doc>`))
+	again, _ := BuildManifest(map[string]*Element{"a.xml": reordered})
+	if d1, d2 := first.Attr("", "digest"), again.Children[0].Attr("", "digest"); d1 != d2 {
+		t.Errorf("digest depends on attribute order: %s != %s", d1, d2)
+	}
+	changed := parseDoc(t, []byte(`<doc x="1" y="2"><p>other</p></doc>`))
+	third, _ := BuildManifest(map[string]*Element{"a.xml": changed})
+	if first.Attr("", "digest") == third.Children[0].Attr("", "digest") {
+		t.Error("digest did not change with content")
+	}
+}
+
+func TestBuildManifestError(t *testing.T) {
+	doc, err := Parse([]byte(`<doc>`+strings.Repeat("x", 200)+`</doc>`), WithContentSpill(100, failStore{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := BuildManifest(map[string]*Element{"a.xml": doc}); m != nil || err == nil || !strings.Contains(err.Error(), "a.xml") {
+		t.Errorf("BuildManifest returned %v, %v", m, err)
+	}
+}
@@ -1,28 +1,14 @@
 package xmltree
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/xml"
 	"io"
 	"strings"
-	"text/template"
+	"unicode/utf8"
 )
 
-// NOTE(droyo) As of go1.5.1, the encoding/xml package does not resolve
-// prefixes in attribute names. Therefore we add .Name.Space verbatim
-// instead of trying to resolve it. One consequence is this is that we cannot
-// rename prefixes without some work.
-var tagTmpl = template.Must(template.New("Marshal XML tags").Parse(
-	`{{define "start" -}}
-	<{{.Scope.Prefix .Name -}}
-	{{range .StartElement.Attr}} {{$.Scope.Prefix .Name -}}="{{.Value}}"{{end -}}
-	{{range .NS }} xmlns{{ if .Local }}:{{ .Local }}{{end}}="{{ .Space }}"{{end -}}
-	{{if or .Children .Content}}>{{else}} />{{end}}
-	{{- end}}
-
-	{{define "end" -}}
-	</{{.Prefix .Name}}>{{end}}`))
-
 type vContentMapping struct {
 	Decoded string
 	Encoded string
@@ -72,11 +58,14 @@ func xmlDecodeString(strToDecode string) (string, error) {
 //
 // The return value of Marshal will use the utf-8 encoding regardless of
 // the original encoding of the source document.
-func Marshal(el *Element) []byte {
+//
+// Marshal returns nil if encoding fails, as when the content of an
+// element cannot be read from its ContentStore, or an EncodeOption
+// reports an error. Use Encode or MarshalAppend to learn the cause.
+func Marshal(el *Element, opts ...EncodeOption) []byte {
 	var buf bytes.Buffer
-	if err := Encode(&buf, el); err != nil {
-		// bytes.Buffer.Write should never return an error
-		panic(err)
+	if err := Encode(&buf, el, opts...); err != nil {
+		return nil
 	}
 	return buf.Bytes()
 }
@@ -86,36 +75,356 @@ func Marshal(el *Element) []byte {
 // followed by zero or more copies of indent according to the
 // nesting depth.
 func MarshalIndent(el *Element, prefix, indent string) []byte {
-	var buf bytes.Buffer
-	enc := encoder{
-		w:      &buf,
-		prefix: prefix,
-		indent: indent,
-		pretty: true,
-	}
-	if err := enc.encode(el, nil, make(map[*Element]struct{})); err != nil {
-		panic(err)
-	}
-	return buf.Bytes()
+	return Marshal(el, WithIndent(prefix, indent))
 }
 
 // Encode writes the XML encoding of the Element to w.
 // Encode returns any errors encountered writing to w.
-func Encode(w io.Writer, el *Element) error {
-	enc := encoder{w: w}
-	return enc.encode(el, nil, make(map[*Element]struct{}))
+func Encode(w io.Writer, el *Element, opts ...EncodeOption) error {
+	if buf, ok := w.(*bytes.Buffer); ok {
+		return EncodeTo(buf, el, opts...)
+	}
+	bw := bufio.NewWriter(w)
+	enc := encoder{w: bw}
+	for _, opt := range opts {
+		opt(&enc)
+	}
+	if err := enc.run(el); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// An EncodeOption modifies the output of Marshal and Encode.
+type EncodeOption func(*encoder)
+
+// WithIndent adds line breaks for each successive element, in the
+// same manner as MarshalIndent.
+func WithIndent(prefix, indent string) EncodeOption {
+	return func(e *encoder) {
+		e.prefix, e.indent = prefix, indent
+		e.pretty = true
+	}
+}
+
+// WithAlignedAttrs pads the attributes of sibling elements that share
+// the same name so that their values line up in columns, producing a
+// tabular layout for hand-edited data files. Attributes are aligned
+// by position; it is most useful in combination with WithIndent.
+func WithAlignedAttrs() EncodeOption {
+	return func(e *encoder) {
+		e.align = true
+	}
+}
+
+// WithOmitEmpty drops elements that have no attributes, no content,
+// and no children from the output, so that a tree may be built
+// optimistically and cleaned up when it is serialized. An element
+// whose children are all dropped is itself considered empty. If any
+// selectors are given, only elements matching one of them, relative
+// to the element being encoded, are dropped; see Selector for the
+// syntax. The root element is never dropped.
+func WithOmitEmpty(selectors ...string) EncodeOption {
+	return func(e *encoder) {
+		e.omitEmpty = true
+		for _, expr := range selectors {
+			sel, err := CompileSelector(expr)
+			if err != nil {
+				e.err = err
+				return
+			}
+			e.omitOnly = append(e.omitOnly, sel)
+		}
+	}
+}
+
+// WithFilter calls fn for each element before it is written. If fn
+// returns nil, the element and its descendants are left out of the
+// output; otherwise the returned element is written in its place,
+// and its own children are passed to fn in turn. fn may return its
+// argument unchanged. This allows a single tree to be encoded
+// differently for each caller, for example depending on the
+// permissions of the user it is sent to, without copying it first.
+// fn must not modify the tree while it is being encoded.
+func WithFilter(fn func(el *Element) *Element) EncodeOption {
+	return func(e *encoder) {
+		e.filter = fn
+	}
+}
+
+// WithParsePrefixes writes the name of each element with the
+// namespace prefix it had in the parsed document, rather than the
+// closest prefix bound to its namespace, for consumers that are
+// sensitive to prefixes as well as namespaces. The recorded prefix is
+// used only if it is still bound to the element's namespace in its
+// Scope; otherwise, and for elements that were not parsed, the
+// prefix is chosen as usual. The same applies to the prefixes of
+// attribute names.
+func WithParsePrefixes() EncodeOption {
+	return func(e *encoder) {
+		e.parsePrefixes = true
+	}
+}
+
+// EncodeTo appends the XML encoding of the Element to buf. Callers
+// that encode many elements may reuse buf to avoid allocating a new
+// buffer for each one, as Marshal does.
+func EncodeTo(buf *bytes.Buffer, el *Element, opts ...EncodeOption) error {
+	enc := encoder{w: buf}
+	for _, opt := range opts {
+		opt(&enc)
+	}
+	return enc.run(el)
+}
+
+// MarshalAppend appends the XML encoding of the Element to dst and
+// returns the extended buffer, in the manner of strconv.AppendInt.
+// If dst has sufficient capacity, no allocation is needed.
+func MarshalAppend(dst []byte, el *Element, opts ...EncodeOption) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := EncodeTo(buf, el, opts...); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the XML encoding of the Element to w, implementing
+// the io.WriterTo interface. It returns the number of bytes written.
+func (el *Element) WriteTo(w io.Writer) (int64, error) {
+	cw := countWriter{w: w}
+	err := Encode(&cw, el)
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// EncodedSize returns the length, in bytes, of the XML encoding of
+// the Element with the given options, without producing it. It may
+// be used to set a Content-Length header, preallocate a buffer, or
+// enforce a size limit before encoding. It returns the same errors
+// as Encode would, such as a failure to read spilled content.
+//
+// A WithEncodeProgress callback is not called. The create function
+// given to WithXInclude is, as the reference written for each
+// externalized element depends on the href it returns; the size
+// covers the main document only.
+func (el *Element) EncodedSize(opts ...EncodeOption) (int64, error) {
+	var sw sizeWriter
+	enc := encoder{w: &sw}
+	for _, opt := range opts {
+		opt(&enc)
+	}
+	enc.progress = nil
+	err := enc.run(el)
+	return int64(sw), err
+}
+
+// A sizeWriter discards its input, counting the bytes written.
+type sizeWriter int
+
+func (s *sizeWriter) Write(p []byte) (int, error) {
+	*s += sizeWriter(len(p))
+	return len(p), nil
+}
+
+func (s *sizeWriter) WriteString(str string) (int, error) {
+	*s += sizeWriter(len(str))
+	return len(str), nil
+}
+
+func (s *sizeWriter) WriteByte(byte) error {
+	*s++
+	return nil
 }
 
 // String returns the XML encoding of an Element
-// and its children as a string.
+// and its children as a string, or the empty string
+// if Marshal would return nil.
 func (el *Element) String() string {
 	return string(Marshal(el))
 }
 
+// A writer is implemented by both *bytes.Buffer and *bufio.Writer.
+// Errors are not checked on each write; bytes.Buffer does not return
+// them, and bufio.Writer returns the first error from Flush.
+type writer interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
 type encoder struct {
-	w              io.Writer
+	w              writer
 	prefix, indent string
 	pretty         bool
+
+	// Column widths of attributes, by parent element and the
+	// name of the sibling elements, when aligning attributes.
+	align   bool
+	columns map[*Element]map[xml.Name][]int
+
+	// Elements dropped by WithOmitEmpty
+	omitEmpty bool
+	omitOnly  []*Selector
+	omitted   map[*Element]bool
+
+	// Elements as rewritten by WithFilter
+	filter   func(*Element) *Element
+	filtered map[*Element]*Element
+
+	// Set by WithEncodeProgress
+	progress *progress
+	counter  *progressWriter
+
+	// Namespaces whose content is preserved, for WithInlineSafe
+	inline map[string]bool
+
+	// Set by WithParsePrefixes
+	parsePrefixes bool
+
+	// Set by WithAutoCDATA
+	cdataMin   int
+	cdataRatio float64
+
+	// Set by WithXInclude
+	xinclude *xinclude
+
+	// Set by WithHoistNamespaces; the prefixes declared on the
+	// root element, and the root's declarations.
+	hoist       bool
+	hoisted     map[string]string
+	hoistedRoot Scope
+
+	// Set by WithRenamePrefixes; prefixes renamed when hoisting,
+	// by the binding they replace.
+	renamed      map[xml.Name]string
+	renameReport func(PrefixRename)
+	reported     map[PrefixRename]bool
+
+	// The first error from an EncodeOption
+	err error
+}
+
+// run encodes the tree rooted at el.
+func (e *encoder) run(el *Element) error {
+	el, err := e.prepare(el)
+	if err != nil || el == nil {
+		return err
+	}
+	if e.progress == nil {
+		return e.encode(el, nil, make(map[*Element]struct{}))
+	}
+	e.counter = &progressWriter{writer: e.w}
+	e.w = e.counter
+	if err := e.encode(el, nil, make(map[*Element]struct{})); err != nil {
+		return err
+	}
+	return e.progress.finish(e.counter.n)
+}
+
+// prepare readies the encoder to write the tree rooted at el, and
+// returns the element to write in its place, which is nil if
+// WithFilter drops it.
+func (e *encoder) prepare(el *Element) (*Element, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.omitEmpty {
+		e.findOmitted(el)
+	}
+	if e.xinclude != nil {
+		e.findIncluded(el)
+	}
+	if e.filter != nil {
+		e.filtered = make(map[*Element]*Element)
+		el = e.visible(el)
+	}
+	// A DocumentWriter prepares each element it appends; the
+	// declarations were hoisted with the root.
+	if e.hoist && el != nil && e.hoisted == nil {
+		if err := e.hoistNamespaces(el); err != nil {
+			return nil, err
+		}
+	}
+	return el, nil
+}
+
+// findOmitted records the elements below root that WithOmitEmpty
+// should drop.
+func (e *encoder) findOmitted(root *Element) {
+	e.omitted = make(map[*Element]bool)
+	var eligible map[*Element]bool
+	if len(e.omitOnly) > 0 {
+		eligible = make(map[*Element]bool)
+		for _, sel := range e.omitOnly {
+			for _, el := range sel.MatchAll(root) {
+				eligible[el] = true
+			}
+		}
+	}
+	var visit func(el, parent *Element, depth int) bool
+	visit = func(el, parent *Element, depth int) bool {
+		// Text, comments and processing instructions within el are
+		// content too.
+		empty := len(el.StartElement.Attr) == 0 && len(diffScope(parent, el).ns) == 0 &&
+			!e.isInline(parent) && !e.isInline(el) && !el.hasInnerMisc()
+		if len(el.Children) == 0 {
+			empty = empty && !el.hasContent()
+		}
+		for i := range el.Children {
+			if depth > recursionLimit || !visit(&el.Children[i], el, depth+1) {
+				empty = false
+			}
+		}
+		if empty && (eligible == nil || eligible[el]) {
+			e.omitted[el] = true
+			return true
+		}
+		return false
+	}
+	for i := range root.Children {
+		visit(&root.Children[i], root, 0)
+	}
+}
+
+// visible returns the element to write in place of el, or nil if
+// el is dropped by WithOmitEmpty or WithFilter.
+func (e *encoder) visible(el *Element) *Element {
+	if e.omitted[el] {
+		return nil
+	}
+	if e.filter == nil {
+		return el
+	}
+	if v, ok := e.filtered[el]; ok {
+		return v
+	}
+	v := e.filter(el)
+	e.filtered[el] = v
+	return v
+}
+
+// hasChildren reports whether any of the children of el will be
+// written.
+func (e *encoder) hasChildren(el *Element) bool {
+	if e.omitted == nil && e.filter == nil {
+		return len(el.Children) > 0
+	}
+	for i := range el.Children {
+		if e.visible(&el.Children[i]) != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // This could be used to print a subset of an XML document, or a document
@@ -130,31 +439,80 @@ func (e *encoder) encode(el, parent *Element, visited map[*Element]struct{}) err
 	}
 	if _, ok := visited[el]; ok {
 		// We have a cycle. Leave a comment, but no error
-		e.w.Write([]byte("<!-- cycle detected -->"))
+		e.w.WriteString("<!-- cycle detected -->")
 		return nil
 	}
-	scope := diffScope(parent, el)
-	if err := e.encodeOpenTag(el, scope, len(visited)); err != nil {
+	if e.pretty && (e.isInline(el) || el.hasText()) {
+		// Indent the element itself, but nothing within it.
+		for i := 0; i < len(visited); i++ {
+			e.w.WriteString(e.indent)
+		}
+		e.pretty = false
+		err := e.encode(el, parent, visited)
+		e.pretty = true
+		e.w.WriteByte('\n')
 		return err
 	}
+	if e.xinclude != nil && e.xinclude.matched[el] {
+		delete(e.xinclude.matched, el)
+		return e.encodeIncluded(el, len(visited))
+	}
+	if parent == nil {
+		if err := e.encodeProlog(el); err != nil {
+			return err
+		}
+	}
+	scope := e.scope(el, parent)
+	if err := e.encodeOpenTag(el, parent, scope, len(visited)); err != nil {
+		return err
+	}
+	if e.progress != nil {
+		if err := e.progress.update(e.counter.n); err != nil {
+			return err
+		}
+	}
+	if len(el.Children) > 0 && !e.hasChildren(el) && !el.hasInnerMisc() {
+		// All children were dropped; the tag is self-closing.
+		return nil
+	}
+	misc := 0 // next entry of el.Misc to write
+	var err error
 	if len(el.Children) == 0 {
-		if len(el.Content) > 0 {
-			mStr, mErr := xmlEncodeString(string(el.Content))
-			if mErr != nil {
-				return mErr
+		// Markup within text is written before it.
+		if misc, err = e.encodeMisc(el, misc, len(el.Children), -1); err != nil {
+			return err
+		}
+		if el.spill != nil {
+			if err := e.encodeSpilled(el); err != nil {
+				return err
 			}
-			e.w.Write([]byte(mStr))
-		} else {
+		} else if len(el.Content) > 0 && (el.CDATA || e.wantCDATA(el.Content)) {
+			writeCDATA(e.w, el.Content)
+		} else if len(el.Content) > 0 {
+			escapeText(e.w, el.Content)
+		} else if !el.hasInnerMisc() {
 			return nil
 		}
 	}
 	for i := range el.Children {
+		if misc, err = e.encodeMisc(el, misc, i, len(visited)+1); err != nil {
+			return err
+		}
+		child := e.visible(&el.Children[i])
+		if child == nil {
+			continue
+		}
 		visited[el] = struct{}{}
-		if err := e.encode(&el.Children[i], el, visited); err != nil {
+		if err := e.encode(child, el, visited); err != nil {
 			return err
 		}
 		delete(visited, el)
 	}
+	if len(el.Children) > 0 {
+		if _, err := e.encodeMisc(el, misc, len(el.Children), len(visited)+1); err != nil {
+			return err
+		}
+	}
 	if err := e.encodeCloseTag(el, len(visited)); err != nil {
 		return err
 	}
@@ -180,72 +538,181 @@ func diffScope(parent, child *Element) Scope {
 	return childScope
 }
 
-func (e *encoder) encodeOpenTag(el *Element, scope Scope, depth int) error {
+func (e *encoder) encodeOpenTag(el, parent *Element, scope Scope, depth int) error {
+	e.encodeTagStart(el, parent, scope, depth)
+	hasChildren := e.hasChildren(el)
+	open := hasChildren || len(el.Children) == 0 && el.hasContent() || el.hasInnerMisc()
+	if open {
+		e.w.WriteByte('>')
+	} else {
+		e.w.WriteString(" />")
+	}
 	if e.pretty {
-		for i := 0; i < depth; i++ {
-			io.WriteString(e.w, e.indent)
+		if hasChildren || !open {
+			e.w.WriteByte('\n')
 		}
 	}
-	// Note that a copy of el is used here so that XML encoded attributes are generated
-	var elCopy *Element = &Element{}
-	elCopy.StartElement = xml.StartElement{}
-	elCopy.StartElement.Name = el.StartElement.Name
-	elCopy.StartElement.Attr = make([]xml.Attr, len(el.StartElement.Attr))
-	for i := 0; i < len(el.StartElement.Attr); i++ {
-		elCopy.StartElement.Attr[i] = el.StartElement.Attr[i]
-	}
-	elCopy.Scope = el.Scope
-	// Escape node contents
-	{
-		mStr, mErr := xmlEncodeString(string(el.Content))
-		if mErr != nil {
-			return mErr
+	return nil
+}
+
+// encodeTagStart writes the start tag of el, with its attributes and
+// namespace declarations, up to but not including the closing '>'.
+func (e *encoder) encodeTagStart(el, parent *Element, scope Scope, depth int) {
+	if e.pretty {
+		for i := 0; i < depth; i++ {
+			e.w.WriteString(e.indent)
 		}
-		elCopy.Content = []byte(mStr)
 	}
-	elCopy.Children = el.Children
+	var widths []int
+	if e.align && parent != nil {
+		widths = e.columnWidths(parent)[el.Name]
+	}
+	renames := e.tagRenames(el)
+	e.w.WriteByte('<')
+	e.w.WriteString(e.elementName(el, renames))
 
-	var tag = struct {
-		*Element
-		NS []xml.Name
-	}{Element: elCopy, NS: scope.ns}
+	// NOTE(droyo) As of go1.5.1, the encoding/xml package does not resolve
+	// prefixes in attribute names. Therefore we add .Name.Space verbatim
+	// instead of trying to resolve it. One consequence is this is that we cannot
+	// rename prefixes without some work.
+	for i, a := range el.StartElement.Attr {
+		name := e.attrName(el, a.Name, renames)
+		e.w.WriteByte(' ')
+		e.w.WriteString(name)
+		e.w.WriteString(`="`)
+		escapeString(e.w, a.Value)
+		e.w.WriteByte('"')
 
-	// XML escape attribute strings held in copy
-	attrs := tag.StartElement.Attr
-	for i := 0; i < len(attrs); i++ {
-		attrStr := attrs[i].Value
-		mStr, mErr := xmlEncodeString(attrStr)
-		if mErr != nil {
-			return mErr
+		// The last attribute is not padded, unless namespace
+		// declarations follow it.
+		if i < len(widths) && (i < len(el.StartElement.Attr)-1 || len(scope.ns)+len(renames) > 0) {
+			for pad := widths[i] - attrWidth(name, a.Value); pad > 0; pad-- {
+				e.w.WriteByte(' ')
+			}
 		}
-		attrs[i].Value = mStr
 	}
-	tag.StartElement.Attr = attrs
+	for _, ns := range append(scope.ns[:len(scope.ns):len(scope.ns)], renames...) {
+		e.w.WriteString(" xmlns")
+		if ns.Local != "" {
+			e.w.WriteByte(':')
+			e.w.WriteString(ns.Local)
+		}
+		e.w.WriteString(`="`)
+		escapeString(e.w, ns.Space)
+		e.w.WriteByte('"')
+	}
+}
 
-	if err := tagTmpl.ExecuteTemplate(e.w, "start", tag); err != nil {
-		return err
+// elementName returns the qualified name to write for el, given the
+// declarations returned by tagRenames.
+func (e *encoder) elementName(el *Element, renames []xml.Name) string {
+	if e.parsePrefixes {
+		for i := len(el.ns) - 1; i >= 0; i-- {
+			if el.ns[i].Local != el.prefix {
+				continue
+			}
+			if el.ns[i].Space != el.Name.Space {
+				break
+			}
+			if el.prefix == "" {
+				return el.Name.Local
+			}
+			return el.prefix + ":" + el.Name.Local
+		}
 	}
-	if e.pretty {
-		if len(el.Children) > 0 || len(el.Content) == 0 {
-			io.WriteString(e.w, "\n")
+	return e.qualify(el, el.Name, renames)
+}
+
+// attrName returns the qualified name to write for the attribute
+// name of el, in the same manner as elementName.
+func (e *encoder) attrName(el *Element, name xml.Name, renames []xml.Name) string {
+	if e.parsePrefixes {
+		for _, p := range el.attrPrefixes {
+			if p.Name != name {
+				continue
+			}
+			if uri, ok := el.binding(p.Value); ok && uri == name.Space {
+				return p.Value + ":" + name.Local
+			}
+			break
 		}
 	}
-	return nil
+	return e.qualifyAttr(el, name, renames)
 }
 
 func (e *encoder) encodeCloseTag(el *Element, depth int) error {
 	if e.pretty {
 		for i := 0; i < depth; i++ {
-			if len(el.Children) > 0 {
-				io.WriteString(e.w, e.indent)
+			if e.hasChildren(el) {
+				e.w.WriteString(e.indent)
 			}
 		}
 	}
-	if err := tagTmpl.ExecuteTemplate(e.w, "end", el); err != nil {
-		return err
-	}
+	e.w.WriteString("</")
+	e.w.WriteString(e.elementName(el, e.tagRenames(el)))
+	e.w.WriteByte('>')
 	if e.pretty {
-		io.WriteString(e.w, "\n")
+		e.w.WriteByte('\n')
 	}
 	return nil
 }
+
+// encodeSpilled writes the content of an element that was moved to
+// a ContentStore.
+func (e *encoder) encodeSpilled(el *Element) error {
+	r, err := el.ContentReader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		escapeText(e.w, buf[:n])
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// attrWidth is the number of characters an attribute occupies in
+// a start tag, with its value escaped.
+func attrWidth(name, value string) int {
+	n := utf8.RuneCountInString(name) + utf8.RuneCountInString(value) + len(`=""`)
+	for i := 0; i < len(value); i++ {
+		if esc := escapeChar(value[i]); esc != "" {
+			n += len(esc) - 1
+		}
+	}
+	return n
+}
+
+// columnWidths calculates, for each distinct element name among the
+// children of parent, the width of the widest attribute at each
+// position. Results are cached for the lifetime of the encoder.
+func (e *encoder) columnWidths(parent *Element) map[xml.Name][]int {
+	if cols, ok := e.columns[parent]; ok {
+		return cols
+	}
+	cols := make(map[xml.Name][]int)
+	for i := range parent.Children {
+		child := &parent.Children[i]
+		widths := cols[child.Name]
+		for j, a := range child.StartElement.Attr {
+			w := attrWidth(child.Prefix(a.Name), a.Value)
+			if j == len(widths) {
+				widths = append(widths, w)
+			} else if w > widths[j] {
+				widths[j] = w
+			}
+		}
+		cols[child.Name] = widths
+	}
+	if e.columns == nil {
+		e.columns = make(map[*Element]map[xml.Name][]int)
+	}
+	e.columns[parent] = cols
+	return cols
+}
@@ -1,9 +1,12 @@
 package xmltree_test
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/mdejong/xmltree"
@@ -295,3 +298,245 @@ func TestXMLParseEscapedAmpersandQuotedAttributeWithXMLTreeReadOnly(t *testing.T
 		}
 	}
 }
+
+// Attributes of same-named siblings are aligned in columns
+
+func TestMarshalAlignedAttrs(t *testing.T) {
+	xmlBytes := []byte(`<rows><row id="1" name="a" /><row id="10" name="bbb" /><other x="1" /><row id="100" name="c" /></rows>`)
+
+	rootNode, err := xmltree.Parse(xmlBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlOutBytes := xmltree.Marshal(rootNode, xmltree.WithIndent("", "  "), xmltree.WithAlignedAttrs())
+
+	{
+		have := string(xmlOutBytes)
+		want := "<rows>\n" +
+			"  <row id=\"1\"   name=\"a\" />\n" +
+			"  <row id=\"10\"  name=\"bbb\" />\n" +
+			"  <other x=\"1\" />\n" +
+			"  <row id=\"100\" name=\"c\" />\n" +
+			"</rows>\n"
+
+		if have != want {
+			t.Fatalf("!Match : want : have :\n-----\n%v\n-----\n%v\n-----", want, have)
+		}
+	}
+}
+
+// EncodeTo appends to the caller's buffer
+
+func TestEncodeTo(t *testing.T) {
+	rootNode, err := xmltree.Parse([]byte(`<module name="a&amp;b"><item>1</item></module>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("prefix:")
+	if err := xmltree.EncodeTo(&buf, rootNode); err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		have := buf.String()
+		want := `prefix:<module name="a&amp;b"><item>1</item></module>`
+
+		if have != want {
+			t.Fatalf("!Match : want : have :\n-----\n%v\n-----\n%v\n-----", want, have)
+		}
+	}
+}
+
+func BenchmarkEncodeTo(b *testing.B) {
+	var doc bytes.Buffer
+	doc.WriteString("<records>")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&doc, `<record id="%d" type="t" owner="o&amp;p" rev="1">%d</record>`, i, i)
+	}
+	doc.WriteString("</records>")
+	rootNode, err := xmltree.Parse(doc.Bytes())
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := xmltree.EncodeTo(&buf, rootNode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// MarshalAppend reuses the caller's slice
+
+func TestMarshalAppend(t *testing.T) {
+	rootNode, err := xmltree.Parse([]byte(`<module name="x"><item>&lt;1&gt;</item></module>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := make([]byte, 0, 256)
+	dst = append(dst, "head:"...)
+	out, err := xmltree.MarshalAppend(dst, rootNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &out[0] != &dst[0] {
+		t.Errorf("MarshalAppend allocated a new slice despite sufficient capacity")
+	}
+
+	{
+		have := string(out)
+		want := `head:<module name="x"><item>&lt;1&gt;</item></module>`
+
+		if have != want {
+			t.Fatalf("!Match : want : have :\n-----\n%v\n-----\n%v\n-----", want, have)
+		}
+	}
+}
+
+// EncodedSize matches the length of the encoding
+
+func TestEncodedSize(t *testing.T) {
+	rootNode, err := xmltree.Parse([]byte(`<a x="&quot;"><b>&amp;</b><c/></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]xmltree.EncodeOption{
+		nil,
+		{xmltree.WithIndent("", "  ")},
+		{xmltree.WithIndent("", "\t"), xmltree.WithAlignedAttrs()},
+	} {
+		have, err := rootNode.EncodedSize(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := int64(len(xmltree.Marshal(rootNode, opts...)))
+		if have != want {
+			t.Errorf("EncodedSize = %d, want %d", have, want)
+		}
+	}
+}
+
+// WithOmitEmpty drops empty elements and subtrees
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	rootNode, err := xmltree.Parse([]byte(`<a><b/><c><d/><e></e></c><f x="1"/><g>text</g><h><i/></h></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		selectors []string
+		want      string
+	}{
+		{nil, `<a><f x="1" /><g>text</g></a>`},
+		{[]string{"c/*"}, `<a><b /><c /><f x="1" /><g>text</g><h><i /></h></a>`},
+		{[]string{"//*[@x]", "b", "//i"}, `<a><c><d /><e /></c><f x="1" /><g>text</g><h /></a>`},
+	}
+	for _, tt := range tests {
+		have := string(xmltree.Marshal(rootNode, xmltree.WithOmitEmpty(tt.selectors...)))
+		if have != tt.want {
+			t.Errorf("WithOmitEmpty(%q):\nhave %s\nwant %s", tt.selectors, have, tt.want)
+		}
+	}
+	rootNode, err = xmltree.Parse([]byte(`<a xmlns="urn:a"><b/><c xmlns:x="urn:x"/></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := string(xmltree.Marshal(rootNode, xmltree.WithOmitEmpty()))
+	if want := `<a xmlns="urn:a"><c xmlns:x="urn:x" /></a>`; have != want {
+		t.Errorf("WithOmitEmpty with namespaces:\nhave %s\nwant %s", have, want)
+	}
+	rootNode, err = xmltree.Parse([]byte(`<r><p>hello <b></b></p><q><!--keep me--></q><s> </s><t/></r>`),
+		xmltree.WithMixedContent(), xmltree.WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	have = string(xmltree.Marshal(rootNode, xmltree.WithOmitEmpty()))
+	if want := `<r><p>hello </p><q><!--keep me--></q><s> </s></r>`; have != want {
+		t.Errorf("WithOmitEmpty with mixed content:\nhave %s\nwant %s", have, want)
+	}
+	if err := xmltree.Encode(io.Discard, rootNode, xmltree.WithOmitEmpty("[")); err == nil {
+		t.Error("invalid selector accepted")
+	}
+}
+
+// WithFilter can drop or rewrite elements as they are encoded
+
+func TestMarshalFilter(t *testing.T) {
+	rootNode, err := xmltree.Parse([]byte(`<user><name>ada</name><secret>x</secret><role>admin</role><audit><secret/></audit></user>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := xmltree.WithFilter(func(el *xmltree.Element) *xmltree.Element {
+		switch el.Name.Local {
+		case "secret":
+			return nil
+		case "role":
+			masked := *el
+			masked.Content = []byte("***")
+			return &masked
+		}
+		return el
+	})
+	have := string(xmltree.Marshal(rootNode, filter))
+	want := `<user><name>ada</name><role>***</role><audit /></user>`
+	if have != want {
+		t.Errorf("have %s\nwant %s", have, want)
+	}
+	if string(rootNode.Children[2].Content) != "admin" {
+		t.Error("filter modified the tree")
+	}
+	if n, err := rootNode.EncodedSize(filter); err != nil || n != int64(len(want)) {
+		t.Errorf("EncodedSize = %d, want %d", n, len(want))
+	}
+	drop := xmltree.WithFilter(func(*xmltree.Element) *xmltree.Element { return nil })
+	if have := xmltree.Marshal(rootNode, drop); len(have) != 0 {
+		t.Errorf("dropping the root produced %s", have)
+	}
+}
+
+func TestMarshalParsePrefixes(t *testing.T) {
+	doc := `<a:root xmlns:a="urn:x" xmlns:b="urn:x"><b:item/><a:item xmlns="urn:y"><c/></a:item></a:root>`
+	rootNode, err := xmltree.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := string(xmltree.Marshal(rootNode, xmltree.WithParsePrefixes()))
+	want := `<a:root xmlns:a="urn:x" xmlns:b="urn:x"><b:item /><a:item xmlns="urn:y"><c /></a:item></a:root>`
+	if have != want {
+		t.Errorf("have %s\nwant %s", have, want)
+	}
+	if have := string(xmltree.Marshal(rootNode)); have == want {
+		t.Errorf("prefixes preserved without WithParsePrefixes: %s", have)
+	}
+
+	// a prefix that no longer refers to the element's namespace is
+	// not used
+	rootNode.Children[0].Name.Space = ""
+	have = string(xmltree.Marshal(rootNode, xmltree.WithParsePrefixes()))
+	if strings.Contains(have, "<b:item") {
+		t.Errorf("stale prefix used: %s", have)
+	}
+}
+
+func TestMarshalParsePrefixesAttrs(t *testing.T) {
+	doc := `<Signature xmlns="urn:sig" xmlns:ds="urn:sig" xmlns:wsu="urn:util" xmlns:u="urn:util">` +
+		`<Reference ds:Id="r1" u:Id="u1" URI="#x"/><ds:Object wsu:Id="o1" xml:lang="en"/></Signature>`
+	rootNode, err := xmltree.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<Signature xmlns="urn:sig" xmlns:ds="urn:sig" xmlns:u="urn:util" xmlns:wsu="urn:util">` +
+		`<Reference ds:Id="r1" u:Id="u1" URI="#x" /><ds:Object wsu:Id="o1" xml:lang="en" /></Signature>`
+	if have := string(xmltree.Marshal(rootNode, xmltree.WithParsePrefixes())); have != want {
+		t.Errorf("have %s\nwant %s", have, want)
+	}
+	if have := string(xmltree.Marshal(rootNode)); strings.Contains(have, " u:Id") {
+		t.Errorf("attribute prefixes preserved without WithParsePrefixes: %s", have)
+	}
+}
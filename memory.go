@@ -0,0 +1,111 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"unsafe"
+)
+
+// Sizes used to estimate the memory held by a tree.
+const (
+	elementSize = int64(unsafe.Sizeof(Element{}))
+	attrSize    = int64(unsafe.Sizeof(xml.Attr{}))
+	nameSize    = int64(unsafe.Sizeof(xml.Name{}))
+	miscSize    = int64(unsafe.Sizeof(Misc{}))
+)
+
+// ErrMemoryLimit is wrapped by the *LimitError returned by Parse when
+// the tree being built exceeds the limit set with WithMaxMemory.
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// WithMaxMemory limits the memory, in bytes, that Parse may use for
+// the tree it builds, as estimated by MemoryFootprint. If the limit is
+// exceeded, Parse stops and returns a *LimitError wrapping
+// ErrMemoryLimit. The memory used by the input document and by the
+// decoder is not counted.
+func WithMaxMemory(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxMemory = n
+	}
+}
+
+// MemoryFootprint estimates the number of bytes retained by the tree
+// rooted at el: the Elements themselves, their attributes, names,
+// content, Misc items and namespace scopes. Strings shared between elements,
+// such as names interned by Parse, are counted once for each use, so
+// the estimate errs on the high side. Content moved to a
+// ContentStore by WithContentSpill is not counted.
+func (el *Element) MemoryFootprint() int64 {
+	scopes := make(map[*xml.Name]bool)
+	n := elementSize
+	for _, e := range append([]*Element{el}, el.Flatten()...) {
+		n += e.footprint()
+		if len(e.ns) > 0 && !scopes[&e.ns[0]] {
+			scopes[&e.ns[0]] = true
+			n += int64(cap(e.ns)) * nameSize
+			for _, ns := range e.ns {
+				n += int64(len(ns.Space) + len(ns.Local))
+			}
+		}
+	}
+	return n
+}
+
+// MemoryFootprint estimates the number of bytes retained by the
+// Document, in the same manner as Element.MemoryFootprint.
+func (d *Document) MemoryFootprint() int64 {
+	if d.Root == nil {
+		return 0
+	}
+	return d.Root.Element().MemoryFootprint()
+}
+
+// footprint estimates the memory held by el itself, apart from its
+// Element struct and namespace scope, including the slice of its
+// children's Element structs and its Misc items.
+func (el *Element) footprint() int64 {
+	n := el.startFootprint()
+	n += int64(cap(el.Content))
+	n += int64(cap(el.Children)) * elementSize
+	n += miscFootprint(el.Misc)
+	return n
+}
+
+// miscFootprint estimates the memory held by the slice misc and the
+// text of its items.
+func miscFootprint(misc []Misc) int64 {
+	n := int64(cap(misc)) * miscSize
+	for _, m := range misc {
+		n += int64(cap(m.Data) + len(m.Target))
+	}
+	return n
+}
+
+// startFootprint estimates the memory held by the names and
+// attributes of el.
+func (el *Element) startFootprint() int64 {
+	n := int64(len(el.Name.Space) + len(el.Name.Local))
+	n += int64(cap(el.StartElement.Attr)) * attrSize
+	for _, a := range el.StartElement.Attr {
+		n += int64(len(a.Name.Space) + len(a.Name.Local) + len(a.Value))
+	}
+	return n
+}
+
+// account adds n bytes to the memory used by the tree being parsed,
+// returning a *LimitError if the WithMaxMemory limit is exceeded.
+func (s *scanner) account(el *Element, n int64) error {
+	if s.opts.maxMemory <= 0 {
+		return nil
+	}
+	s.memory += n
+	if s.memory > int64(s.opts.maxMemory) {
+		return &LimitError{
+			Err:     ErrMemoryLimit,
+			Limit:   s.opts.maxMemory,
+			Element: el.Name,
+			Offset:  s.offset(),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package xmltree
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMemoryFootprint(t *testing.T) {
+	small := parseDoc(t, recordDoc(10))
+	large := parseDoc(t, recordDoc(1000))
+	s, l := small.MemoryFootprint(), large.MemoryFootprint()
+	if s <= 0 || l < 50*s {
+		t.Errorf("footprints %d and %d do not scale with size", s, l)
+	}
+	if d := NewDocument(small); d.MemoryFootprint() <= 0 {
+		t.Error("Document footprint is zero")
+	}
+	if len(recordDoc(1000)) > int(l) {
+		t.Errorf("footprint %d is smaller than the document", l)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	doc := recordDoc(1000)
+	root := parseDoc(t, doc)
+	need := root.MemoryFootprint()
+	if _, err := Parse(doc, WithMaxMemory(int(need))); err != nil {
+		t.Errorf("parse within limit failed: %v", err)
+	}
+	_, err := Parse(doc, WithMaxMemory(int(need/2)))
+	var limitErr *LimitError
+	if !errors.Is(err, ErrMemoryLimit) || !errors.As(err, &limitErr) {
+		t.Fatalf("Parse returned %v", err)
+	}
+	if limitErr.Limit != int(need/2) || limitErr.Offset <= 0 {
+		t.Errorf("unexpected error details %+v", limitErr)
+	}
+}
+
+func TestMaxMemoryMisc(t *testing.T) {
+	big := strings.Repeat("x", 1<<20)
+	for _, doc := range []string{
+		`<a><!--` + big + `--></a>`,
+		`<!--` + big + `--><a/>`,
+		`<a><b/>` + big + `<b/></a>`,
+	} {
+		if _, err := Parse([]byte(doc), WithComments(), WithMixedContent(), WithMaxMemory(64<<10)); !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("%.20s...: Parse returned %v", doc, err)
+		}
+		root, err := Parse([]byte(doc), WithComments(), WithMixedContent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := root.MemoryFootprint(); n < 1<<20 {
+			t.Errorf("%.20s...: footprint %d", doc, n)
+		}
+	}
+}
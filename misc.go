@@ -0,0 +1,329 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A MiscKind identifies the kind of markup held by a Misc.
+type MiscKind int
+
+const (
+	// MiscComment is a comment; the Data field of the Misc holds
+	// the text between <!-- and -->.
+	MiscComment MiscKind = iota
+
+	// MiscProcInst is a processing instruction; the Target field
+	// of the Misc holds its target, and Data the instruction.
+	MiscProcInst
+
+	// MiscText is character data between the children of an
+	// element, in mixed content; Data holds the text, with entity
+	// references decoded.
+	MiscText
+)
+
+// A Misc is an item of markup other than an element, such as a
+// comment, processing instruction or run of text, in the Misc field
+// of an Element. Its position is given by Index, the number of the
+// element's Children that precede it; a Misc with an Index of
+// len(Children) or more follows the last child.
+// Within the text of an element that has no children, Misc items are
+// written before the text. A Misc with a negative Index comes before
+// the start tag of the element; Parse uses these for the comments
+// and processing instructions that precede the root element.
+//
+// The Misc field must be kept sorted by Index. When children are
+// added or removed directly, the Index of the items that follow them
+// must be adjusted to match.
+type Misc struct {
+	Kind   MiscKind
+	Index  int
+	Target string
+	Data   []byte
+}
+
+// WithComments causes Parse to keep the comments in a document, so
+// that they are written out again by Marshal and Encode. Comments are
+// stored in the Misc field of the element that contains them, or of
+// the root element if they precede it. Comments after the root
+// element are discarded.
+func WithComments() ParseOption {
+	return func(o *parseOptions) {
+		o.comments = true
+	}
+}
+
+// WithMixedContent causes Parse to keep the text between the
+// children of an element, so that mixed content such as
+//
+//	<p>hello <b>world</b> again</p>
+//
+// is written out again as it was read. Each run of text is stored in
+// the Misc field of its element, and can be visited in order with the
+// element's children using EachNode. The text of an element without
+// children is in its Content, as usual. White space between children
+// is kept too, so MarshalIndent does not indent within an element
+// that has any text items.
+func WithMixedContent() ParseOption {
+	return func(o *parseOptions) {
+		o.mixed = true
+	}
+}
+
+// EachNode calls fn for each child of el and each item in el.Misc,
+// other than those before its start tag, in document order. For a
+// child element, misc is nil; for a Misc item, child is nil. As when
+// encoding, Misc items in an element without children come before
+// its Content.
+//
+//	// the text of <p>hello <b>world</b> again</p>
+//	p.EachNode(func(child *xmltree.Element, misc *xmltree.Misc) {
+//		if child != nil {
+//			buf.Write(child.Content)
+//		} else if misc.Kind == xmltree.MiscText {
+//			buf.Write(misc.Data)
+//		}
+//	})
+func (el *Element) EachNode(fn func(child *Element, misc *Misc)) {
+	next := 0
+	each := func(index int) {
+		for ; next < len(el.Misc); next++ {
+			m := &el.Misc[next]
+			if m.Index > index && index < len(el.Children) {
+				return
+			}
+			if m.Index >= 0 {
+				fn(nil, m)
+			}
+		}
+	}
+	for i := range el.Children {
+		each(i)
+		fn(&el.Children[i], nil)
+	}
+	each(len(el.Children))
+}
+
+// WithProcInsts causes Parse to keep the processing instructions in
+// a document, such as <?xml-stylesheet?>, in the same manner as
+// WithComments keeps comments. The XML declaration is not kept; it
+// is not a processing instruction, although it looks like one.
+func WithProcInsts() ParseOption {
+	return func(o *parseOptions) {
+		o.procInsts = true
+	}
+}
+
+// misc returns the Misc item for the current token, if it is one
+// that should be kept, placed at index.
+func (s *scanner) misc(index int) (Misc, bool) {
+	switch tok := s.tok.(type) {
+	case xml.Comment:
+		if s.opts.comments {
+			return Misc{Kind: MiscComment, Index: index, Data: append([]byte(nil), tok...)}, true
+		}
+	case xml.ProcInst:
+		if s.opts.procInsts && tok.Target != "xml" {
+			return Misc{Kind: MiscProcInst, Index: index, Target: tok.Target, Data: append([]byte(nil), tok.Inst...)}, true
+		}
+	}
+	return Misc{}, false
+}
+
+// text records character data between the children of el, joining
+// it to the preceding text item if there is nothing between them.
+func (el *Element) text(data []byte, index int) {
+	if n := len(el.Misc); n > 0 && el.Misc[n-1].Kind == MiscText && el.Misc[n-1].Index == index {
+		el.Misc[n-1].Data = append(el.Misc[n-1].Data, data...)
+		return
+	}
+	el.Misc = append(el.Misc, Misc{Kind: MiscText, Index: index, Data: append([]byte(nil), data...)})
+}
+
+// dropText removes the text items of el, once it is known that el
+// has no children and its text is in Content.
+func (el *Element) dropText() {
+	misc := el.Misc[:0]
+	for _, m := range el.Misc {
+		if m.Kind != MiscText {
+			misc = append(misc, m)
+		}
+	}
+	if len(misc) == 0 {
+		misc = nil
+	}
+	el.Misc = misc
+}
+
+// hasText reports whether el has any text items.
+func (el *Element) hasText() bool {
+	for _, m := range el.Misc {
+		if m.Kind == MiscText {
+			return true
+		}
+	}
+	return false
+}
+
+// token returns the xml.Token for m.
+func (m *Misc) token() xml.Token {
+	switch m.Kind {
+	case MiscComment:
+		return xml.Comment(m.Data)
+	case MiscProcInst:
+		return xml.ProcInst{Target: m.Target, Inst: m.Data}
+	}
+	return xml.CharData(m.Data)
+}
+
+// hasInnerMisc reports whether el has any Misc items after its start
+// tag.
+func (el *Element) hasInnerMisc() bool {
+	for _, m := range el.Misc {
+		if m.Index >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// moveMisc moves each of the Misc items of el that precedes a child
+// to the position newIndex returns for the child, after the children
+// of el have been rearranged. Items after the last child are placed
+// at end. The Misc slice is copied, as it may be shared.
+func (el *Element) moveMisc(newIndex func(i int) int, end int) {
+	n := len(el.Children)
+	misc := make([]Misc, len(el.Misc))
+	for i, m := range el.Misc {
+		misc[i] = m
+		if m.Index >= n {
+			misc[i].Index = end
+		} else if m.Index >= 0 {
+			misc[i].Index = newIndex(m.Index)
+		}
+	}
+	sortMisc(misc)
+	el.Misc = misc
+}
+
+// sortMisc restores the order of Misc items by position, keeping the
+// order of items at the same position, and of those in the prolog.
+func sortMisc(misc []Misc) {
+	i := 0
+	for i < len(misc) && misc[i].Index < 0 {
+		i++
+	}
+	rest := misc[i:]
+	sort.SliceStable(rest, func(a, b int) bool { return rest[a].Index < rest[b].Index })
+}
+
+func cloneMisc(misc []Misc) []Misc {
+	if misc == nil {
+		return nil
+	}
+	dup := make([]Misc, len(misc))
+	for i, m := range misc {
+		dup[i] = m
+		dup[i].Data = append([]byte(nil), m.Data...)
+	}
+	return dup
+}
+
+// encodeProlog writes the Misc items that precede el, each on its
+// own line.
+func (e *encoder) encodeProlog(el *Element) error {
+	for _, m := range el.Misc {
+		if m.Index < 0 {
+			if err := e.encodeMiscItem(el, m); err != nil {
+				return err
+			}
+			e.w.WriteByte('\n')
+		}
+	}
+	return nil
+}
+
+// encodeMisc writes the items in el.Misc, starting at next, that come
+// before the child at index, and returns the position of the first
+// item not written. When pretty printing, each item is put on its
+// own line, indented to depth, unless depth is negative.
+func (e *encoder) encodeMisc(el *Element, next, index, depth int) (int, error) {
+	for ; next < len(el.Misc); next++ {
+		m := el.Misc[next]
+		if m.Index < 0 {
+			continue
+		}
+		if m.Index > index && index < len(el.Children) {
+			break
+		}
+		if e.pretty && depth >= 0 {
+			for i := 0; i < depth; i++ {
+				e.w.WriteString(e.indent)
+			}
+		}
+		if err := e.encodeMiscItem(el, m); err != nil {
+			return next, err
+		}
+		if e.pretty && depth >= 0 {
+			e.w.WriteByte('\n')
+		}
+	}
+	return next, nil
+}
+
+// check returns the error ValidateTree reports for m, with a
+// description of the problem, or nil if m can be written as it is.
+func (m *Misc) check() (string, error) {
+	if r, ok := invalidChar(m.Data); !ok {
+		return fmt.Sprintf("%U in Misc data", r), ErrInvalidChar
+	}
+	switch m.Kind {
+	case MiscComment:
+		if bytes.Contains(m.Data, []byte("--")) || bytes.HasSuffix(m.Data, []byte("-")) {
+			return fmt.Sprintf("comment %q", m.Data), ErrInvalidMarkup
+		}
+	case MiscProcInst:
+		if !IsValidName(m.Target) || strings.Contains(m.Target, ":") {
+			return fmt.Sprintf("processing instruction target %q", m.Target), ErrInvalidName
+		}
+		if strings.EqualFold(m.Target, "xml") {
+			return "processing instruction target \"xml\"", ErrInvalidName
+		}
+		if bytes.Contains(m.Data, []byte("?>")) {
+			return fmt.Sprintf("processing instruction %q", m.Data), ErrInvalidMarkup
+		}
+	case MiscText:
+	default:
+		return fmt.Sprintf("Misc kind %d", m.Kind), ErrInvalidMarkup
+	}
+	return "", nil
+}
+
+// encodeMiscItem writes m, or returns a *TreeError if it cannot be
+// written as well-formed XML.
+func (e *encoder) encodeMiscItem(el *Element, m Misc) error {
+	if detail, err := m.check(); err != nil {
+		return &TreeError{Path: el.path(), Err: err, Detail: detail}
+	}
+	switch m.Kind {
+	case MiscComment:
+		e.w.WriteString("<!--")
+		e.w.Write(m.Data)
+		e.w.WriteString("-->")
+	case MiscProcInst:
+		e.w.WriteString("<?")
+		e.w.WriteString(m.Target)
+		if len(m.Data) > 0 {
+			e.w.WriteByte(' ')
+			e.w.Write(m.Data)
+		}
+		e.w.WriteString("?>")
+	case MiscText:
+		escapeText(e.w, m.Data)
+	}
+	return nil
+}
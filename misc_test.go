@@ -0,0 +1,141 @@
+package xmltree
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithComments(t *testing.T) {
+	input := `<!-- header --><config>` +
+		`<!-- first --><a>1</a><!-- between --><b><!-- inner --></b>` +
+		`<c>x<!-- in text -->y</c><!-- last --></config>`
+	el, err := Parse([]byte(input), WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(el.Misc) != 4 {
+		t.Fatalf("root has %d Misc items, want 4: %+v", len(el.Misc), el.Misc)
+	}
+	if m := el.Misc[2]; m.Kind != MiscComment || m.Index != 1 || string(m.Data) != " between " {
+		t.Errorf("got %+v, want comment between the first two children", m)
+	}
+	want := "<!-- header -->\n<config>" +
+		`<!-- first --><a>1</a><!-- between --><b><!-- inner --></b>` +
+		`<c><!-- in text -->xy</c><!-- last --></config>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := string(Marshal(el.Clone())); got != want {
+		t.Errorf("clone marshals as %s", got)
+	}
+	if got := string(Marshal(NewDocument(el).Element())); got != want {
+		t.Errorf("Document marshals as %s", got)
+	}
+
+	indented := string(MarshalIndent(el, "", "  "))
+	if !strings.Contains(indented, "\n  <!-- between -->\n  <b>") {
+		t.Errorf("comment not indented:\n%s", indented)
+	}
+
+	plain, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain.Misc) != 0 || strings.Contains(string(Marshal(plain)), "<!-- first -->") {
+		t.Error("comments kept without WithComments")
+	}
+}
+
+func TestWithProcInsts(t *testing.T) {
+	input := `<?xml version="1.0"?>` + "\n" +
+		`<?xml-stylesheet type="text/xsl" href="style.xsl"?><!-- c -->` +
+		`<doc><?php echo 1; ?><p>a<?break?></p><?end?></doc>`
+	el, err := Parse([]byte(input), WithProcInsts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := el.Misc[0]; m.Kind != MiscProcInst || m.Target != "xml-stylesheet" || m.Index != -1 {
+		t.Errorf("got %+v, want xml-stylesheet before the root", m)
+	}
+	want := `<?xml-stylesheet type="text/xsl" href="style.xsl"?>` + "\n" +
+		`<doc><?php echo 1; ?><p><?break?>a</p><?end?></doc>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	el, err = Parse([]byte(input), WithProcInsts(), WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Marshal(el)); !strings.HasPrefix(got, `<?xml-stylesheet type="text/xsl" href="style.xsl"?>`+"\n<!-- c -->\n<doc>") {
+		t.Errorf("prolog not kept in order: %s", got)
+	}
+}
+
+func TestWithMixedContent(t *testing.T) {
+	input := `<doc><p>hello <b>world</b> &amp; <i>all</i><!-- c --> again</p><q>plain</q></doc>`
+	el, err := Parse([]byte(input), WithMixedContent(), WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Marshal(el)); got != input {
+		t.Errorf("got  %s\nwant %s", got, input)
+	}
+	if q := el.Child("", "q"); len(q.Misc) != 0 {
+		t.Errorf("leaf element has Misc items %+v", q.Misc)
+	}
+
+	var text strings.Builder
+	el.Child("", "p").EachNode(func(child *Element, misc *Misc) {
+		if child != nil {
+			text.WriteString("[" + string(child.Content) + "]")
+		} else if misc.Kind == MiscText {
+			text.Write(misc.Data)
+		}
+	})
+	if got := text.String(); got != "hello [world] & [all] again" {
+		t.Errorf("EachNode gave %q", got)
+	}
+
+	var v struct {
+		P struct {
+			Text string `xml:",chardata"`
+		} `xml:"p"`
+	}
+	if err := el.Unmarshal(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.P.Text != "hello  &  again" {
+		t.Errorf("Unmarshal gave chardata %q", v.P.Text)
+	}
+
+	indented := string(MarshalIndent(el, "", "  "))
+	if !strings.Contains(indented, "\n  <p>hello <b>world</b>") {
+		t.Errorf("mixed content reformatted:\n%s", indented)
+	}
+}
+
+func TestEncodeInvalidMisc(t *testing.T) {
+	tests := []Misc{
+		{Kind: MiscComment, Data: []byte(" x --> <evil/> <!-- ")},
+		{Kind: MiscComment, Data: []byte("dash-")},
+		{Kind: MiscProcInst, Target: "p", Data: []byte("?><inj/>")},
+		{Kind: MiscProcInst, Target: "xml", Data: []byte(`version="1.0"`)},
+		{Kind: MiscProcInst, Data: []byte("x")},
+		{Kind: MiscText, Data: []byte("bad \x01 char")},
+		{Kind: MiscKind(7)},
+	}
+	for _, m := range tests {
+		root := MustParse([]byte(`<a><b/></a>`))
+		root.Misc = []Misc{m}
+		if out, err := MarshalAppend(nil, root); err == nil {
+			t.Errorf("%+v: encoded as %s", m, out)
+		} else if !errors.As(err, new(*TreeError)) {
+			t.Errorf("%+v: got %v, want a *TreeError", m, err)
+		}
+		if errs := ValidateTree(root); len(errs) != 1 {
+			t.Errorf("%+v: ValidateTree returned %v", m, errs)
+		}
+	}
+}
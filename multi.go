@@ -0,0 +1,186 @@
+package xmltree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A MultiParser reads a stream of XML documents that follow one
+// another with no separator, such as the output of some log and
+// message queue exporters. Successive calls to the Next method
+// step through the documents in the stream.
+//
+//	docs := xmltree.ParseMulti(r)
+//	for docs.Next() {
+//		root := docs.Element()
+//		// ...
+//	}
+//	if err := docs.Err(); err != nil {
+//		// ...
+//	}
+type MultiParser struct {
+	d    *xml.Decoder
+	rec  recorder
+	base int64 // input offset of rec.buf[0]
+	el   *Element
+	err  error
+}
+
+// ParseMulti returns a MultiParser that reads XML documents from r.
+func ParseMulti(r io.Reader) *MultiParser {
+	m := &MultiParser{rec: recorder{r: bufio.NewReader(r)}}
+	m.d = xml.NewDecoder(&m.rec)
+	// Documents are only split here; character set conversion
+	// is done by Parse, so that the input offsets reported by
+	// the decoder match the recorded bytes.
+	m.d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		return r, nil
+	}
+	return m
+}
+
+// Next parses the next document in the stream, which is then
+// available through the Element method. Next returns false when
+// the end of the input is reached or an error occurs. Text other
+// than white space between or after the documents is an error
+// wrapping ErrTrailingContent.
+func (m *MultiParser) Next() bool {
+	m.el = nil
+	if m.err != nil {
+		return false
+	}
+	depth := 0
+	for {
+		tok, err := m.d.RawToken()
+		if err == io.EOF {
+			if depth > 0 {
+				m.err = io.ErrUnexpectedEOF
+			}
+			return false
+		} else if err != nil {
+			m.err = err
+			return false
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(tok)) > 0 {
+				m.err = fmt.Errorf("%w at offset %d", ErrTrailingContent, m.d.InputOffset())
+				return false
+			}
+		}
+		if depth == 0 {
+			if _, ok := tok.(xml.EndElement); ok {
+				break
+			}
+		}
+	}
+	end := int(m.d.InputOffset() - m.base)
+	doc := make([]byte, end)
+	copy(doc, m.rec.buf[:end])
+	m.rec.buf = append(m.rec.buf[:0], m.rec.buf[end:]...)
+	m.base += int64(end)
+
+	m.el, m.err = Parse(doc)
+	return m.err == nil
+}
+
+// Element returns the root element of the most recent document
+// parsed by Next.
+func (m *MultiParser) Element() *Element {
+	return m.el
+}
+
+// Err returns the first error encountered by Next. The end of the
+// input is not considered an error.
+func (m *MultiParser) Err() error {
+	return m.err
+}
+
+// A recorder is an io.ByteReader that saves every byte read from it.
+// Because it implements io.ByteReader, an xml.Decoder will not read
+// ahead of the tokens it returns.
+type recorder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, c)
+	}
+	return c, err
+}
+
+// ConcatDocuments returns a new element named root whose children are
+// the given documents, in order, for combining many documents, such
+// as per-shard reports, into one. The documents are copied with
+// Clone, so that the parent pointers of the result lead to its own
+// root, and the documents may be modified or released afterwards.
+//
+// Each document keeps its own namespace declarations, so documents
+// whose default namespaces conflict, with each other or with root,
+// are written with their own xmlns attributes. The namespace of root
+// is declared as the default namespace only if every document
+// declares a default namespace of its own; otherwise it is bound to
+// a prefix that no document declares, so that elements of the
+// documents that are in no namespace remain so.
+func ConcatDocuments(root xml.Name, docs ...*Element) *Element {
+	result := &Element{StartElement: xml.StartElement{Name: root}}
+	result.Children = make([]Element, 0, len(docs))
+	everyDefault := true
+	for _, doc := range docs {
+		result.Children = append(result.Children, *doc.Clone())
+		if _, ok := doc.binding(""); !ok {
+			everyDefault = false
+		}
+	}
+	result.LinkParents()
+	if root.Space == "" {
+		return result
+	}
+	prefix := ""
+	if !everyDefault {
+		prefix = "ns"
+		for i := 1; declaredIn(docs, prefix); i++ {
+			prefix = "ns" + strconv.Itoa(i)
+		}
+	}
+	result.ns = []xml.Name{{Space: root.Space, Local: prefix}}
+	return result
+}
+
+// binding returns the namespace bound to prefix in the scope, if any.
+func (scope *Scope) binding(prefix string) (string, bool) {
+	for i := len(scope.ns) - 1; i >= 0; i-- {
+		if scope.ns[i].Local == prefix {
+			return scope.ns[i].Space, true
+		}
+	}
+	return "", false
+}
+
+func declaredIn(docs []*Element, prefix string) bool {
+	for _, doc := range docs {
+		for _, el := range append([]*Element{doc}, doc.Flatten()...) {
+			if _, ok := el.binding(prefix); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
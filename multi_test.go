@@ -0,0 +1,94 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseMulti(t *testing.T) {
+	input := `<?xml version="1.0"?><a x="1"><b>one</b></a>
+	<?xml version="1.0" encoding="utf-8"?>
+	<!-- second -->
+	<a x="2"><b>two</b></a><a x="3"/>
+	`
+	docs := ParseMulti(strings.NewReader(input))
+	var got []string
+	for docs.Next() {
+		el := docs.Element()
+		got = append(got, el.Attr("", "x"))
+		if x := el.Attr("", "x"); x != "3" && len(el.Children) != 1 {
+			t.Errorf("document %s: expected 1 child, got %d", x, len(el.Children))
+		}
+	}
+	if err := docs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(got, ","); s != "1,2,3" {
+		t.Errorf("got documents %s, want 1,2,3", s)
+	}
+}
+
+func TestParseMultiTruncated(t *testing.T) {
+	docs := ParseMulti(strings.NewReader(`<a/><b><c>`))
+	if !docs.Next() {
+		t.Fatalf("expected first document, got error %v", docs.Err())
+	}
+	if docs.Next() {
+		t.Fatal("expected truncated second document to fail")
+	}
+	if docs.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, docs.Err())
+	}
+}
+
+func TestParseMultiGarbage(t *testing.T) {
+	for _, input := range []string{`<a/>junk`, `<a/> junk <b/>`} {
+		docs := ParseMulti(strings.NewReader(input))
+		if !docs.Next() {
+			t.Fatalf("%s: expected first document, got error %v", input, docs.Err())
+		}
+		if docs.Next() || !errors.Is(docs.Err(), ErrTrailingContent) {
+			t.Errorf("%s: expected ErrTrailingContent, got %v", input, docs.Err())
+		}
+	}
+}
+
+func TestConcatDocuments(t *testing.T) {
+	a := parseDoc(t, []byte(`<report xmlns="urn:a"><n>1</n></report>`))
+	b := parseDoc(t, []byte(`<report xmlns="urn:b" xmlns:ns="urn:x"><ns:n>2</ns:n></report>`))
+	plain := parseDoc(t, []byte(`<report><n>3</n></report>`))
+
+	tests := []struct {
+		root xml.Name
+		docs []*Element
+		want string
+	}{
+		{
+			xml.Name{Local: "all"}, []*Element{a, b, plain},
+			`<all><report xmlns="urn:a"><n>1</n></report><report xmlns="urn:b" xmlns:ns="urn:x"><ns:n>2</ns:n></report><report><n>3</n></report></all>`,
+		},
+		{
+			xml.Name{Space: "urn:a", Local: "all"}, []*Element{a, b},
+			`<all xmlns="urn:a"><report><n>1</n></report><report xmlns="urn:b" xmlns:ns="urn:x"><ns:n>2</ns:n></report></all>`,
+		},
+		{
+			xml.Name{Space: "urn:all", Local: "all"}, []*Element{b, plain},
+			`<ns1:all xmlns:ns1="urn:all"><report xmlns="urn:b" xmlns:ns="urn:x"><ns:n>2</ns:n></report><report><n>3</n></report></ns1:all>`,
+		},
+	}
+	for _, tt := range tests {
+		root := ConcatDocuments(tt.root, tt.docs...)
+		if got := string(Marshal(root)); got != tt.want {
+			t.Errorf("got  %s\nwant %s", got, tt.want)
+		}
+		reparsed := parseDoc(t, Marshal(root))
+		for i, doc := range tt.docs {
+			if !Equal(&reparsed.Children[i], doc) {
+				t.Errorf("document %d changed: %s", i, Marshal(&reparsed.Children[i]))
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package xmltree
+
+import "fmt"
+
+// MustParse is like Parse, but panics if the document cannot be
+// parsed. It is intended for tests and for initializing package-level
+// variables from embedded templates.
+func MustParse(doc []byte, opts ...ParseOption) *Element {
+	el, err := Parse(doc, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("xmltree: MustParse: %v", err))
+	}
+	return el
+}
+
+// MustFindOne is like FindOne, but panics if selector is invalid or
+// matches nothing. The panic message includes the selector and the
+// path of el within its tree, in the form returned by PathTo, found
+// by following parent pointers.
+func (el *Element) MustFindOne(selector string) *Element {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		panic(err.Error())
+	}
+	if match := sel.Match(el); match != nil {
+		return match
+	}
+	panic(fmt.Sprintf("xmltree: %s: no element matches %q", el.path(), selector))
+}
+
+// MustAttr returns the value of the named attribute, which may include
+// a namespace prefix. It panics if the element has no such attribute,
+// with a message that includes the path of el, as MustFindOne does.
+func (el *Element) MustAttr(name string) string {
+	if v, ok := el.lookupAttr(name); ok {
+		return v
+	}
+	panic(fmt.Sprintf("xmltree: %s: missing attribute %q", el.path(), name))
+}
@@ -0,0 +1,37 @@
+package xmltree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func mustPanic(t *testing.T, want string, fn func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if r == nil {
+			t.Errorf("expected panic containing %q", want)
+		} else if !strings.Contains(fmt.Sprint(r), want) {
+			t.Errorf("panic %q does not contain %q", r, want)
+		}
+	}()
+	fn()
+}
+
+func TestMust(t *testing.T) {
+	root := MustParse(googleSOAP)
+	item := root.MustFindOne("//item[3]")
+	if s := item.MustFindOne("URL").Content; len(s) == 0 {
+		t.Error("empty URL")
+	}
+	if v := root.MustFindOne("//resultElements").MustAttr("soapenc:arrayType"); v != "google:ResultElement[3]" {
+		t.Errorf("MustAttr = %q", v)
+	}
+
+	mustPanic(t, "MustParse", func() { MustParse([]byte("<a>")) })
+	mustPanic(t, `/soap11:Envelope/soap11:Body/doGoogleSearchResponse/return/resultElements/item[3]: no element matches "nothing"`, func() { item.MustFindOne("nothing") })
+	mustPanic(t, "invalid selector", func() { item.MustFindOne("[") })
+	mustPanic(t, `/resultElements/item[3]: missing attribute "id"`, func() { item.MustAttr("id") })
+}
@@ -0,0 +1,217 @@
+package xmltree
+
+import "encoding/xml"
+
+// AppendChild adds a copy of child as the last child of el, and
+// returns a pointer to the copy. The namespace scope of the copy is
+// merged with that of el, so that every name in it still refers to
+// the same namespace, and the tree is encoded with the xmlns
+// declarations it needs, whichever document child came from. As
+// with RemoveChild, the Children slice of el is copied, and pointers
+// to its other children must be looked up again afterwards.
+func (el *Element) AppendChild(child *Element) *Element {
+	return el.insertAt(len(el.Children), child)
+}
+
+// InsertBefore adds a copy of child to el immediately before ref,
+// which must be one of the Children of el, and returns a pointer to
+// the copy. It returns nil, leaving el unchanged, if ref is not a
+// child of el. Namespaces are merged as in AppendChild, and Misc
+// items, such as a comment, that precede ref stay next to it.
+func (el *Element) InsertBefore(child, ref *Element) *Element {
+	if i := el.childPos(ref); i >= 0 {
+		return el.insertAt(i, child)
+	}
+	return nil
+}
+
+// InsertAfter is like InsertBefore, but adds the copy of child
+// immediately after ref.
+func (el *Element) InsertAfter(child, ref *Element) *Element {
+	if i := el.childPos(ref); i >= 0 {
+		return el.insertAt(i+1, child)
+	}
+	return nil
+}
+
+// ReplaceChild puts a copy of replacement in place of old, which must
+// be one of the Children of el, and returns a pointer to the copy. It
+// returns nil, leaving el unchanged, if old is not a child of el.
+// Namespaces are merged as in AppendChild, so that a subtree from
+// another document, such as the payload of a SOAP message, keeps its
+// meaning and is encoded with the declarations it needs.
+func (el *Element) ReplaceChild(old, replacement *Element) *Element {
+	i := el.childPos(old)
+	if i < 0 {
+		return nil
+	}
+	dup := replacement.Clone()
+	dup.rescope(el.Scope, 0)
+	children := make([]Element, len(el.Children))
+	copy(children, el.Children)
+	children[i] = *dup
+	el.Children = children
+	el.childrenChanged()
+	return &el.Children[i]
+}
+
+// childPos returns the position of child in the Children of el, or
+// -1 if it is not one of them.
+func (el *Element) childPos(child *Element) int {
+	for i := range el.Children {
+		if &el.Children[i] == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (el *Element) insertAt(i int, child *Element) *Element {
+	dup := child.Clone()
+	dup.rescope(el.Scope, 0)
+	children := make([]Element, 0, len(el.Children)+1)
+	children = append(children, el.Children[:i]...)
+	children = append(children, *dup)
+	children = append(children, el.Children[i:]...)
+	if len(el.Misc) > 0 {
+		misc := make([]Misc, len(el.Misc))
+		for j, m := range el.Misc {
+			misc[j] = m
+			if m.Index >= i {
+				misc[j].Index++
+			}
+		}
+		el.Misc = misc
+	}
+	el.Children = children
+	el.childrenChanged()
+	// relinkChildren only reaches the grandchildren of el; the
+	// copy's descendants were linked by clone.
+	return &el.Children[i]
+}
+
+// rescope replaces the scope of el and its descendants with outer,
+// extended with the bindings of each element that outer lacks. The
+// names in the tree then resolve as they did before, in the context
+// of a new parent. If an element has no namespace, but outer has a
+// default namespace, the default namespace is undeclared for it.
+func (el *Element) rescope(outer Scope, depth int) {
+	var own []xml.Name
+	seen := make(map[string]bool)
+	for i := len(el.ns) - 1; i >= 0; i-- {
+		b := el.ns[i]
+		if seen[b.Local] {
+			continue
+		}
+		seen[b.Local] = true
+		if uri, ok := outer.binding(b.Local); !ok || uri != b.Space {
+			own = append(own, b)
+		}
+	}
+	if uri, ok := outer.binding(""); ok && uri != "" && !seen[""] && el.Name.Space == "" {
+		own = append(own, xml.Name{})
+	}
+	if len(own) == 0 {
+		el.Scope = outer
+	} else {
+		ns := make([]xml.Name, len(outer.ns), len(outer.ns)+len(own))
+		copy(ns, outer.ns)
+		for i := len(own) - 1; i >= 0; i-- {
+			ns = append(ns, own[i])
+		}
+		el.Scope = Scope{ns: ns}
+	}
+	if depth > recursionLimit {
+		return
+	}
+	for i := range el.Children {
+		el.Children[i].rescope(el.Scope, depth+1)
+	}
+}
+
+// RemoveChild removes child, which must be one of the Children of el
+// itself, and reports whether it was found. The Children slice is
+// copied rather than modified in place, as it may be shared with
+// other trees; pointers to the other children of el must be looked
+// up again afterwards. The positions of any Misc items, such as
+// comments, are adjusted to match.
+func (el *Element) RemoveChild(child *Element) bool {
+	for i := range el.Children {
+		if &el.Children[i] == child {
+			el.removeChildren(func(j int) bool { return j == i })
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveChildren removes every child of el for which fn returns
+// true, and returns the number removed. Only the direct children of
+// el are considered. As with RemoveChild, the Children slice is
+// copied.
+//
+//	// drop the drafts
+//	n := list.RemoveChildren(func(c *xmltree.Element) bool {
+//		return c.Attr("", "status") == "draft"
+//	})
+func (el *Element) RemoveChildren(fn func(*Element) bool) int {
+	return el.removeChildren(func(i int) bool { return fn(&el.Children[i]) })
+}
+
+// removeChildren removes the children at the positions for which
+// remove returns true, and returns the number removed.
+func (el *Element) removeChildren(remove func(i int) bool) int {
+	var kept []Element
+	// before[i] is the number of children kept before position i
+	before := make([]int, len(el.Children)+1)
+	var removed []int
+	for i := range el.Children {
+		before[i+1] = before[i]
+		if !remove(i) {
+			kept = append(kept, el.Children[i])
+			before[i+1]++
+		} else {
+			removed = append(removed, i)
+		}
+	}
+	n := len(el.Children) - len(kept)
+	if n == 0 {
+		return 0
+	}
+	el.Misc = keepMisc(el.Misc, before)
+	// The removed children become the roots of their own trees.
+	for _, i := range removed {
+		el.Children[i].parent = nil
+	}
+	el.Children = kept
+	el.childrenChanged()
+	return n
+}
+
+// keepMisc returns misc with the Index of each item adjusted for the
+// removal of some children of its element, where before[i] is the
+// number of the first i children that were kept. The slice is copied,
+// as it may be shared.
+func keepMisc(misc []Misc, before []int) []Misc {
+	if len(misc) == 0 {
+		return misc
+	}
+	n := len(before) - 1
+	dup := make([]Misc, len(misc))
+	for i, m := range misc {
+		dup[i] = m
+		if m.Index > n {
+			dup[i].Index = before[n] + m.Index - n
+		} else if m.Index > 0 {
+			dup[i].Index = before[m.Index]
+		}
+	}
+	return dup
+}
+
+// childrenChanged updates the bookkeeping of el after its Children
+// slice has been replaced or rearranged.
+func (el *Element) childrenChanged() {
+	el.invalidateChildIndex()
+	el.relinkChildren()
+}
@@ -0,0 +1,97 @@
+package xmltree
+
+import "testing"
+
+func TestRemoveChild(t *testing.T) {
+	root := MustParse([]byte(`<a><b/><!--1--><c/><!--2--><d/></a>`), WithComments())
+	c := &root.Children[1]
+	if !root.RemoveChild(c) {
+		t.Fatal("RemoveChild(c) = false")
+	}
+	if c.Parent() != nil || c.parent != nil {
+		t.Error("removed child still refers to its parent")
+	}
+	if root.RemoveChild(c) {
+		t.Error("removed c twice")
+	}
+	if got := root.String(); got != `<a><b /><!--1--><!--2--><d /></a>` {
+		t.Errorf("got %s", got)
+	}
+	if d := root.Child("", "d"); d == nil || d.Parent() != root || d.Index() != 1 {
+		t.Error("bookkeeping not updated after RemoveChild")
+	}
+}
+
+func TestRemoveChildren(t *testing.T) {
+	root := MustParse([]byte(`<list><i s="draft"/><i/><i s="draft"/><i/></list>`))
+	n := root.RemoveChildren(func(c *Element) bool { return c.Attr("", "s") == "draft" })
+	if n != 2 {
+		t.Errorf("removed %d children, want 2", n)
+	}
+	if got := root.String(); got != `<list><i /><i /></list>` {
+		t.Errorf("got %s", got)
+	}
+	if len(root.ChildrenNamed("", "i")) != 2 {
+		t.Error("child index not updated")
+	}
+}
+
+func TestInsertChild(t *testing.T) {
+	root := MustParse([]byte(`<doc xmlns="urn:doc" xmlns:m="urn:meta"><a/><!--before b--><b/></doc>`), WithComments())
+	other := MustParse([]byte(`<wrap xmlns:m="urn:other"><m:note>n</m:note><plain/></wrap>`))
+
+	note := root.InsertBefore(&other.Children[0], root.Child("urn:doc", "b"))
+	if note == nil || note.Name.Space != "urn:other" {
+		t.Fatalf("InsertBefore returned %v", note)
+	}
+	if root.InsertAfter(&other.Children[1], root.Child("urn:doc", "a")) == nil {
+		t.Fatal("InsertAfter failed")
+	}
+	root.AppendChild(MustParse([]byte(`<m:end xmlns:m="urn:meta"/>`)))
+	if root.InsertBefore(other, other) != nil {
+		t.Error("InsertBefore succeeded with a reference that is not a child")
+	}
+
+	want := `<doc xmlns="urn:doc" xmlns:m="urn:meta"><a /><plain xmlns="" xmlns:m="urn:other" />` +
+		`<m:note xmlns:m="urn:other">n</m:note><!--before b--><b /><m:end /></doc>`
+	if got := root.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	reparsed := MustParse([]byte(root.String()))
+	if c := reparsed.Children[1]; c.Name.Space != "" || c.Name.Local != "plain" {
+		t.Errorf("plain parsed as %v", c.Name)
+	}
+	if c := reparsed.Children[2]; c.Name.Space != "urn:other" {
+		t.Errorf("note parsed as %v", c.Name)
+	}
+	if other.String() != `<wrap xmlns:m="urn:other"><m:note>n</m:note><plain /></wrap>` {
+		t.Errorf("source tree modified: %s", other)
+	}
+	for i := range root.Children {
+		if root.Children[i].Parent() != root {
+			t.Errorf("child %d has the wrong parent", i)
+		}
+	}
+}
+
+func TestReplaceChild(t *testing.T) {
+	env := MustParse([]byte(`<soap:Envelope xmlns:soap="urn:soap"><soap:Body><m:Old xmlns:m="urn:m"/></soap:Body></soap:Envelope>`))
+	payload := MustParse([]byte(`<req xmlns="urn:req" xmlns:t="urn:types"><t:id>1</t:id></req>`))
+	body := &env.Children[0]
+	old := &body.Children[0]
+	if body.ReplaceChild(payload, payload) != nil {
+		t.Error("ReplaceChild accepted an element that is not a child")
+	}
+	got := body.ReplaceChild(old, payload)
+	if got == nil || got.Parent() != body {
+		t.Fatal("replacement not linked into the tree")
+	}
+	want := `<soap:Envelope xmlns:soap="urn:soap"><soap:Body>` +
+		`<req xmlns="urn:req" xmlns:t="urn:types"><t:id>1</t:id></req></soap:Body></soap:Envelope>`
+	if s := env.String(); s != want {
+		t.Errorf("got\n%s\nwant\n%s", s, want)
+	}
+	if id := MustParse([]byte(env.String())).FindOne("//id"); id == nil || id.Name.Space != "urn:types" {
+		t.Errorf("replacement lost its namespace")
+	}
+}
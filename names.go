@@ -0,0 +1,126 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// IsValidName reports whether s may be used as the local name of an
+// element or attribute, or as a namespace prefix: that is, whether
+// it is an XML name that contains no colon.
+func IsValidName(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+	for i, r := range s {
+		if r == ':' || !isNameChar(r) || i == 0 && !isNameStartChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// A NamePolicy determines how SanitizeName treats characters that
+// may not appear in a name.
+type NamePolicy int
+
+const (
+	// ReplaceInvalid replaces each invalid character with an
+	// underscore.
+	ReplaceInvalid NamePolicy = iota
+
+	// DropInvalid removes invalid characters.
+	DropInvalid
+
+	// EscapeInvalid replaces each invalid character with _xHHHH_,
+	// its code point in hexadecimal, as does the EncodeName
+	// function of .NET's XmlConvert. Underscores that would be
+	// mistaken for the start of an escape are escaped themselves,
+	// so different inputs always produce different names.
+	EscapeInvalid
+)
+
+// SanitizeName returns a name derived from s for which IsValidName
+// is true, treating characters that may not appear in a name as
+// directed by policy. A name that would begin with a character that
+// may only follow the first, such as a digit, is given a leading
+// underscore, as is the empty string. Valid names are returned
+// unchanged.
+//
+//	xmltree.SanitizeName("Unit Price ($)", xmltree.ReplaceInvalid) // "Unit_Price____"
+//	xmltree.SanitizeName("2nd address", xmltree.DropInvalid)       // "_2ndaddress"
+//	xmltree.SanitizeName("a:b", xmltree.EscapeInvalid)             // "a_x003A_b"
+func SanitizeName(s string, policy NamePolicy) string {
+	if IsValidName(s) && (policy != EscapeInvalid || !strings.Contains(s, "_x")) {
+		return s
+	}
+	var b strings.Builder
+	for i, w := 0, 0; i < len(s); i += w {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		w = width
+		first := b.Len() == 0
+		valid := r != ':' && isNameChar(r) && !(r == utf8.RuneError && width == 1)
+		switch {
+		case valid && policy == EscapeInvalid && r == '_' && strings.HasPrefix(s[i+1:], "x"):
+			b.WriteString("_x005F_")
+		case valid && first && !isNameStartChar(r):
+			if policy == EscapeInvalid {
+				fmt.Fprintf(&b, "_x%04X_", r)
+			} else {
+				b.WriteByte('_')
+				b.WriteRune(r)
+			}
+		case valid:
+			b.WriteRune(r)
+		case policy == ReplaceInvalid:
+			b.WriteByte('_')
+		case policy == EscapeInvalid:
+			fmt.Fprintf(&b, "_x%04X_", r)
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// SafeElement returns a new Element named with SanitizeName(name,
+// ReplaceInvalid), in no namespace, for building trees whose element
+// names come from data such as the header of a CSV file.
+func SafeElement(name string) *Element {
+	return &Element{
+		StartElement: xml.StartElement{
+			Name: xml.Name{Local: SanitizeName(name, ReplaceInvalid)},
+		},
+	}
+}
+
+// SafeAttr returns an attribute named with SanitizeName(name,
+// ReplaceInvalid), in no namespace, with the given value.
+func SafeAttr(name, value string) xml.Attr {
+	return xml.Attr{
+		Name:  xml.Name{Local: SanitizeName(name, ReplaceInvalid)},
+		Value: value,
+	}
+}
+
+// isNameStartChar and isNameChar implement the NameStartChar and
+// NameChar productions of the XML 1.0 (Fifth Edition) specification.
+func isNameStartChar(r rune) bool {
+	return r == ':' || r == '_' ||
+		'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' ||
+		0xC0 <= r && r <= 0xD6 || 0xD8 <= r && r <= 0xF6 ||
+		0xF8 <= r && r <= 0x2FF || 0x370 <= r && r <= 0x37D ||
+		0x37F <= r && r <= 0x1FFF || 0x200C <= r && r <= 0x200D ||
+		0x2070 <= r && r <= 0x218F || 0x2C00 <= r && r <= 0x2FEF ||
+		0x3001 <= r && r <= 0xD7FF || 0xF900 <= r && r <= 0xFDCF ||
+		0xFDF0 <= r && r <= 0xFFFD || 0x10000 <= r && r <= 0xEFFFF
+}
+
+func isNameChar(r rune) bool {
+	return isNameStartChar(r) || r == '-' || r == '.' ||
+		'0' <= r && r <= '9' || r == 0xB7 ||
+		0x300 <= r && r <= 0x36F || 0x203F <= r && r <= 0x2040
+}
@@ -0,0 +1,52 @@
+package xmltree
+
+import "testing"
+
+func TestIsValidName(t *testing.T) {
+	for s, want := range map[string]bool{
+		"a": true, "_a.b-c": true, "é": true, "a1": true,
+		"": false, "1a": false, "-a": false, "a:b": false, "a b": false,
+	} {
+		if got := IsValidName(s); got != want {
+			t.Errorf("IsValidName(%q) = %v", s, got)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		in     string
+		policy NamePolicy
+		want   string
+	}{
+		{"name", ReplaceInvalid, "name"},
+		{"Unit Price ($)", ReplaceInvalid, "Unit_Price____"},
+		{"2nd address", DropInvalid, "_2ndaddress"},
+		{"a:b", EscapeInvalid, "a_x003A_b"},
+		{"1st", EscapeInvalid, "_x0031_st"},
+		{"a_xb", EscapeInvalid, "a_x005F_xb"},
+		{"", ReplaceInvalid, "_"},
+		{"$$", DropInvalid, "_"},
+		{"bad\xffutf8", ReplaceInvalid, "bad_utf8"},
+	}
+	for _, tt := range tests {
+		got := SanitizeName(tt.in, tt.policy)
+		if got != tt.want {
+			t.Errorf("SanitizeName(%q, %d) = %q, want %q", tt.in, tt.policy, got, tt.want)
+		}
+		if !IsValidName(got) {
+			t.Errorf("SanitizeName(%q, %d) = %q is not valid", tt.in, tt.policy, got)
+		}
+	}
+}
+
+func TestSafeElement(t *testing.T) {
+	el := SafeElement("order id")
+	el.StartElement.Attr = append(el.StartElement.Attr, SafeAttr("#", "1"))
+	if got, want := string(Marshal(el)), `<order_id _="1" />`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if errs := ValidateTree(el); errs != nil {
+		t.Error(errs)
+	}
+}
@@ -0,0 +1,179 @@
+package xmltree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalNormalized returns a normalized form of the tree rooted at
+// el, for comparing documents with a line-oriented diff tool, or for
+// hashing. Two trees with the same names, attribute values and text
+// have the same normalized form, however their namespace prefixes
+// and declarations, attribute order and white space differ:
+//
+//   - each element is on a line of its own, indented by two spaces
+//     for each level of nesting, with its text, if it has no children;
+//   - attributes are sorted by namespace and local name;
+//   - every namespace is declared on the root element, sorted by URI,
+//     and is given the prefix ns1, ns2, and so on, in that order;
+//   - runs of white space in text are collapsed to a single space, and
+//     leading and trailing white space is removed;
+//   - comments and processing instructions are left out, and CDATA
+//     sections are written as escaped text.
+//
+// The output is well-formed XML, but it is not meant to be read by
+// other programs, and its details may change between versions of
+// this package. It is not an XML canonicalization in the sense of
+// the W3C C14N specifications.
+//
+// If the content of an element cannot be read from its ContentStore,
+// MarshalNormalized returns nil; EncodeNormalized returns the error.
+func MarshalNormalized(el *Element) []byte {
+	var buf bytes.Buffer
+	if err := EncodeNormalized(&buf, el); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// EncodeNormalized writes the normalized form of the tree rooted at
+// el, as produced by MarshalNormalized, to w.
+func EncodeNormalized(w io.Writer, el *Element) error {
+	bw := bufio.NewWriter(w)
+	n := normalizer{w: bw, prefixes: make(map[string]string)}
+	var uris []string
+	n.collect(el, &uris, 0)
+	sort.Strings(uris)
+	for i, uri := range uris {
+		n.prefixes[uri] = "ns" + strconv.Itoa(i+1)
+	}
+	if err := n.write(el, uris, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type normalizer struct {
+	w        *bufio.Writer
+	prefixes map[string]string // by namespace URI
+}
+
+// collect appends the namespaces used in the tree rooted at el to
+// uris, once each.
+func (n *normalizer) collect(el *Element, uris *[]string, depth int) {
+	add := func(uri string) {
+		switch uri {
+		case "", xmlLangURI, xmlNamespaceURI:
+			return
+		}
+		if _, ok := n.prefixes[uri]; !ok {
+			n.prefixes[uri] = ""
+			*uris = append(*uris, uri)
+		}
+	}
+	add(el.Name.Space)
+	for _, a := range el.StartElement.Attr {
+		add(a.Name.Space)
+	}
+	if depth > recursionLimit {
+		return
+	}
+	for i := range el.Children {
+		n.collect(&el.Children[i], uris, depth+1)
+	}
+}
+
+// name returns the normalized qualified name for name.
+func (n *normalizer) name(name xml.Name) string {
+	switch name.Space {
+	case "":
+		return name.Local
+	case xmlLangURI:
+		return "xml:" + name.Local
+	case xmlNamespaceURI:
+		return "xmlns:" + name.Local
+	}
+	return n.prefixes[name.Space] + ":" + name.Local
+}
+
+// write writes el at the given depth; the root element declares the
+// namespaces in uris.
+func (n *normalizer) write(el *Element, uris []string, depth int) error {
+	w := n.w
+	for i := 0; i < depth; i++ {
+		w.WriteString("  ")
+	}
+	w.WriteByte('<')
+	w.WriteString(n.name(el.Name))
+	for _, uri := range uris {
+		w.WriteString(" xmlns:")
+		w.WriteString(n.prefixes[uri])
+		w.WriteString(`="`)
+		escapeString(w, uri)
+		w.WriteByte('"')
+	}
+	for _, a := range sortedAttrs(el).StartElement.Attr {
+		w.WriteByte(' ')
+		w.WriteString(n.name(a.Name))
+		w.WriteString(`="`)
+		escapeString(w, a.Value)
+		w.WriteByte('"')
+	}
+
+	if len(el.Children) == 0 || depth > recursionLimit {
+		content, err := el.contentBytes()
+		if err != nil {
+			return err
+		}
+		if text := collapseSpace(content); text != "" {
+			w.WriteByte('>')
+			escapeString(w, text)
+			w.WriteString("</")
+			w.WriteString(n.name(el.Name))
+			w.WriteString(">\n")
+		} else {
+			w.WriteString(" />\n")
+		}
+		return nil
+	}
+
+	w.WriteString(">\n")
+	var err error
+	el.EachNode(func(child *Element, misc *Misc) {
+		if err != nil {
+			return
+		}
+		if child != nil {
+			err = n.write(child, nil, depth+1)
+		} else if misc.Kind == MiscText {
+			if text := collapseSpace(misc.Data); text != "" {
+				for i := 0; i <= depth; i++ {
+					w.WriteString("  ")
+				}
+				escapeString(w, text)
+				w.WriteByte('\n')
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for i := 0; i < depth; i++ {
+		w.WriteString("  ")
+	}
+	w.WriteString("</")
+	w.WriteString(n.name(el.Name))
+	w.WriteString(">\n")
+	return nil
+}
+
+// collapseSpace replaces each run of white space in text with a
+// single space, and removes leading and trailing white space.
+func collapseSpace(text []byte) string {
+	return strings.Join(strings.Fields(string(text)), " ")
+}
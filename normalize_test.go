@@ -0,0 +1,24 @@
+package xmltree
+
+import "testing"
+
+func TestMarshalNormalized(t *testing.T) {
+	a := parseDoc(t, []byte(`<a:doc xmlns:a="urn:doc" xmlns:z="urn:attr" z:k="v" id="1">
+	<a:title>  Hello,
+	   world  </a:title><!-- ignored --><a:empty/></a:doc>`))
+	b := parseDoc(t, []byte(`<doc xmlns="urn:doc" id="1" xmlns:q="urn:attr" q:k="v"><title>Hello, world</title><empty></empty></doc>`))
+	want := `<ns2:doc xmlns:ns1="urn:attr" xmlns:ns2="urn:doc" id="1" ns1:k="v">
+  <ns2:title>Hello, world</ns2:title>
+  <ns2:empty />
+</ns2:doc>
+`
+	for _, el := range []*Element{a, b} {
+		if got := string(MarshalNormalized(el)); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	}
+	c := parseDoc(t, []byte(`<doc xmlns="urn:doc" id="2" xmlns:q="urn:attr" q:k="v"><title>Hello, world</title><empty/></doc>`))
+	if string(MarshalNormalized(c)) == want {
+		t.Error("different attribute value, same normalized form")
+	}
+}
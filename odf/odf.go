@@ -0,0 +1,93 @@
+// Package odf reads and writes OpenDocument packages, such as .odt
+// text documents and .ods spreadsheets, exposing their XML parts as
+// xmltree Elements.
+package odf // import "github.com/mdejong/xmltree/odf"
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+	"github.com/mdejong/xmltree/xmlzip"
+)
+
+// Names of the standard members of an OpenDocument package.
+const (
+	MimeTypeFile = "mimetype"
+	ContentFile  = "content.xml"
+	StylesFile   = "styles.xml"
+	MetaFile     = "meta.xml"
+	ManifestFile = "META-INF/manifest.xml"
+)
+
+// ErrNotODF is returned by Open when the archive has no mimetype
+// member naming an OpenDocument media type.
+var ErrNotODF = errors.New("odf: not an OpenDocument package")
+
+// A Package is an OpenDocument zip container.
+type Package struct {
+	*xmlzip.Archive
+}
+
+// Open reads an OpenDocument package from r, which is size bytes
+// long.
+func Open(r io.ReaderAt, size int64) (*Package, error) {
+	a, err := xmlzip.Open(r, size)
+	if err != nil {
+		return nil, err
+	}
+	p := &Package{a}
+	if !strings.HasPrefix(p.MimeType(), "application/vnd.oasis.opendocument.") {
+		return nil, ErrNotODF
+	}
+	return p, nil
+}
+
+// MimeType returns the media type of the package, such as
+// "application/vnd.oasis.opendocument.text".
+func (p *Package) MimeType() string {
+	if f := p.File(MimeTypeFile); f != nil {
+		if data, err := f.Bytes(); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// Content returns the document body, from content.xml.
+func (p *Package) Content() (*xmltree.Element, error) {
+	return p.Tree(ContentFile)
+}
+
+// Styles returns the document styles, from styles.xml.
+func (p *Package) Styles() (*xmltree.Element, error) {
+	return p.Tree(StylesFile)
+}
+
+// Meta returns the document metadata, from meta.xml.
+func (p *Package) Meta() (*xmltree.Element, error) {
+	return p.Tree(MetaFile)
+}
+
+// Manifest returns the package manifest, from META-INF/manifest.xml,
+// which lists the members of the package and their media types.
+func (p *Package) Manifest() (*xmltree.Element, error) {
+	return p.Tree(ManifestFile)
+}
+
+// WriteTo writes the package to w. As the OpenDocument specification
+// requires, the mimetype member is written first and without
+// compression, so that the file type can be identified from its
+// leading bytes.
+func (p *Package) WriteTo(w io.Writer) (int64, error) {
+	f := p.File(MimeTypeFile)
+	if f == nil {
+		return 0, fmt.Errorf("odf: package has no %s member", MimeTypeFile)
+	}
+	f.Method = zip.Store
+	p.MoveFirst(MimeTypeFile)
+	return p.Archive.WriteTo(w)
+}
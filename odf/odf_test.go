@@ -0,0 +1,91 @@
+package odf
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+const mimeODT = "application/vnd.oasis.opendocument.text"
+
+func makeODT(t *testing.T) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, data string }{
+		{"content.xml", `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text><text:p>Hello</text:p></office:text></office:body></office:document-content>`},
+		{"styles.xml", `<office:document-styles xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"/>`},
+		{"meta.xml", `<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"/>`},
+		{"META-INF/manifest.xml", `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"><manifest:file-entry manifest:full-path="/" manifest:media-type="` + mimeODT + `"/></manifest:manifest>`},
+		{"mimetype", mimeODT},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestPackage(t *testing.T) {
+	r := makeODT(t)
+	p, err := Open(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MimeType() != mimeODT {
+		t.Errorf("MimeType = %q", p.MimeType())
+	}
+	content, err := p.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	para := content.Search("", "p")[0]
+	para.Content = []byte("Goodbye")
+	for _, part := range []func() error{
+		func() error { _, err := p.Styles(); return err },
+		func() error { _, err := p.Meta(); return err },
+		func() error { _, err := p.Manifest(); return err },
+	} {
+		if err := part(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("first member is %s, method %d", first.Name, first.Method)
+	}
+	if !bytes.Contains(buf.Bytes()[:80], []byte(mimeODT)) {
+		t.Error("media type not readable from leading bytes")
+	}
+	q, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ = q.Content()
+	if s := string(content.Search("", "p")[0].Content); s != "Goodbye" {
+		t.Errorf("content after rewrite is %q", s)
+	}
+}
+
+func TestNotODF(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("content.xml")
+	zw.Close()
+	if _, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != ErrNotODF {
+		t.Errorf("Open = %v, want ErrNotODF", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Package opml reads and writes OPML outline documents, such as feed
+// subscription lists and podcast directories.
+//
+// Outlines are views of the underlying xmltree.Element, so
+// attributes this package does not know about are preserved.
+package opml // import "github.com/mdejong/xmltree/opml"
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/mdejong/xmltree"
+)
+
+// A Document is an opml element.
+type Document struct {
+	*xmltree.Element
+}
+
+// Parse parses an OPML document.
+func Parse(data []byte) (Document, error) {
+	root, err := xmltree.Parse(data)
+	if err != nil {
+		return Document{}, err
+	}
+	if root.Name.Local != "opml" {
+		return Document{}, fmt.Errorf("opml: root element is %s, not opml", root.Name.Local)
+	}
+	if body(root) == nil {
+		return Document{}, fmt.Errorf("opml: document has no body")
+	}
+	return Document{root}, nil
+}
+
+// New returns an empty OPML 2.0 document with the given title.
+func New(title string) Document {
+	root := &xmltree.Element{
+		StartElement: xml.StartElement{
+			Name: xml.Name{Local: "opml"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: "2.0"}},
+		},
+		Children: []xmltree.Element{
+			{
+				StartElement: xml.StartElement{Name: xml.Name{Local: "head"}},
+				Children: []xmltree.Element{{
+					StartElement: xml.StartElement{Name: xml.Name{Local: "title"}},
+					Content:      []byte(title),
+				}},
+			},
+			{StartElement: xml.StartElement{Name: xml.Name{Local: "body"}}},
+		},
+	}
+	return Document{root}
+}
+
+func child(el *xmltree.Element, local string) *xmltree.Element {
+	for i := range el.Children {
+		if el.Children[i].Name.Local == local {
+			return &el.Children[i]
+		}
+	}
+	return nil
+}
+
+func body(root *xmltree.Element) *xmltree.Element {
+	return child(root, "body")
+}
+
+// Title returns the title from the document head.
+func (d Document) Title() string {
+	if head := child(d.Element, "head"); head != nil {
+		if title := child(head, "title"); title != nil {
+			return string(title.Content)
+		}
+	}
+	return ""
+}
+
+// Body returns an Outline for the document body, whose children are
+// the top-level outlines.
+func (d Document) Body() Outline {
+	return Outline{body(d.Element)}
+}
+
+// Outlines returns the top-level outlines of the document.
+func (d Document) Outlines() []Outline {
+	return d.Body().Children()
+}
+
+// Walk calls fn for every outline in the document, in depth-first
+// order, with its nesting depth, starting at 0. If fn returns false,
+// the children of that outline are skipped.
+func (d Document) Walk(fn func(o Outline, depth int) bool) {
+	d.Body().walk(fn, 0)
+}
+
+// Feeds returns every outline, at any depth, with an xmlUrl
+// attribute, as in a list of feed subscriptions.
+func (d Document) Feeds() []Outline {
+	var feeds []Outline
+	d.Walk(func(o Outline, depth int) bool {
+		if o.Attr("", "xmlUrl") != "" {
+			feeds = append(feeds, o)
+		}
+		return true
+	})
+	return feeds
+}
+
+// An Outline is an outline element, or the document body.
+type Outline struct {
+	*xmltree.Element
+}
+
+// Text returns the text attribute of the outline.
+func (o Outline) Text() string {
+	return o.Attr("", "text")
+}
+
+// Type returns the type attribute of the outline, such as "rss" or
+// "link".
+func (o Outline) Type() string {
+	return o.Attr("", "type")
+}
+
+// Children returns the outlines nested within o.
+func (o Outline) Children() []Outline {
+	var result []Outline
+	for i := range o.Element.Children {
+		if c := &o.Element.Children[i]; c.Name.Local == "outline" {
+			result = append(result, Outline{c})
+		}
+	}
+	return result
+}
+
+func (o Outline) walk(fn func(Outline, int) bool, depth int) {
+	for _, c := range o.Children() {
+		if fn(c, depth) {
+			c.walk(fn, depth+1)
+		}
+	}
+}
+
+// Add appends a new outline within o with the given text, and
+// optional attributes given as name, value pairs. Add panics if
+// attrs has an odd number of elements. Adding an outline
+// may move the existing children of o in memory, so Outlines
+// obtained from o.Children before the call must not be used after
+// it.
+func (o Outline) Add(text string, attrs ...string) Outline {
+	el := xmltree.Element{
+		StartElement: xml.StartElement{Name: xml.Name{Local: "outline"}},
+		Scope:        o.Scope,
+	}
+	if len(attrs)%2 == 1 {
+		panic("opml: Add: odd argument count")
+	}
+	el.SetAttr("", "text", text)
+	for i := 0; i < len(attrs); i += 2 {
+		el.SetAttr("", attrs[i], attrs[i+1])
+	}
+	o.Element.Children = append(o.Element.Children, el)
+	return Outline{&o.Element.Children[len(o.Element.Children)-1]}
+}
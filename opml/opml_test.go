@@ -0,0 +1,71 @@
+package opml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+const subscriptions = `<?xml version="1.0"?>
+<opml version="2.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="News">
+      <outline text="Example" type="rss" xmlUrl="http://example.org/feed" custom="1"/>
+      <outline text="Deeper"><outline text="Podcast" type="rss" xmlUrl="http://example.org/pod"/></outline>
+    </outline>
+    <outline text="Site" type="link" url="http://example.org/"/>
+  </body>
+</opml>`
+
+func TestParse(t *testing.T) {
+	d, err := Parse([]byte(subscriptions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title() != "Subscriptions" {
+		t.Errorf("title %q", d.Title())
+	}
+	if top := d.Outlines(); len(top) != 2 || top[1].Type() != "link" {
+		t.Errorf("top-level outlines %v", top)
+	}
+	var visited []string
+	d.Walk(func(o Outline, depth int) bool {
+		visited = append(visited, strings.Repeat(">", depth)+o.Text())
+		return o.Text() != "Deeper"
+	})
+	if got := strings.Join(visited, ","); got != "News,>Example,>Deeper,Site" {
+		t.Errorf("walk visited %s", got)
+	}
+	feeds := d.Feeds()
+	if len(feeds) != 2 || feeds[1].Text() != "Podcast" || feeds[0].Attr("", "custom") != "1" {
+		t.Errorf("feeds %v", feeds)
+	}
+	if _, err := Parse([]byte(`<rss/>`)); err == nil {
+		t.Error("parsed a non-OPML document")
+	}
+}
+
+func TestNew(t *testing.T) {
+	d := New("Mine")
+	folder := d.Body().Add("Folder")
+	folder.Add("Feed", "type", "rss", "xmlUrl", "http://example.org/a")
+	want := `<opml version="2.0"><head><title>Mine</title></head><body><outline text="Folder"><outline text="Feed" type="rss" xmlUrl="http://example.org/a" /></outline></body></opml>`
+	if got := string(xmltree.Marshal(d.Element)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	again, err := Parse(xmltree.Marshal(d.Element))
+	if err != nil || len(again.Feeds()) != 1 {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
+
+func TestAddOddAttrs(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Add did not panic on a name without a value")
+		}
+	}()
+	New("Mine").Body().Add("Feed", "type")
+}
@@ -0,0 +1,259 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// A ParseOption modifies the behavior of Parse.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	rejectTrailing bool
+
+	dupAttrs       DuplicateAttrPolicy
+	reportDupAttrs func(*DuplicateAttrError)
+
+	maxAttrs, maxAttrLen, maxNameLen int
+
+	noIntern bool
+
+	elementHint, childrenHint int
+
+	spillThreshold int
+	spillStore     ContentStore
+
+	skip []*Selector
+
+	onElement []func(*Element) error
+
+	comments, procInsts, cdata, mixed bool
+
+	inline map[string]bool // namespaces whose text is kept
+
+	progress *progress
+
+	maxMemory int
+
+	// The first error from a ParseOption
+	err error
+}
+
+func newParseOptions(opts []ParseOption) *parseOptions {
+	o := new(parseOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ErrTrailingContent is returned by Parse, when the WithRejectTrailing
+// option is used, if a document contains anything other than white
+// space, comments or processing instructions after its root element.
+// A MultiParser returns it for text between or after its documents.
+var ErrTrailingContent = errors.New("xmltree: content after root element")
+
+// WithRejectTrailing causes Parse to return ErrTrailingContent if
+// there is anything but white space, comments or processing
+// instructions after the end of the root element. By default, such
+// content is ignored.
+func WithRejectTrailing() ParseOption {
+	return func(o *parseOptions) {
+		o.rejectTrailing = true
+	}
+}
+
+// A DuplicateAttrPolicy determines how Parse handles an element with
+// more than one attribute of the same name.
+type DuplicateAttrPolicy int
+
+const (
+	// Keep all attributes. This is the default, and matches the
+	// behavior of the encoding/xml package.
+	DuplicateAttrsKeepAll DuplicateAttrPolicy = iota
+	// Fail with a *DuplicateAttrError, as required by the XML
+	// specification.
+	DuplicateAttrsError
+	// Keep the first of the duplicate attributes.
+	DuplicateAttrsKeepFirst
+	// Keep the last of the duplicate attributes.
+	DuplicateAttrsKeepLast
+)
+
+// A DuplicateAttrError describes an attribute that appears more than
+// once in the same start tag.
+type DuplicateAttrError struct {
+	Element xml.Name
+	Attr    xml.Name
+	// Offset of the end of the offending start tag in the input.
+	Offset int64
+}
+
+func (e *DuplicateAttrError) Error() string {
+	return fmt.Sprintf("xmltree: duplicate attribute %s on element <%s> at offset %d",
+		formatName(e.Attr), formatName(e.Element), e.Offset)
+}
+
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+// WithDuplicateAttrs sets the policy for handling duplicate attributes.
+// Attributes are considered duplicates if their namespace and local
+// names are equal. Regardless of policy, if report is non-nil it is
+// called for each duplicate attribute found; this allows callers to
+// log or count violations while still accepting the document.
+func WithDuplicateAttrs(policy DuplicateAttrPolicy, report func(*DuplicateAttrError)) ParseOption {
+	return func(o *parseOptions) {
+		o.dupAttrs = policy
+		o.reportDupAttrs = report
+	}
+}
+
+// checkAttrs applies the duplicate attribute policy to the attributes
+// of a start tag, returning the attributes to keep.
+func (o *parseOptions) checkAttrs(tag xml.StartElement, offset int64) ([]xml.Attr, error) {
+	if o.dupAttrs == DuplicateAttrsKeepAll && o.reportDupAttrs == nil {
+		return tag.Attr, nil
+	}
+	attrs := tag.Attr
+	for i := 1; i < len(attrs); i++ {
+		for j := 0; j < i; j++ {
+			if attrs[i].Name != attrs[j].Name {
+				continue
+			}
+			err := &DuplicateAttrError{Element: tag.Name, Attr: attrs[i].Name, Offset: offset}
+			if o.reportDupAttrs != nil {
+				o.reportDupAttrs(err)
+			}
+			switch o.dupAttrs {
+			case DuplicateAttrsError:
+				return nil, err
+			case DuplicateAttrsKeepFirst:
+				attrs = append(attrs[:i:i], attrs[i+1:]...)
+				i--
+			case DuplicateAttrsKeepLast:
+				attrs[j] = attrs[i]
+				attrs = append(attrs[:i:i], attrs[i+1:]...)
+				i--
+			}
+			break
+		}
+	}
+	return attrs, nil
+}
+
+// Errors wrapped by a *LimitError, identifying the limit exceeded.
+var (
+	ErrTooManyAttrs    = errors.New("too many attributes")
+	ErrAttrValueTooBig = errors.New("attribute value too long")
+	ErrNameTooLong     = errors.New("name too long")
+)
+
+// A LimitError is returned by Parse when a document exceeds one of
+// the limits set with WithMaxAttrs, WithMaxAttrLen, WithMaxNameLen or
+// WithMaxMemory.
+// Use errors.Is to determine which limit was exceeded.
+type LimitError struct {
+	Err     error    // ErrTooManyAttrs, ErrAttrValueTooBig, ErrNameTooLong or ErrMemoryLimit
+	Limit   int      // the limit that was exceeded
+	Element xml.Name // the element where the limit was exceeded
+	// Offset of the end of the offending start tag in the input.
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("xmltree: %v (limit %d) in element <%s> at offset %d",
+		e.Err, e.Limit, formatName(e.Element), e.Offset)
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// WithMaxAttrs limits the number of attributes, including namespace
+// declarations, allowed on a single element.
+func WithMaxAttrs(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxAttrs = n
+	}
+}
+
+// WithMaxAttrLen limits the length, in bytes, of attribute values.
+func WithMaxAttrLen(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxAttrLen = n
+	}
+}
+
+// WithMaxNameLen limits the length, in bytes, of element and
+// attribute names. The namespace prefix, if any, is not counted.
+func WithMaxNameLen(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxNameLen = n
+	}
+}
+
+// checkLimits enforces the limits on the size of a start tag. Note
+// that the encoding/xml package has already read the entire start
+// tag into memory before these checks are made; the limits protect
+// against the cost of building and retaining large trees.
+func (o *parseOptions) checkLimits(tag xml.StartElement, offset int64) error {
+	fail := func(err error, limit int) error {
+		return &LimitError{Err: err, Limit: limit, Element: tag.Name, Offset: offset}
+	}
+	if o.maxAttrs > 0 && len(tag.Attr) > o.maxAttrs {
+		return fail(ErrTooManyAttrs, o.maxAttrs)
+	}
+	if o.maxNameLen > 0 && len(tag.Name.Local) > o.maxNameLen {
+		return fail(ErrNameTooLong, o.maxNameLen)
+	}
+	for _, a := range tag.Attr {
+		if o.maxNameLen > 0 && len(a.Name.Local) > o.maxNameLen {
+			return fail(ErrNameTooLong, o.maxNameLen)
+		}
+		if o.maxAttrLen > 0 && len(a.Value) > o.maxAttrLen {
+			return fail(ErrAttrValueTooBig, o.maxAttrLen)
+		}
+	}
+	return nil
+}
+
+// WithoutInterning disables the interning of element and attribute
+// names. By default, Parse stores a single copy of each distinct
+// name string, which greatly reduces the memory used by documents
+// with many repeated tag names, at a small cost in parsing speed.
+func WithoutInterning() ParseOption {
+	return func(o *parseOptions) {
+		o.noIntern = true
+	}
+}
+
+// WithSizeHint tells Parse to expect a document with approximately
+// the given number of elements, each of which has, on average, the
+// given number of children. Parse uses these hints to allocate
+// memory for the tree in fewer, larger blocks. Either value may be
+// zero if unknown. The hints do not affect the resulting tree.
+func WithSizeHint(elements, childrenPerElement int) ParseOption {
+	return func(o *parseOptions) {
+		o.elementHint = elements
+		o.childrenHint = childrenPerElement
+	}
+}
+
+// WithOnElement calls fn for each element as soon as its end tag has
+// been read, with its attributes, content and children complete.
+// Children are reported before their parents, and the root element
+// last. If fn returns an error, Parse stops and returns that error.
+// fn may modify the element, for example to annotate it, but must
+// not retain the pointer it is given, except for the root element;
+// elements are moved into their parent's Children slice after fn
+// returns. The option may be given more than once; the functions
+// are called in order.
+func WithOnElement(fn func(*Element) error) ParseOption {
+	return func(o *parseOptions) {
+		o.onElement = append(o.onElement, fn)
+	}
+}
@@ -0,0 +1,204 @@
+package xmltree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestRejectTrailing(t *testing.T) {
+	tests := []struct {
+		doc string
+		ok  bool
+	}{
+		{"<a><b/></a>", true},
+		{"<a/>\n  <!-- done --><?pi x?>\n", true},
+		{"<a/>junk", false},
+		{"<a/><b/>", false},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.doc)); err != nil {
+			t.Errorf("Parse(%q) without options: %v", tt.doc, err)
+		}
+		_, err := Parse([]byte(tt.doc), WithRejectTrailing())
+		if tt.ok && err != nil {
+			t.Errorf("Parse(%q): unexpected error %v", tt.doc, err)
+		} else if !tt.ok && !errors.Is(err, ErrTrailingContent) {
+			t.Errorf("Parse(%q): expected ErrTrailingContent, got %v", tt.doc, err)
+		}
+	}
+}
+
+func TestDuplicateAttrs(t *testing.T) {
+	doc := []byte(`<a x="1" y="2" x="3"><b xmlns:p="urn:p" p:z="1" z="2" p:z="3"/></a>`)
+	tests := []struct {
+		policy DuplicateAttrPolicy
+		a, b   string
+	}{
+		{DuplicateAttrsKeepAll, "1,2,3", "1,2,3"},
+		{DuplicateAttrsKeepFirst, "1,2", "1,2"},
+		{DuplicateAttrsKeepLast, "3,2", "3,2"},
+	}
+	values := func(el *Element) string {
+		var s []string
+		for _, a := range el.StartElement.Attr {
+			s = append(s, a.Value)
+		}
+		return strings.Join(s, ",")
+	}
+	for _, tt := range tests {
+		var reported int
+		root, err := Parse(doc, WithDuplicateAttrs(tt.policy, func(*DuplicateAttrError) {
+			reported++
+		}))
+		if err != nil {
+			t.Errorf("policy %d: %v", tt.policy, err)
+			continue
+		}
+		if reported != 2 {
+			t.Errorf("policy %d: reported %d duplicates, want 2", tt.policy, reported)
+		}
+		if v := values(root); v != tt.a {
+			t.Errorf("policy %d: <a> attributes %s, want %s", tt.policy, v, tt.a)
+		}
+		if v := values(&root.Children[0]); v != tt.b {
+			t.Errorf("policy %d: <b> attributes %s, want %s", tt.policy, v, tt.b)
+		}
+	}
+	_, err := Parse(doc, WithDuplicateAttrs(DuplicateAttrsError, nil))
+	var dup *DuplicateAttrError
+	if !errors.As(err, &dup) {
+		t.Fatalf("expected *DuplicateAttrError, got %v", err)
+	}
+	if dup.Attr.Local != "x" || dup.Element.Local != "a" {
+		t.Errorf("unexpected error %v", dup)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	doc := []byte(`<root><item a="1" b="2" c="333"/><longername/></root>`)
+	tests := []struct {
+		opt ParseOption
+		err error
+	}{
+		{WithMaxAttrs(3), nil},
+		{WithMaxAttrs(2), ErrTooManyAttrs},
+		{WithMaxAttrLen(3), nil},
+		{WithMaxAttrLen(2), ErrAttrValueTooBig},
+		{WithMaxNameLen(10), nil},
+		{WithMaxNameLen(9), ErrNameTooLong},
+	}
+	for i, tt := range tests {
+		_, err := Parse(doc, tt.opt)
+		if tt.err == nil && err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		} else if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%d: expected %v, got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestInterning(t *testing.T) {
+	doc := []byte(`<list><item id="1"/><item id="2"/></list>`)
+	sameString := func(a, b string) bool {
+		return unsafe.StringData(a) == unsafe.StringData(b)
+	}
+	root := parseDoc(t, doc)
+	a, b := &root.Children[0], &root.Children[1]
+	if !sameString(a.Name.Local, b.Name.Local) || !sameString(a.StartElement.Attr[0].Name.Local, b.StartElement.Attr[0].Name.Local) {
+		t.Error("names were not interned")
+	}
+	root, err := Parse(doc, WithoutInterning())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b = &root.Children[0], &root.Children[1]
+	if sameString(a.Name.Local, b.Name.Local) {
+		t.Error("names were interned with WithoutInterning")
+	}
+}
+
+func TestSizeHint(t *testing.T) {
+	a := parseDoc(t, exampleDoc)
+	b, err := Parse(exampleDoc, WithSizeHint(10, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(a, b) {
+		t.Errorf("size hint changed the parsed tree:\n%s", Marshal(b))
+	}
+	// Appending to a child must not clobber its siblings, which
+	// may share the same block of memory.
+	first, second := &b.Children[0], b.Children[1].Name
+	first.Children = append(first.Children, Element{})
+	if b.Children[1].Name != second {
+		t.Errorf("append to child modified sibling: %v", b.Children[1].Name)
+	}
+}
+
+func TestOnElement(t *testing.T) {
+	var order []string
+	el, err := Parse([]byte(`<a><b><c/></b><d/></a>`), WithOnElement(func(el *Element) error {
+		order = append(order, el.Name.Local)
+		el.SetAttr("", "seen", fmt.Sprint(len(order)))
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ","); got != "c,b,d,a" {
+		t.Errorf("elements completed in order %s", got)
+	}
+	want := `<a seen="4"><b seen="2"><c seen="1" /></b><d seen="3" /></a>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	errReject := errors.New("rejected")
+	count := 0
+	_, err = Parse(recordDoc(100), WithOnElement(func(el *Element) error {
+		if count++; el.Name.Local == "record" && el.Attr("", "id") == "2" {
+			return errReject
+		}
+		return nil
+	}))
+	if err != errReject {
+		t.Errorf("Parse returned %v, want %v", err, errReject)
+	}
+	if count != 12 {
+		t.Errorf("parse continued after rejection: %d elements seen", count)
+	}
+}
+
+func recordDoc(records int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<records>")
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&buf, `<record id="%d"><name>n%d</name><value>%d</value><flag/></record>`, i, i, i)
+	}
+	buf.WriteString("</records>")
+	return buf.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	doc := recordDoc(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSizeHint(b *testing.B) {
+	doc := recordDoc(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(doc, WithSizeHint(40001, 3)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
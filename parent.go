@@ -0,0 +1,92 @@
+package xmltree
+
+// Parent returns the element that contains el, or nil if el is the
+// root of its tree. Parent pointers are set in the trees built by
+// Parse, Clone, Builder, Project, Exclude, ConcatDocuments and
+// FromValue, and kept up to date by AppendChild, InsertBefore,
+// InsertAfter, ReplaceChild, RemoveChild, RemoveChildren,
+// SortChildrenBy and GroupChildrenBy. They are not updated when a
+// Children slice is modified directly. If el is no longer at the
+// position its pointer records, Parent returns nil, but an element
+// moved to a place another once held may be given the wrong parent.
+// Call LinkParents on the root to restore the pointers after such
+// changes.
+//
+//	for p := match.Parent(); p != nil; p = p.Parent() {
+//		fmt.Println(p.Name.Local)
+//	}
+func (el *Element) Parent() *Element {
+	p := el.parent
+	if p == nil || el.pos >= len(p.Children) || &p.Children[el.pos] != el {
+		return nil
+	}
+	return p
+}
+
+// LinkParents sets the parent pointers of every element below el,
+// making el the root of its tree. It also discards the indexes used
+// by Child and ChildrenNamed, which must be rebuilt if children were
+// renamed or reordered in place.
+func (el *Element) LinkParents() {
+	el.parent = nil
+	el.link(0)
+}
+
+func (el *Element) link(depth int) {
+	if depth > recursionLimit {
+		return
+	}
+	el.invalidateChildIndex()
+	for i := range el.Children {
+		c := &el.Children[i]
+		c.parent, c.pos = el, i
+		c.link(depth + 1)
+	}
+}
+
+// relinkChildren updates the parent pointers of the children of el,
+// and of their children, after the children have been moved to a
+// new place, such as when the Children slice is sorted or grown.
+func (el *Element) relinkChildren() {
+	for i := range el.Children {
+		c := &el.Children[i]
+		c.parent, c.pos = el, i
+		for j := range c.Children {
+			c.Children[j].parent, c.Children[j].pos = c, j
+		}
+	}
+}
+
+// Index returns the position of el in the Children of its parent,
+// or -1 if el has no parent. Like Parent, it relies on the parent
+// pointers maintained by this package.
+func (el *Element) Index() int {
+	if el.Parent() == nil {
+		return -1
+	}
+	return el.pos
+}
+
+// NextSibling returns the child of el's parent that follows el, or
+// nil if el is the last child or has no parent.
+//
+//	for s := el.NextSibling(); s != nil; s = s.NextSibling() {
+//		// ...
+//	}
+func (el *Element) NextSibling() *Element {
+	p := el.Parent()
+	if p == nil || el.pos+1 >= len(p.Children) {
+		return nil
+	}
+	return &p.Children[el.pos+1]
+}
+
+// PrevSibling returns the child of el's parent that precedes el, or
+// nil if el is the first child or has no parent.
+func (el *Element) PrevSibling() *Element {
+	p := el.Parent()
+	if p == nil || el.pos == 0 {
+		return nil
+	}
+	return &p.Children[el.pos-1]
+}
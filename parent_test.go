@@ -0,0 +1,111 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestParent(t *testing.T) {
+	root := MustParse([]byte(`<a><b><c id="1"/><c id="2"/></b><d/></a>`))
+	c := root.FindAll("//c")[1]
+	var path string
+	for p := c.Parent(); p != nil; p = p.Parent() {
+		path = "/" + p.Name.Local + path
+	}
+	if path != "/a/b" {
+		t.Errorf("ancestors of c: %s, want /a/b", path)
+	}
+	if root.Parent() != nil {
+		t.Error("root has a parent")
+	}
+
+	// Sorting moves b after d; the pointers follow it.
+	err := root.SortChildrenBy("", func(el *Element) string {
+		if el.Name.Local == "b" {
+			return "z"
+		}
+		return el.Name.Local
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &root.Children[1]
+	if b.Name.Local != "b" || b.Parent() != root || b.Children[0].Parent() != b {
+		t.Error("parent pointers not updated by SortChildrenBy")
+	}
+
+	dup := root.Clone()
+	if dup.Children[1].Children[0].Parent() != &dup.Children[1] {
+		t.Error("clone does not set parent pointers")
+	}
+
+	// Appending directly may move the children; Parent notices.
+	moved := &root.Children[0]
+	root.Children = append([]Element{{}}, root.Children...)
+	if moved.Parent() == root {
+		t.Error("stale parent pointer returned")
+	}
+	root.LinkParents()
+	if root.Children[2].Children[1].Parent() != &root.Children[2] {
+		t.Error("LinkParents did not restore pointers")
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	root := MustParse([]byte(`<a><b/><c/><d/></a>`))
+	c := root.FindOne("c")
+	if c == nil {
+		t.Fatal("c not found")
+	}
+	if c.Index() != 1 {
+		t.Errorf("Index() = %d, want 1", c.Index())
+	}
+	if s := c.NextSibling(); s == nil || s.Name.Local != "d" {
+		t.Errorf("NextSibling() = %v, want d", s)
+	}
+	if s := c.PrevSibling(); s == nil || s.Name.Local != "b" {
+		t.Errorf("PrevSibling() = %v, want b", s)
+	}
+	if root.Children[0].PrevSibling() != nil || root.Children[2].NextSibling() != nil {
+		t.Error("sibling beyond the ends of Children")
+	}
+	if root.Index() != -1 || root.NextSibling() != nil {
+		t.Error("root has siblings")
+	}
+}
+
+func TestConstructorsLinkParents(t *testing.T) {
+	src := MustParse([]byte(`<r><a><b/></a><c/></r>`))
+	check := func(name string, root *Element) {
+		t.Helper()
+		for _, el := range root.Flatten() {
+			top := el
+			for p := el.Parent(); p != nil; p = p.Parent() {
+				top = p
+			}
+			if top != root {
+				t.Errorf("%s: %s is not linked to the root", name, el.Name.Local)
+			}
+		}
+	}
+	projected, err := Project(src, "//b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Project", projected)
+	excluded, err := Exclude(src, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Exclude", excluded)
+	check("FromValue", FromValue(map[string]interface{}{"a": map[string]interface{}{"b": "1"}}))
+
+	all := ConcatDocuments(xml.Name{Local: "all"}, src, src)
+	check("ConcatDocuments", all)
+	if b := &all.Children[1].Children[0].Children[0]; b.Parent() != &all.Children[1].Children[0] {
+		t.Error("ConcatDocuments: parent points outside the result")
+	}
+	if b := &src.Children[0].Children[0]; b.Parent() != &src.Children[0] {
+		t.Error("ConcatDocuments relinked its input")
+	}
+}
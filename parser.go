@@ -0,0 +1,162 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+
+	"golang.org/x/net/html/charset"
+)
+
+// maxInternedNames bounds the size of the table of interned names
+// kept by a Parser between documents.
+const maxInternedNames = 1 << 14
+
+// A Parser parses XML documents into trees of Elements. A Parser
+// retains internal buffers and its table of interned names between
+// calls to Parse, which reduces allocations for services that parse
+// many small documents. The encoding/xml package does not allow an
+// xml.Decoder to be reused, so a new one is still created for each
+// document.
+//
+// A Parser is not safe for concurrent use; each goroutine should
+// use its own Parser.
+type Parser struct {
+	opts  *parseOptions
+	r     bytes.Reader
+	names map[string]string
+	stack [][]Element
+}
+
+// NewParser returns a Parser that parses documents with the given
+// options.
+func NewParser(opts ...ParseOption) *Parser {
+	return &Parser{opts: newParseOptions(opts)}
+}
+
+// Reset discards any state retained by the Parser from previous
+// documents.
+func (p *Parser) Reset() {
+	p.r.Reset(nil)
+	p.names = nil
+	p.stack = nil
+}
+
+// release takes back buffers lent to a scanner, clearing any
+// references into the parsed document so that they may be
+// garbage collected.
+func (p *Parser) release(s *scanner) {
+	p.r.Reset(nil)
+	if len(s.names) > maxInternedNames {
+		s.names = nil
+	}
+	p.names = s.names
+	for i, pending := range s.stack {
+		for j := range pending {
+			pending[j] = Element{}
+		}
+		s.stack[i] = pending[:0]
+	}
+	p.stack = s.stack
+}
+
+// Parse builds a tree of Elements from an XML document, in the same
+// manner as the Parse function.
+func (p *Parser) Parse(doc []byte) (*Element, error) {
+	return p.parse(doc, "")
+}
+
+// parse parses doc. If wrapper is not empty, doc is a fragment
+// wrapped in an element of that name, which is not part of the
+// document: it is not reported to WithOnElement or WithParseProgress,
+// nor counted against limits, and selectors and depths are relative
+// to the element it contains.
+func (p *Parser) parse(doc []byte, wrapper string) (*Element, error) {
+	if p.opts.err != nil {
+		return nil, p.opts.err
+	}
+	p.r.Reset(doc)
+	d := xml.NewDecoder(&p.r)
+
+	// The xmltree package, when constructing the tree, takes slices
+	// of the source document for chardata (data between tags). To do
+	// this, it takes the position of the Decoder in the utf-8 input
+	// stream. If the source document is not utf8, the position may be
+	// incorrect and cause invalid data or a run-time panic. So we copy
+	// the utf8 conversion to an internal buffer.
+	utf8buf := bytes.NewBuffer(doc[:0])
+	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		utf8input, err := charset.NewReaderLabel(label, r)
+		if err != nil {
+			return nil, err
+		}
+		// At this point, the encoding/xml package has already
+		// parsed the <?xml?> header. To be able to index
+		// into the document, we need to account for this.
+		padding := make([]byte, int(d.InputOffset()))
+		utf8buf.Write(padding)
+
+		_, err = io.Copy(utf8buf, utf8input)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(utf8buf.Bytes()[len(padding)+1:]), nil
+	}
+	scanner := scanner{
+		Decoder:  d,
+		opts:     p.opts,
+		names:    p.names,
+		stack:    p.stack,
+		slabHint: p.opts.elementHint,
+	}
+	if p.opts.progress != nil {
+		progress := *p.opts.progress
+		scanner.progress = &progress
+	}
+	defer p.release(&scanner)
+	root := AcquireElement()
+
+	var prolog []Misc
+	for scanner.scan() {
+		if start, ok := scanner.tok.(xml.StartElement); ok {
+			root.StartElement = start
+			break
+		}
+		if m, ok := scanner.misc(-1); ok {
+			prolog = append(prolog, m)
+		}
+	}
+	if scanner.err != nil {
+		ReleaseElement(root)
+		return nil, scanner.err
+	}
+	if wrapper != "" {
+		scanner.outer = 1
+		scanner.base = scanner.InputOffset()
+	}
+	if err := root.parse(&scanner, utf8buf.Bytes(), 0); err != nil {
+		return nil, err
+	}
+	if prolog != nil {
+		if err := scanner.account(root, miscFootprint(prolog)); err != nil {
+			return nil, err
+		}
+		root.Misc = append(prolog, root.Misc...)
+	}
+	root.link(0)
+	if scanner.progress != nil {
+		end := scanner.offset()
+		if wrapper != "" {
+			end -= int64(len("</" + wrapper + ">"))
+		}
+		if err := scanner.progress.finish(end); err != nil {
+			return nil, err
+		}
+	}
+	if scanner.opts.rejectTrailing {
+		if err := scanner.trailing(); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,57 @@
+package xmltree
+
+import (
+	"testing"
+)
+
+func TestParserReuse(t *testing.T) {
+	p := NewParser(WithRejectTrailing())
+	docs := []string{
+		`<a><b>1</b><b>2</b></a>`,
+		`<a><b>3</b></a>`,
+		`<a><b>4`,
+		`<a/>garbage`,
+		`<a><c>5</c><c>6</c><c>7</c></a>`,
+	}
+	var results []*Element
+	for i, doc := range docs {
+		el, err := p.Parse([]byte(doc))
+		if (err != nil) != (i == 2 || i == 3) {
+			t.Errorf("Parse(%q): unexpected error result %v", doc, err)
+		}
+		results = append(results, el)
+	}
+	// Elements returned by earlier calls must not be clobbered
+	// by later ones.
+	if s := results[0].String(); s != docs[0] {
+		t.Errorf("first document changed to %s", s)
+	}
+	if s := results[4].String(); s != docs[4] {
+		t.Errorf("last document parsed as %s", s)
+	}
+	p.Reset()
+	if _, err := p.Parse([]byte(docs[1])); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	doc := []byte(`<msg id="1"><to>a</to><from>b</from><body>hello</body></msg>`)
+	b.Run("Parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Parse(doc); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Parser", func(b *testing.B) {
+		b.ReportAllocs()
+		p := NewParser()
+		for i := 0; i < b.N; i++ {
+			if _, err := p.Parse(doc); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
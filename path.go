@@ -0,0 +1,76 @@
+package xmltree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PathTo returns the location of el within the tree rooted at root,
+// as a slash-separated list of qualified element names beginning
+// with the name of root, such as "/envelope/body/item[2]". An index
+// is added to any element that has siblings of the same name,
+// counting from 1. If el is not part of the tree, PathTo returns
+// the empty string.
+func (root *Element) PathTo(el *Element) string {
+	var steps []string
+	if !root.pathTo(el, &steps, 0) {
+		return ""
+	}
+	return "/" + strings.Join(steps, "/")
+}
+
+func (root *Element) pathTo(el *Element, steps *[]string, depth int) bool {
+	*steps = append(*steps, root.Prefix(root.Name))
+	if root == el {
+		return true
+	}
+	if depth <= recursionLimit {
+		for i := range root.Children {
+			if root.Children[i].pathTo(el, steps, depth+1) {
+				(*steps)[depth+1] += siblingIndex(root, i)
+				return true
+			}
+		}
+	}
+	*steps = (*steps)[:depth]
+	return false
+}
+
+// siblingIndex returns the index to add to the path step for the
+// child i of parent, or the empty string if it is the only child of
+// its name.
+func siblingIndex(parent *Element, i int) string {
+	name := parent.Children[i].Name
+	pos, count := 0, 0
+	for j := range parent.Children {
+		if parent.Children[j].Name == name {
+			count++
+			if j <= i {
+				pos++
+			}
+		}
+	}
+	if count > 1 {
+		return fmt.Sprintf("[%d]", pos)
+	}
+	return ""
+}
+
+// path returns the location of el within its tree, in the form
+// returned by PathTo, by following parent pointers up to the root.
+func (el *Element) path() string {
+	var steps []string
+	for depth := 0; el != nil && depth <= recursionLimit; depth++ {
+		step := el.Prefix(el.Name)
+		p := el.Parent()
+		if p != nil {
+			step += siblingIndex(p, el.pos)
+		}
+		steps = append(steps, step)
+		el = p
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return "/" + strings.Join(steps, "/")
+}
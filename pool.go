@@ -0,0 +1,107 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"sync"
+)
+
+// Long-running services that query and transform many documents may
+// reuse the Elements and slices of element pointers they need only
+// briefly, rather than leaving them to the garbage collector. The
+// rules for pooled values are the same as for sync.Pool: a value
+// obtained from AcquireElement or AcquireElements belongs to the
+// caller until it is released, and must not be used, or referred to
+// from anything still in use, once it has been. Values that were not
+// obtained from the pool may be released too, under the same rules.
+// Parse and Node.Element take the root of each tree they build from
+// the pool, so a service that releases each tree it is done with
+// reuses the root Element and the backing arrays of its Attr and
+// Children slices. Only the root is pooled: every other Element in
+// the tree, and its slices, are freshly allocated for each document
+// and left to the garbage collector.
+
+// Slices larger than this are left to the garbage collector rather
+// than being kept in a pool.
+const maxPooledCap = 1 << 16
+
+var (
+	elementPool = sync.Pool{New: func() interface{} { return new(Element) }}
+	listPool    = sync.Pool{New: func() interface{} { return new([]*Element) }}
+	seenPool    = sync.Pool{New: func() interface{} { return make(map[*Element]bool) }}
+)
+
+// AcquireElement returns an empty Element, which may have been used
+// before and released with ReleaseElement.
+func AcquireElement() *Element {
+	return elementPool.Get().(*Element)
+}
+
+// ReleaseElement returns el to the pool used by AcquireElement. The
+// caller gives up el, along with its Attr and Children slices, whose
+// storage may be reused; they must not be shared with any Element
+// that is still in use. The children of el are not released
+// individually, and pointers to them become invalid. Content is not
+// reused, as it may refer to the data of a parsed document.
+func ReleaseElement(el *Element) {
+	attrs, children := el.StartElement.Attr, el.Children
+	if cap(attrs) > maxPooledCap || cap(children) > maxPooledCap {
+		return
+	}
+	for i := range attrs {
+		attrs[i] = xml.Attr{}
+	}
+	for i := range children {
+		children[i] = Element{}
+	}
+	*el = Element{}
+	el.StartElement.Attr = attrs[:0]
+	el.Children = children[:0]
+	elementPool.Put(el)
+}
+
+// AcquireElements returns a pointer to an empty slice of elements,
+// such as may hold the results of a query:
+//
+//	matches := xmltree.AcquireElements()
+//	defer xmltree.ReleaseElements(matches)
+//	*matches = sel.MatchAppend(*matches, root)
+//	for _, el := range *matches {
+//		// ...
+//	}
+//
+// The slice is valid until it is passed to ReleaseElements. The
+// elements it points to are not affected by either function.
+func AcquireElements() *[]*Element {
+	return listPool.Get().(*[]*Element)
+}
+
+// ReleaseElements empties the slice list points to, and returns it to
+// the pool used by AcquireElements. Neither list nor the slice may be
+// used afterwards.
+func ReleaseElements(list *[]*Element) {
+	s := *list
+	if cap(s) > maxPooledCap {
+		return
+	}
+	for i := range s {
+		s[i] = nil
+	}
+	*list = s[:0]
+	listPool.Put(list)
+}
+
+// acquireSeen returns an empty set of elements, to be returned with
+// releaseSeen.
+func acquireSeen() map[*Element]bool {
+	return seenPool.Get().(map[*Element]bool)
+}
+
+func releaseSeen(seen map[*Element]bool) {
+	if len(seen) > maxPooledCap {
+		return
+	}
+	for k := range seen {
+		delete(seen, k)
+	}
+	seenPool.Put(seen)
+}
@@ -0,0 +1,60 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestReleaseElement(t *testing.T) {
+	el := AcquireElement()
+	el.StartElement.Name.Local = "a"
+	el.SetAttr("", "id", "1")
+	el.Children = append(el.Children, Element{Content: []byte("x")})
+	ReleaseElement(el)
+	if el.Name.Local != "" || len(el.StartElement.Attr) != 0 || len(el.Children) != 0 || el.Content != nil {
+		t.Errorf("released element not reset: %+v", el)
+	}
+	if attrs := el.StartElement.Attr[:1]; attrs[0] != (xml.Attr{}) {
+		t.Errorf("released attribute still set: %v", attrs[0])
+	}
+}
+
+func TestMatchAppend(t *testing.T) {
+	root := MustParse([]byte(`<a><b id="1"/><c><b id="2"/></c><b id="3"/></a>`))
+	sel := MustCompileSelector("//b")
+	list := AcquireElements()
+	defer ReleaseElements(list)
+	for i := 0; i < 3; i++ {
+		*list = sel.MatchAppend((*list)[:0], root)
+		var ids string
+		for _, el := range *list {
+			ids += el.Attr("", "id")
+		}
+		if ids != "123" {
+			t.Errorf("run %d: matched %q", i, ids)
+		}
+	}
+	if n, err := root.SetAttrAll("//b", "seen", "yes"); n != 3 || err != nil {
+		t.Errorf("SetAttrAll = %d, %v", n, err)
+	}
+}
+
+func TestReleaseConverted(t *testing.T) {
+	doc, err := ParseDocument([]byte(`<a x="1"><b/><c y="2"/></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := doc.Root.Element().String()
+	for i := 0; i < 3; i++ {
+		el := doc.Root.Element()
+		if got := el.String(); got != want {
+			t.Errorf("run %d: got %s, want %s", i, got, want)
+		}
+		ReleaseElement(el)
+	}
+	root := MustParse([]byte(`<a><b/></a>`))
+	ReleaseElement(root)
+	if got := MustParse([]byte(`<c z="3"/>`)).String(); got != `<c z="3" />` {
+		t.Errorf("Parse after release: got %s", got)
+	}
+}
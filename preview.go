@@ -0,0 +1,218 @@
+package xmltree
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// elisionMarker replaces content that is omitted by MarshalPreview.
+const elisionMarker = "<!-- … -->"
+
+// MarshalPreview produces an abbreviated XML encoding of el, suitable
+// for logging or display of very large documents. The output is
+// always well-formed and never longer than maxBytes; elements that
+// do not fit, elements nested more than maxDepth levels below el,
+// and the tail of long text content are replaced with a "…" comment.
+// If maxDepth is less than or equal to zero, depth is not limited.
+// If not even the root element's tags fit in maxBytes, MarshalPreview
+// returns a bare marker comment, or nil if that doesn't fit either.
+//
+// Comments, processing instructions and text in el.Misc are written
+// where they belong among the children, and are elided like them; the
+// items that precede the root element are left out. CDATA sections
+// are written as escaped text.
+func MarshalPreview(el *Element, maxBytes, maxDepth int) []byte {
+	p := previewer{max: maxBytes, maxDepth: maxDepth}
+	p.enc.w = &p.scratch
+	if p.element(el, nil, 0, false) {
+		return p.buf.Bytes()
+	}
+	p.buf.Reset()
+	if !p.element(el, nil, 0, true) && len(elisionMarker) <= maxBytes {
+		p.buf.WriteString(elisionMarker)
+	}
+	return p.buf.Bytes()
+}
+
+type previewer struct {
+	buf, scratch bytes.Buffer
+	enc          encoder
+	max          int
+	maxDepth     int
+	// bytes that must remain available to close all open elements
+	reserve int
+}
+
+// room returns the number of bytes available for content. If partial
+// is true, room is left for an elision marker.
+func (p *previewer) room(partial bool) int {
+	n := p.max - p.buf.Len() - p.reserve
+	if partial {
+		n -= len(elisionMarker)
+	}
+	return n
+}
+
+// element writes el to the preview. If partial is false, element
+// writes el in its entirety or not at all, returning false if it does
+// not fit; it is up to the caller to discard any partial output. If
+// partial is true, element elides as much of el as necessary to fit,
+// and returns false only if its start and end tags do not fit.
+func (p *previewer) element(el, parent *Element, depth int, partial bool) bool {
+	if depth > recursionLimit {
+		return false
+	}
+	p.scratch.Reset()
+	p.enc.encodeOpenTag(el, parent, diffScope(parent, el), 0)
+	open := p.scratch.String()
+
+	var end string
+	if len(el.Children) > 0 || el.hasContent() || el.hasInnerMisc() {
+		p.scratch.Reset()
+		p.enc.encodeCloseTag(el, 0)
+		end = p.scratch.String()
+	}
+	if len(open)+len(end) > p.room(partial) {
+		return false
+	}
+	p.buf.WriteString(open)
+	if end == "" {
+		return true
+	}
+	p.reserve += len(end)
+	defer func() {
+		p.reserve -= len(end)
+	}()
+	switch {
+	case len(el.Children) == 0:
+		// Markup within text is written before it.
+		if _, ok := p.misc(el, 0, 0, partial); !ok {
+			if !partial {
+				return false
+			}
+			break
+		}
+		content := el.Content
+		if el.spill != nil {
+			// Only read as much as could possibly fit.
+			content = make([]byte, p.room(false)+1)
+			r, err := el.ContentReader()
+			if err == nil {
+				n, _ := io.ReadFull(r, content)
+				content = content[:n]
+				r.Close()
+			}
+		}
+		text, _ := xmlEncodeString(string(content))
+		if len(text) <= p.room(partial) {
+			p.buf.WriteString(text)
+		} else if partial {
+			p.buf.WriteString(truncateEscaped(text, p.room(partial)))
+			p.buf.WriteString(elisionMarker)
+		} else {
+			return false
+		}
+	case p.maxDepth > 0 && depth >= p.maxDepth:
+		if len(elisionMarker) > p.room(partial) {
+			return false
+		}
+		p.buf.WriteString(elisionMarker)
+	case !partial:
+		next, ok := 0, true
+		for i := range el.Children {
+			if next, ok = p.misc(el, next, i, false); !ok {
+				return false
+			}
+			if !p.element(&el.Children[i], el, depth+1, false) {
+				return false
+			}
+		}
+		if _, ok := p.misc(el, next, len(el.Children), false); !ok {
+			return false
+		}
+	default:
+		next, ok := 0, true
+		for i := range el.Children {
+			if next, ok = p.misc(el, next, i, true); !ok {
+				break
+			}
+			// Unless nothing follows this child, room must be
+			// left for a marker in case the next item does not
+			// fit.
+			var marker int
+			if i < len(el.Children)-1 || len(el.Misc) > 0 && el.Misc[len(el.Misc)-1].Index > i {
+				marker = len(elisionMarker)
+			}
+			mark := p.buf.Len()
+			p.reserve += marker
+			ok := p.element(&el.Children[i], el, depth+1, false)
+			p.reserve -= marker
+			if ok {
+				continue
+			}
+			p.buf.Truncate(mark)
+			if !p.element(&el.Children[i], el, depth+1, true) {
+				p.buf.Truncate(mark)
+				p.buf.WriteString(elisionMarker)
+			}
+			ok = false
+			break
+		}
+		if ok {
+			p.misc(el, next, len(el.Children), true)
+		}
+	}
+	p.buf.WriteString(end)
+	return true
+}
+
+// misc writes the Misc items of el, starting at next, that come
+// before the child at index, or all the remaining items if index is
+// len(el.Children), and returns the position of the first item not
+// written. Items that cannot be encoded are skipped. If an item does
+// not fit, misc returns false; if partial is true, it first writes a
+// marker in place of the item, if there is room.
+func (p *previewer) misc(el *Element, next, index int, partial bool) (int, bool) {
+	for ; next < len(el.Misc); next++ {
+		m := el.Misc[next]
+		if m.Index < 0 {
+			continue
+		}
+		if m.Index > index && index < len(el.Children) {
+			break
+		}
+		p.scratch.Reset()
+		if p.enc.encodeMiscItem(el, m) != nil {
+			continue
+		}
+		if p.scratch.Len() > p.room(partial) {
+			if partial && len(elisionMarker) <= p.room(false) {
+				p.buf.WriteString(elisionMarker)
+			}
+			return next, false
+		}
+		p.buf.Write(p.scratch.Bytes())
+	}
+	return next, true
+}
+
+// truncateEscaped shortens XML-escaped text to at most n bytes,
+// without splitting a UTF-8 sequence or an entity reference.
+func truncateEscaped(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	s = s[:n]
+	if amp := strings.LastIndexByte(s, '&'); amp >= 0 && !strings.Contains(s[amp:], ";") {
+		s = s[:amp]
+	}
+	return s
+}
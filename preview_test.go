@@ -0,0 +1,89 @@
+package xmltree
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalPreview(t *testing.T) {
+	root := parseDoc(t, exampleDoc)
+	full := Marshal(root)
+	if out := MarshalPreview(root, len(full), 0); !bytes.Equal(out, full) {
+		t.Errorf("preview with sufficient budget differs from Marshal:\n%s", out)
+	}
+	for _, max := range []int{0, 5, 20, 100, 500, 1000, 2000} {
+		out := MarshalPreview(root, max, 0)
+		if len(out) > max {
+			t.Errorf("MarshalPreview(%d) produced %d bytes", max, len(out))
+		}
+		if len(out) > len(elisionMarker) {
+			if _, err := Parse(out); err != nil {
+				t.Errorf("MarshalPreview(%d) is not well-formed: %v\n%s", max, err, out)
+			}
+		}
+	}
+}
+
+func TestMarshalPreviewDepth(t *testing.T) {
+	root := parseDoc(t, []byte(`<a><b><c>deep</c></b><b/></a>`))
+	out := string(MarshalPreview(root, 1000, 1))
+	want := `<a><b>` + elisionMarker + `</b><b /></a>`
+	if out != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
+
+func TestMarshalPreviewText(t *testing.T) {
+	root := parseDoc(t, []byte(`<a>&amp;&amp;&amp;&amp;&amp;&amp;&amp;&amp;&amp;&amp;</a>`))
+	out := string(MarshalPreview(root, 30, 0))
+	want := `<a>&amp;&amp;` + elisionMarker + `</a>`
+	if out != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
+
+func TestMarshalPreviewBudget(t *testing.T) {
+	docs := [][]byte{
+		exampleDoc,
+		[]byte(`<a><b/><b/><b/><b/><b/><b/><b/><b/><b/><b/></a>`),
+		[]byte(`<a><b>text</b><c><d x="1"/><d>more text</d></c><e/></a>`),
+	}
+	for _, doc := range docs {
+		root := parseDoc(t, doc)
+		full := Marshal(root)
+		for max := 0; max <= len(full); max++ {
+			out := MarshalPreview(root, max, 0)
+			if len(out) > max {
+				t.Errorf("MarshalPreview(%d) produced %d bytes: %s", max, len(out), out)
+			}
+			if len(out) > len(elisionMarker) {
+				if _, err := Parse(out); err != nil {
+					t.Errorf("MarshalPreview(%d) is not well-formed: %v\n%s", max, err, out)
+				}
+			}
+		}
+	}
+}
+
+func TestMarshalPreviewMisc(t *testing.T) {
+	if out := string(MarshalPreview(MustParse([]byte(`<a><!--c--></a>`), WithComments()), 100, 0)); out != `<a><!--c--></a>` {
+		t.Errorf("got %s", out)
+	}
+	root := MustParse([]byte(`<a>one <b>two</b><!--three--> four <?p five?><c>six</c> seven</a>`),
+		WithComments(), WithProcInsts(), WithMixedContent())
+	full := Marshal(root)
+	if out := MarshalPreview(root, len(full), 0); !bytes.Equal(out, full) {
+		t.Errorf("preview with sufficient budget differs from Marshal:\n%s", out)
+	}
+	for max := 0; max < len(full); max++ {
+		out := MarshalPreview(root, max, 0)
+		if len(out) > max {
+			t.Errorf("MarshalPreview(%d) produced %d bytes", max, len(out))
+		}
+		if len(out) > len(elisionMarker) {
+			if _, err := Parse(out); err != nil {
+				t.Errorf("MarshalPreview(%d) is not well-formed: %v\n%s", max, err, out)
+			}
+		}
+	}
+}
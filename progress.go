@@ -0,0 +1,82 @@
+package xmltree
+
+// Progress describes how much of its work Parse or Encode has done.
+type Progress struct {
+	// Bytes of input consumed by Parse, or of output written by
+	// Encode.
+	Bytes int64
+	// Elements parsed or encoded.
+	Elements int
+}
+
+type progress struct {
+	every int64
+	fn    func(Progress) error
+	next  int64
+	Progress
+}
+
+// update counts an element at the given byte offset, calling the
+// callback if another interval has passed.
+func (p *progress) update(offset int64) error {
+	p.Elements++
+	p.Bytes = offset
+	if offset < p.next {
+		return nil
+	}
+	p.next = offset + p.every
+	return p.fn(p.Progress)
+}
+
+// finish calls the callback a final time with the totals.
+func (p *progress) finish(offset int64) error {
+	p.Bytes = offset
+	return p.fn(p.Progress)
+}
+
+// WithParseProgress calls fn periodically while parsing: at the start
+// of the first element that begins at least every bytes into the
+// input since the previous call, and once more when parsing is
+// complete. If fn returns an error, Parse stops and returns it; this
+// may be used to enforce a deadline.
+func WithParseProgress(every int64, fn func(Progress) error) ParseOption {
+	return func(o *parseOptions) {
+		o.progress = &progress{every: every, fn: fn}
+	}
+}
+
+// WithEncodeProgress calls fn periodically while encoding, after every
+// bytes of output, and once more when encoding is complete, in the
+// same manner as WithParseProgress. If fn returns an error, encoding
+// stops and the error is returned.
+func WithEncodeProgress(every int64, fn func(Progress) error) EncodeOption {
+	return func(e *encoder) {
+		e.progress = &progress{every: every, fn: fn}
+	}
+}
+
+// A progressWriter counts the bytes written through it.
+type progressWriter struct {
+	writer
+	n int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *progressWriter) WriteString(s string) (int, error) {
+	n, err := w.writer.WriteString(s)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *progressWriter) WriteByte(c byte) error {
+	err := w.writer.WriteByte(c)
+	if err == nil {
+		w.n++
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package xmltree
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	doc := recordDoc(1000)
+	var reports []Progress
+	p := NewParser(WithParseProgress(int64(len(doc)/10), func(p Progress) error {
+		reports = append(reports, p)
+		return nil
+	}))
+	for i := 0; i < 2; i++ {
+		reports = nil
+		if _, err := p.Parse(doc); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(reports); n < 10 || n > 12 {
+			t.Errorf("got %d progress reports", n)
+		}
+		last := reports[len(reports)-1]
+		if last.Bytes != int64(len(doc)) || last.Elements != 4001 {
+			t.Errorf("final report %+v", last)
+		}
+		for i := 1; i < len(reports); i++ {
+			if reports[i].Bytes < reports[i-1].Bytes || reports[i].Elements < reports[i-1].Elements {
+				t.Errorf("progress went backwards: %+v", reports)
+			}
+		}
+	}
+
+	errDeadline := errors.New("deadline")
+	_, err := Parse(doc, WithParseProgress(100, func(p Progress) error {
+		if p.Elements > 50 {
+			return errDeadline
+		}
+		return nil
+	}))
+	if err != errDeadline {
+		t.Errorf("Parse returned %v", err)
+	}
+}
+
+func TestEncodeProgress(t *testing.T) {
+	root := parseDoc(t, recordDoc(1000))
+	size, err := root.EncodedSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last Progress
+	calls := 0
+	err = Encode(io.Discard, root, WithEncodeProgress(size/5, func(p Progress) error {
+		calls++
+		last = p
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls < 5 || calls > 7 {
+		t.Errorf("got %d progress reports", calls)
+	}
+	if last.Bytes != size || last.Elements != 4001 {
+		t.Errorf("final report %+v, want %d bytes", last, size)
+	}
+	errStop := errors.New("stop")
+	if err := Encode(io.Discard, root, WithEncodeProgress(1, func(Progress) error { return errStop })); err != errStop {
+		t.Errorf("Encode returned %v", err)
+	}
+	if n, err := root.EncodedSize(WithEncodeProgress(1, func(Progress) error { return errStop })); err != nil || n != size {
+		t.Errorf("EncodedSize with progress = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,108 @@
+package xmltree
+
+// Project returns a copy of the tree rooted at el containing only the
+// elements matching any of the selectors, together with their
+// descendants and ancestors. Ancestors keep their attributes and
+// namespace scope, so that the encoded result declares every
+// namespace the matched elements need. See Selector for the selector
+// syntax. If nothing matches, the result is a copy of el without
+// children.
+func Project(el *Element, selectors ...string) (*Element, error) {
+	matched, err := matchSelectors(el, selectors)
+	if err != nil {
+		return nil, err
+	}
+	result := shallowCopy(el)
+	projectChildren(result, el, matched, 0)
+	result.LinkParents()
+	return result, nil
+}
+
+func projectChildren(dst, src *Element, matched map[*Element]bool, depth int) bool {
+	if depth > recursionLimit {
+		return false
+	}
+	// before[i] is the number of children kept before position i
+	before := make([]int, len(src.Children)+1)
+	for i := range src.Children {
+		before[i+1] = before[i]
+		child := &src.Children[i]
+		if matched[child] {
+			dst.Children = append(dst.Children, *child.Clone())
+			before[i+1]++
+			continue
+		}
+		dup := shallowCopy(child)
+		if projectChildren(dup, child, matched, depth+1) {
+			dst.Children = append(dst.Children, *dup)
+			before[i+1]++
+		}
+	}
+	dst.Misc = keepMisc(dst.Misc, before)
+	return len(dst.Children) > 0
+}
+
+// Exclude returns a copy of the tree rooted at el with the elements
+// matching any of the selectors, and their descendants, removed. It
+// is the inverse of Project. See Selector for the selector syntax.
+func Exclude(el *Element, selectors ...string) (*Element, error) {
+	matched, err := matchSelectors(el, selectors)
+	if err != nil {
+		return nil, err
+	}
+	result := excludeFrom(el, matched, 0)
+	result.LinkParents()
+	return result, nil
+}
+
+func excludeFrom(el *Element, matched map[*Element]bool, depth int) *Element {
+	if len(el.Children) == 0 || depth > recursionLimit {
+		return el.Clone()
+	}
+	result := shallowCopy(el)
+	before := make([]int, len(el.Children)+1)
+	for i := range el.Children {
+		before[i+1] = before[i]
+		if !matched[&el.Children[i]] {
+			result.Children = append(result.Children, *excludeFrom(&el.Children[i], matched, depth+1))
+			before[i+1]++
+		}
+	}
+	result.Misc = keepMisc(result.Misc, before)
+	return result
+}
+
+// shallowCopy copies an element without its children. The content of
+// an element with children is the raw text of those children, so it
+// is not copied. The caller must adjust the Misc items of the copy
+// to the children it keeps.
+func shallowCopy(el *Element) *Element {
+	dup := &Element{
+		StartElement: el.StartElement.Copy(),
+		Scope:        el.Scope,
+		xmlns:        el.xmlns,
+		prefix:       el.prefix,
+		attrPrefixes: el.attrPrefixes,
+		Misc:         el.Misc,
+	}
+	if len(el.Children) == 0 {
+		dup.Content = el.Content
+		dup.CDATA = el.CDATA
+		dup.spill = el.spill
+	}
+	return dup
+}
+
+func matchSelectors(el *Element, selectors []string) (map[*Element]bool, error) {
+	matched := make(map[*Element]bool)
+	for _, expr := range selectors {
+		sel, err := CompileSelector(expr)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range sel.MatchAll(el) {
+			matched[m] = true
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,61 @@
+package xmltree
+
+import "testing"
+
+const projectDoc = `<r xmlns:p="urn:p" a="1"><x><p:y id="1">one</p:y><z>skip</z></x><w><p:y id="2"/></w><v/></r>`
+
+func TestProject(t *testing.T) {
+	root := parseDoc(t, []byte(projectDoc))
+	tests := []struct {
+		selectors []string
+		want      string
+	}{
+		{[]string{"//p:y"}, `<r a="1" xmlns:p="urn:p"><x><p:y id="1">one</p:y></x><w><p:y id="2" /></w></r>`},
+		{[]string{"x/z", "v"}, `<r a="1" xmlns:p="urn:p"><x><z>skip</z></x><v /></r>`},
+		{[]string{"nothing"}, `<r a="1" xmlns:p="urn:p" />`},
+	}
+	for _, tt := range tests {
+		el, err := Project(root, tt.selectors...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(Marshal(el)); got != tt.want {
+			t.Errorf("Project(%q):\ngot  %s\nwant %s", tt.selectors, got, tt.want)
+		}
+	}
+	if _, err := Project(root, "["); err == nil {
+		t.Error("invalid selector accepted")
+	}
+}
+
+func TestExclude(t *testing.T) {
+	root := parseDoc(t, []byte(projectDoc))
+	el, err := Exclude(root, "//p:y", "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<r a="1" xmlns:p="urn:p"><x><z>skip</z></x><w /></r>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := string(Marshal(root)); got != `<r a="1" xmlns:p="urn:p"><x><p:y id="1">one</p:y><z>skip</z></x><w><p:y id="2" /></w><v /></r>` {
+		t.Errorf("Exclude modified its input: %s", got)
+	}
+}
+
+func TestProjectMisc(t *testing.T) {
+	root := MustParse([]byte(`<list><!--first--><item>1</item><!--second--><item>2</item><!--end--></list>`), WithComments())
+	el, err := Exclude(root, "//item[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := el.String(), `<list><!--first--><!--second--><item>2</item><!--end--></list>`; got != want {
+		t.Errorf("Exclude:\ngot  %s\nwant %s", got, want)
+	}
+	if el, err = Project(root, "//item[2]"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := el.String(), `<list><!--first--><!--second--><item>2</item><!--end--></list>`; got != want {
+		t.Errorf("Project:\ngot  %s\nwant %s", got, want)
+	}
+}
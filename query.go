@@ -0,0 +1,135 @@
+package xmltree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Query is a chainable lookup that tolerates missing nodes. Each step
+// returns a new Query; once a step fails, every later step also fails,
+// and the first failure is reported by Err. This makes it possible to
+// write
+//
+//	id := root.Query().Child("order").Child("item").Attr("id")
+//	if !id.Exists() {
+//		log.Print(id.Err())
+//	}
+//
+// without checking for nil at each level.
+type Query struct {
+	root  *Element
+	el    *Element
+	path  string
+	value string
+	leaf  bool // value holds an attribute or text value
+	err   error
+}
+
+// ErrNotFound is wrapped by the error returned from Query.Err when a
+// step matched nothing.
+var ErrNotFound = errors.New("not found")
+
+// Query returns a Query rooted at el.
+func (el *Element) Query() Query {
+	return Query{root: el, el: el, path: "/" + el.Prefix(el.Name)}
+}
+
+func (q Query) fail(step string, err error) Query {
+	q.el, q.value, q.leaf = nil, "", false
+	q.err = fmt.Errorf("xmltree: %s: %s: %w", q.path, step, err)
+	return q
+}
+
+func (q Query) usable(step string) (Query, bool) {
+	if q.err != nil {
+		return q, false
+	}
+	if q.leaf {
+		return q.fail(step, errors.New("not an element")), false
+	}
+	return q, true
+}
+
+// Child selects the first element matching selector relative to the
+// current element. selector may be a simple name or any expression
+// accepted by CompileSelector.
+func (q Query) Child(selector string) Query {
+	q, ok := q.usable(selector)
+	if !ok {
+		return q
+	}
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return q.fail(selector, err)
+	}
+	match := sel.Match(q.el)
+	if match == nil {
+		return q.fail(selector, ErrNotFound)
+	}
+	q.el = match
+	q.path = strings.TrimSuffix(q.path, "/") + "/" + strings.TrimPrefix(selector, "/")
+	return q
+}
+
+// Attr selects the value of an attribute of the current element. The
+// name may include a namespace prefix.
+func (q Query) Attr(name string) Query {
+	q, ok := q.usable("@" + name)
+	if !ok {
+		return q
+	}
+	v, found := q.el.lookupAttr(name)
+	if !found {
+		return q.fail("@"+name, ErrNotFound)
+	}
+	q.path += "/@" + name
+	q.value, q.leaf = v, true
+	return q
+}
+
+// Text selects the text content of the current element.
+func (q Query) Text() Query {
+	q, ok := q.usable("text()")
+	if !ok {
+		return q
+	}
+	content, err := q.el.contentBytes()
+	if err != nil {
+		return q.fail("text()", err)
+	}
+	q.value, q.leaf = string(content), true
+	return q
+}
+
+// Exists reports whether every step of the query succeeded.
+func (q Query) Exists() bool {
+	return q.err == nil && (q.el != nil || q.leaf)
+}
+
+// Err returns the error from the first failed step, or nil.
+func (q Query) Err() error {
+	return q.err
+}
+
+// Element returns the selected element, or nil if the query failed or
+// selected a value.
+func (q Query) Element() *Element {
+	if q.err != nil || q.leaf {
+		return nil
+	}
+	return q.el
+}
+
+// String returns the selected attribute or text value. If the query
+// selected an element, its text content is returned. If the query
+// failed, String returns the empty string.
+func (q Query) String() string {
+	if q.leaf {
+		return q.value
+	}
+	if q.el == nil || q.err != nil {
+		return ""
+	}
+	return q.Text().value
+}
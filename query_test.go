@@ -0,0 +1,36 @@
+package xmltree
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	root := parseDoc(t, googleSOAP)
+	q := root.Query().Child("Body").Child("doGoogleSearchResponse").Child("return")
+	if !q.Exists() || q.Element() == nil {
+		t.Fatalf("query failed: %v", q.Err())
+	}
+	if s := q.Child("searchTime").String(); s != "0.194871" {
+		t.Errorf("searchTime = %q", s)
+	}
+	if s := q.Child("resultElements").Attr("soapenc:arrayType").String(); s != "google:ResultElement[3]" {
+		t.Errorf("arrayType = %q", s)
+	}
+
+	missing := q.Child("nothing").Child("deeper").Attr("id")
+	if missing.Exists() || missing.String() != "" || missing.Element() != nil {
+		t.Error("missing node reported as present")
+	}
+	if err := missing.Err(); !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "/return: nothing") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if err := q.Child("searchTime").Text().Child("x").Err(); err == nil {
+		t.Error("selecting a child of a value succeeded")
+	}
+	if err := q.Child("a[").Err(); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("invalid selector gave %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// A PrefixRename records a namespace prefix that the encoder replaced
+// with another, because the prefix was bound to a different namespace
+// where it was needed.
+type PrefixRename struct {
+	Space string // the namespace URI
+	From  string // the prefix in the tree, or "" for the default namespace
+	To    string // the prefix written in its place
+}
+
+// WithRenamePrefixes resolves conflicts between namespace prefixes by
+// renaming one of them, adding a numeric suffix, rather than failing.
+// With WithHoistNamespaces, a prefix that is bound to a second
+// namespace elsewhere in the document is renamed for that namespace,
+// so that all declarations may still be written on the root element.
+// report, if not nil, is called once for each renamed prefix, so that
+// the caller can keep track of the names in the output.
+//
+// Independently of this option, the encoder renames a prefix wherever
+// the tree refers to a namespace whose prefixes are all bound to other
+// namespaces at that point, as may happen when subtrees are moved
+// between documents by hand. The report function is called for such
+// renames too.
+func WithRenamePrefixes(report func(PrefixRename)) EncodeOption {
+	return func(e *encoder) {
+		e.renamed = make(map[xml.Name]string)
+		e.renameReport = report
+	}
+}
+
+// rename records that the binding of b.Local to b.Space is written as
+// the prefix to, and reports it once.
+func (e *encoder) rename(b xml.Name, to string) {
+	r := PrefixRename{Space: b.Space, From: b.Local, To: to}
+	if e.renameReport == nil || e.reported[r] {
+		return
+	}
+	if e.reported == nil {
+		e.reported = make(map[PrefixRename]bool)
+	}
+	e.reported[r] = true
+	e.renameReport(r)
+}
+
+// freshPrefix returns the first of prefix1, prefix2, ... for which
+// taken returns false. The default namespace is renamed to ns1, ns2,
+// and so on.
+func freshPrefix(prefix string, taken func(string) bool) string {
+	if prefix == "" {
+		prefix = "ns"
+	}
+	for i := 1; ; i++ {
+		p := prefix + strconv.Itoa(i)
+		if !taken(p) {
+			return p
+		}
+	}
+}
+
+// tagRenames returns the namespace declarations that must be added to
+// the start tag of el for its name and the names of its attributes to
+// be written unambiguously: one for each namespace whose prefixes are
+// all shadowed in the Scope of el.
+func (e *encoder) tagRenames(el *Element) []xml.Name {
+	var decls []xml.Name
+	check := func(name xml.Name, attr bool) {
+		switch name.Space {
+		case "", xmlLangURI, xmlNamespaceURI:
+			return
+		}
+		q := el.Prefix(name)
+		if attr {
+			q = attrPrefix(el, name)
+		}
+		if q != "" && (!attr || strings.Contains(q, ":")) {
+			return
+		}
+		for _, d := range decls {
+			if d.Space == name.Space {
+				return
+			}
+		}
+		// Only namespaces that are bound, but hidden, are
+		// renamed; the tree says nothing about the prefix for
+		// a namespace that was never declared.
+		from, found := "", false
+		for i := len(el.ns) - 1; i >= 0; i-- {
+			if b := el.ns[i]; b.Space == name.Space && (b.Local != "" || !attr) {
+				from, found = b.Local, true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+		to := freshPrefix(from, func(p string) bool {
+			if _, ok := el.binding(p); ok {
+				return true
+			}
+			if _, ok := e.hoisted[p]; ok {
+				return true
+			}
+			for _, d := range decls {
+				if d.Local == p {
+					return true
+				}
+			}
+			return false
+		})
+		decls = append(decls, xml.Name{Space: name.Space, Local: to})
+		e.rename(xml.Name{Space: name.Space, Local: from}, to)
+	}
+	check(el.Name, false)
+	for _, a := range el.StartElement.Attr {
+		check(a.Name, true)
+	}
+	return decls
+}
+
+// qualify returns the qualified name to write for name, which is the
+// name of el or of one of its attributes, given the declarations
+// returned by tagRenames.
+func (e *encoder) qualify(el *Element, name xml.Name, renames []xml.Name) string {
+	return e.renamedName(el.Prefix(name), name, renames)
+}
+
+// qualifyAttr is like qualify, for the name of an attribute of el.
+func (e *encoder) qualifyAttr(el *Element, name xml.Name, renames []xml.Name) string {
+	return e.renamedName(attrPrefix(el, name), name, renames)
+}
+
+// renamedName returns qname, the qualified form of name, with any
+// renamed prefix replaced.
+func (e *encoder) renamedName(qname string, name xml.Name, renames []xml.Name) string {
+	for _, d := range renames {
+		if d.Space == name.Space {
+			return d.Local + ":" + name.Local
+		}
+	}
+	if len(e.renamed) > 0 {
+		if i := strings.IndexByte(qname, ':'); i > 0 {
+			if to, ok := e.renamed[xml.Name{Space: name.Space, Local: qname[:i]}]; ok {
+				return to + qname[i:]
+			}
+		}
+	}
+	return qname
+}
+
+// attrPrefix returns the qualified name for the attribute name in the
+// scope of el, as Prefix does, except that a prefixed binding of its
+// namespace is preferred to the default namespace, which does not
+// apply to attributes.
+func attrPrefix(el *Element, name xml.Name) string {
+	qname := el.Prefix(name)
+	if name.Space == "" || strings.Contains(qname, ":") {
+		return qname
+	}
+	for i := len(el.ns) - 1; i >= 0; i-- {
+		if b := el.ns[i]; b.Space == name.Space && b.Local != "" && !el.shadowed(i) {
+			return b.Local + ":" + name.Local
+		}
+	}
+	return qname
+}
@@ -0,0 +1,57 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestRenamePrefixesHoisted(t *testing.T) {
+	root := MustParse([]byte(`<doc><p:x xmlns:p="urn:one"/><p:y xmlns:p="urn:two" p:at="v"/><p:z xmlns:p="urn:two"/></doc>`))
+	var renames []PrefixRename
+	got := string(Marshal(root, WithHoistNamespaces(), WithRenamePrefixes(func(r PrefixRename) {
+		renames = append(renames, r)
+	})))
+	want := `<doc xmlns:p="urn:one" xmlns:p1="urn:two"><p:x /><p1:y p1:at="v" /><p1:z /></doc>`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if len(renames) != 1 || renames[0] != (PrefixRename{Space: "urn:two", From: "p", To: "p1"}) {
+		t.Errorf("unexpected renames %v", renames)
+	}
+	if reparsed := MustParse([]byte(got)); !Equal(reparsed, root) {
+		t.Errorf("renamed document differs from the original")
+	}
+}
+
+func TestRenameShadowedPrefix(t *testing.T) {
+	// A subtree moved by hand: c is in urn:a, but the prefix m
+	// is rebound to urn:b in its scope.
+	root := MustParse([]byte(`<m:r xmlns:m="urn:a"><m:c/></m:r>`))
+	c := &root.Children[0]
+	c.Scope.ns = append(c.Scope.ns[:1:1], xml.Name{Space: "urn:b", Local: "m"})
+
+	var renames []PrefixRename
+	got := string(Marshal(root, WithRenamePrefixes(func(r PrefixRename) {
+		renames = append(renames, r)
+	})))
+	want := `<m:r xmlns:m="urn:a"><m1:c xmlns:m="urn:b" xmlns:m1="urn:a" /></m:r>`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if len(renames) != 1 || renames[0] != (PrefixRename{Space: "urn:a", From: "m", To: "m1"}) {
+		t.Errorf("unexpected renames %v", renames)
+	}
+	if name := MustParse([]byte(got)).Children[0].Name; name.Space != "urn:a" {
+		t.Errorf("c resolves to %s", name.Space)
+	}
+}
+
+func TestRenameDefaultNamespaceAttr(t *testing.T) {
+	// The attribute must be written with the prefix p, although
+	// its namespace is also the default one.
+	doc := `<r xmlns="urn:a" xmlns:p="urn:a"><c p:x="1" /></r>`
+	root := MustParse([]byte(doc))
+	if got := string(Marshal(root)); got != doc {
+		t.Errorf("got  %s\nwant %s", got, doc)
+	}
+}
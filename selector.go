@@ -0,0 +1,435 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Selector is a compiled path expression, used to find elements
+// within a tree. Selectors use a small subset of XPath syntax:
+//
+//	item          children of the context element named item
+//	order/item    item children of order children
+//	//item        item elements at any depth below the context element
+//	order//sku    sku elements at any depth below order children
+//	*             children with any name
+//	p:item        item in the namespace bound to prefix p at the
+//	              context element
+//	{urn:x}item   item in the namespace urn:x
+//	item[2]       the second matching item child, counting from 1
+//	//item[2]     every item that is the second item child of its
+//	              parent
+//	item[@id]     item children with an id attribute
+//	item[@id='7'] item children whose id attribute is 7
+//
+// A leading "/" is permitted, and has no effect; selectors are always
+// evaluated relative to the element they are applied to. Names without
+// a prefix or namespace match elements in any namespace.
+type Selector struct {
+	expr  string
+	steps []selectorStep
+}
+
+type selectorStep struct {
+	descendant bool
+	prefix     string
+	space      string
+	anySpace   bool
+	local      string // "*" matches any name
+	preds      []selectorPred
+}
+
+type selectorPred struct {
+	index    int // if non-zero, a position predicate
+	attr     string
+	value    string
+	hasValue bool
+}
+
+// CompileSelector parses a selector expression.
+func CompileSelector(expr string) (*Selector, error) {
+	sel := &Selector{expr: expr}
+	s := strings.TrimPrefix(expr, "/")
+	if strings.HasPrefix(expr, "//") {
+		s = expr
+	}
+	descendant := false
+	for s != "" {
+		if strings.HasPrefix(s, "/") {
+			s = s[1:]
+			if strings.HasPrefix(s, "/") {
+				descendant = true
+				s = s[1:]
+			}
+		}
+		var step selectorStep
+		var err error
+		step, s, err = parseStep(s)
+		if err != nil {
+			return nil, fmt.Errorf("xmltree: invalid selector %q: %v", expr, err)
+		}
+		step.descendant = descendant
+		descendant = false
+		sel.steps = append(sel.steps, step)
+		if s != "" && s[0] != '/' {
+			return nil, fmt.Errorf("xmltree: invalid selector %q: unexpected %q", expr, s)
+		}
+	}
+	if len(sel.steps) == 0 {
+		return nil, fmt.Errorf("xmltree: invalid selector %q: empty", expr)
+	}
+	return sel, nil
+}
+
+// MustCompileSelector is like CompileSelector, but panics if the
+// expression cannot be parsed. It is intended for package-level
+// selector variables.
+func MustCompileSelector(expr string) *Selector {
+	sel, err := CompileSelector(expr)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+func (sel *Selector) String() string {
+	return sel.expr
+}
+
+func parseStep(s string) (selectorStep, string, error) {
+	step := selectorStep{anySpace: true}
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return step, s, fmt.Errorf("unterminated namespace")
+		}
+		step.space, step.anySpace = s[1:end], false
+		s = s[end+1:]
+	}
+	n := strings.IndexAny(s, "/[")
+	if n < 0 {
+		n = len(s)
+	}
+	name := s[:n]
+	s = s[n:]
+	if i := strings.IndexByte(name, ':'); i >= 0 && step.anySpace {
+		step.prefix, name = name[:i], name[i+1:]
+		step.anySpace = false
+	}
+	if name == "" {
+		return step, s, fmt.Errorf("missing element name")
+	}
+	step.local = name
+	for strings.HasPrefix(s, "[") {
+		end := predEnd(s)
+		if end < 0 {
+			return step, s, fmt.Errorf("unterminated predicate")
+		}
+		pred, err := parsePred(s[1:end])
+		if err != nil {
+			return step, s, err
+		}
+		step.preds = append(step.preds, pred)
+		s = s[end+1:]
+	}
+	return step, s, nil
+}
+
+// predEnd returns the index of the "]" closing the predicate at the
+// start of s, skipping over quoted attribute values, or -1.
+func predEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parsePred(s string) (selectorPred, error) {
+	var pred selectorPred
+	if !strings.HasPrefix(s, "@") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return pred, fmt.Errorf("invalid predicate [%s]", s)
+		}
+		pred.index = n
+		return pred, nil
+	}
+	s = s[1:]
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		value := s[i+1:]
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return pred, fmt.Errorf("attribute value must be quoted in [@%s]", s)
+		}
+		pred.value, pred.hasValue = value[1:len(value)-1], true
+		s = s[:i]
+	}
+	if s == "" {
+		return pred, fmt.Errorf("missing attribute name")
+	}
+	pred.attr = s
+	return pred, nil
+}
+
+// MatchAll returns the elements selected by sel, relative to el, in
+// document order.
+func (sel *Selector) MatchAll(el *Element) []*Element {
+	return sel.MatchAppend(nil, el)
+}
+
+// MatchAppend is like MatchAll, but appends the selected elements to
+// dst and returns the extended slice. Together with AcquireElements,
+// it allows a query to be run repeatedly without allocating a new
+// slice for the results each time.
+func (sel *Selector) MatchAppend(dst []*Element, el *Element) []*Element {
+	context, next := AcquireElements(), AcquireElements()
+	defer ReleaseElements(context)
+	defer ReleaseElements(next)
+	seen := acquireSeen()
+	defer releaseSeen(seen)
+
+	*context = append(*context, el)
+	for _, step := range sel.steps {
+		for _, ctx := range *context {
+			for _, match := range step.apply(el, ctx) {
+				if !seen[match] {
+					seen[match] = true
+					*next = append(*next, match)
+				}
+			}
+		}
+		for k := range seen {
+			delete(seen, k)
+		}
+		context, next = next, context
+		*next = (*next)[:0]
+		if len(*context) == 0 {
+			break
+		}
+	}
+	return append(dst, *context...)
+}
+
+// Match returns the first element selected by sel, relative to el,
+// or nil if there is none.
+func (sel *Selector) Match(el *Element) *Element {
+	if all := sel.MatchAll(el); len(all) > 0 {
+		return all[0]
+	}
+	return nil
+}
+
+// apply returns the elements matched by a single step from ctx. Any
+// prefixes are resolved in the scope of root.
+func (step *selectorStep) apply(root, ctx *Element) []*Element {
+	name := step.name(root)
+	test := func(el *Element) bool {
+		return step.test(name, el)
+	}
+	if step.descendant && step.positional() {
+		return step.applyEach(ctx, test)
+	}
+	var candidates []*Element
+	if step.descendant {
+		candidates = ctx.SearchFunc(test)
+	} else {
+		for i := range ctx.Children {
+			if test(&ctx.Children[i]) {
+				candidates = append(candidates, &ctx.Children[i])
+			}
+		}
+	}
+	return step.filter(candidates)
+}
+
+// applyEach returns the descendants of ctx matched by a step with a
+// position predicate. As in XPath, positions count among the matching
+// children of each parent, so //item[2] selects every item that is
+// the second item child of its parent.
+func (step *selectorStep) applyEach(ctx *Element, test func(*Element) bool) []*Element {
+	keep := make(map[*Element]bool)
+	for _, parent := range append([]*Element{ctx}, ctx.Flatten()...) {
+		var group []*Element
+		for i := range parent.Children {
+			if test(&parent.Children[i]) {
+				group = append(group, &parent.Children[i])
+			}
+		}
+		for _, el := range step.filter(group) {
+			keep[el] = true
+		}
+	}
+	return ctx.SearchFunc(func(el *Element) bool { return keep[el] })
+}
+
+// filter applies the predicates of step, in order, to candidates
+// that share a parent.
+func (step *selectorStep) filter(candidates []*Element) []*Element {
+	for _, pred := range step.preds {
+		candidates = pred.filter(candidates)
+	}
+	return candidates
+}
+
+// name returns the name matched by step, resolving any prefix in the
+// scope of root.
+func (step *selectorStep) name(root *Element) xml.Name {
+	if step.prefix != "" {
+		return root.Resolve(step.prefix + ":" + step.local)
+	}
+	return xml.Name{Space: step.space, Local: step.local}
+}
+
+// test reports whether el matches the name of a step, as returned by
+// the name method.
+func (step *selectorStep) test(name xml.Name, el *Element) bool {
+	if step.local != "*" && el.Name.Local != name.Local {
+		return false
+	}
+	return step.anySpace || el.Name.Space == name.Space
+}
+
+// matchPath reports whether sel, applied to path[0], would match the
+// last element of path, where each element of path is the parent of
+// the next. Position predicates are not considered; see positional.
+func (sel *Selector) matchPath(path []*Element) bool {
+	return sel.matchFrom(0, 0, path)
+}
+
+// matchFrom reports whether steps[i:] match the elements of path
+// following path[j], ending at the last one.
+func (sel *Selector) matchFrom(i, j int, path []*Element) bool {
+	if i == len(sel.steps) {
+		return j == len(path)-1
+	}
+	step := &sel.steps[i]
+	name := step.name(path[0])
+	for k := j + 1; k < len(path); k++ {
+		if step.test(name, path[k]) && step.attrsMatch(path[k]) && sel.matchFrom(i+1, k, path) {
+			return true
+		}
+		if !step.descendant {
+			break
+		}
+	}
+	return false
+}
+
+func (step *selectorStep) attrsMatch(el *Element) bool {
+	for _, pred := range step.preds {
+		if pred.index == 0 && len(pred.filter([]*Element{el})) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// positional reports whether sel uses position predicates, such as
+// item[2].
+func (sel *Selector) positional() bool {
+	for i := range sel.steps {
+		if sel.steps[i].positional() {
+			return true
+		}
+	}
+	return false
+}
+
+// positional reports whether step has a position predicate.
+func (step *selectorStep) positional() bool {
+	for _, pred := range step.preds {
+		if pred.index > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (pred *selectorPred) filter(list []*Element) []*Element {
+	if pred.index > 0 {
+		if pred.index > len(list) {
+			return nil
+		}
+		return list[pred.index-1 : pred.index]
+	}
+	var result []*Element
+	for _, el := range list {
+		if v, ok := el.lookupAttr(pred.attr); ok && (!pred.hasValue || v == pred.value) {
+			result = append(result, el)
+		}
+	}
+	return result
+}
+
+// lookupAttr finds an attribute by its qualified name, resolving any
+// prefix in the element's scope.
+func (el *Element) lookupAttr(qname string) (string, bool) {
+	space, local := "", qname
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		name := el.Resolve(qname)
+		space, local = name.Space, name.Local
+	}
+	for _, a := range el.StartElement.Attr {
+		if a.Name.Local == local && (space == "" || a.Name.Space == space) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// FindAll returns the elements matching selector, relative to el. See
+// Selector for the syntax. An invalid selector matches nothing.
+func (el *Element) FindAll(selector string) []*Element {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return nil
+	}
+	return sel.MatchAll(el)
+}
+
+// FindOne returns the first element matching selector, relative to
+// el, or nil if there is none.
+func (el *Element) FindOne(selector string) *Element {
+	if all := el.FindAll(selector); len(all) > 0 {
+		return all[0]
+	}
+	return nil
+}
+
+// FindText returns the text content of the first element matching
+// selector. The second return value is false if no element matches.
+func (el *Element) FindText(selector string) (string, bool) {
+	match := el.FindOne(selector)
+	if match == nil {
+		return "", false
+	}
+	content, err := match.contentBytes()
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+// FindAttr returns the value of attribute attr on the first element
+// matching selector that has it. The attribute name may include a
+// namespace prefix. The second return value is false if there is no
+// such element.
+func (el *Element) FindAttr(selector, attr string) (string, bool) {
+	for _, match := range el.FindAll(selector) {
+		if v, ok := match.lookupAttr(attr); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
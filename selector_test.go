@@ -0,0 +1,81 @@
+package xmltree
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	root := parseDoc(t, googleSOAP)
+	tests := []struct {
+		expr  string
+		count int
+	}{
+		{"soap11:Body", 1},
+		{"/soap11:Body/doGoogleSearchResponse/return/resultElements/item", 3},
+		{"//item", 3},
+		{"//item[2]", 1},
+		{"//{urn:GoogleSearch}item", 3},
+		{"//{urn:other}item", 0},
+		{"//resultElements/*", 3},
+		{"//*[@soapenc:arrayType]", 2},
+		{"//resultElements[@soapenc:arrayType='google:ResultElement[3]']", 1},
+		{"//item//b", 23},
+		{"Body", 1},
+		{"missing/item", 0},
+	}
+	for _, tt := range tests {
+		sel, err := CompileSelector(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if n := len(sel.MatchAll(root)); n != tt.count {
+			t.Errorf("%s: matched %d elements, want %d", tt.expr, n, tt.count)
+		}
+	}
+	for _, bad := range []string{"", "a[", "a[0]", "a[@x=y]", "{urn:x", "a/[1]"} {
+		if _, err := CompileSelector(bad); err == nil {
+			t.Errorf("CompileSelector(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSelectorPositionPerParent(t *testing.T) {
+	root := parseDoc(t, []byte(`<r><a><i n="1"/><i n="2"/></a><b><i n="3"/><c><i n="4"/><i n="5"/></c></b><i n="6"/><i n="7" x=""/></r>`))
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"//i[2]", "2 5 7"},
+		{"//i[1]", "1 3 4 6"},
+		{"//i[@x][1]", "7"},
+		{"b//i[2]", "5"},
+		{"i[2]", "7"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, el := range root.FindAll(tt.expr) {
+			got = append(got, el.Attr("", "n"))
+		}
+		if s := strings.Join(got, " "); s != tt.want {
+			t.Errorf("%s: matched %q, want %q", tt.expr, s, tt.want)
+		}
+	}
+}
+
+func TestFindText(t *testing.T) {
+	root := parseDoc(t, googleSOAP)
+	if s, ok := root.FindText("//item[2]/URL"); !ok || s != "http://hci.stanford.edu/winograd/shrdlu" {
+		t.Errorf("FindText = %q, %v", s, ok)
+	}
+	if _, ok := root.FindText("//nothing"); ok {
+		t.Error("FindText found missing element")
+	}
+	if s, ok := root.FindAttr("//resultElements", "soapenc:arrayType"); !ok || s != "google:ResultElement[3]" {
+		t.Errorf("FindAttr = %q, %v", s, ok)
+	}
+	if _, ok := root.FindAttr("//item", "id"); ok {
+		t.Error("FindAttr found missing attribute")
+	}
+}
@@ -0,0 +1,52 @@
+package xmltree
+
+import "fmt"
+
+// WithSkipElements causes Parse to discard the elements matching
+// selector, and everything within them, as they are read, so that
+// no memory is spent on parts of a document the caller does not
+// need. The selector is applied relative to the root element; see
+// Selector for the syntax. Position predicates, such as item[2], are
+// not supported, and cause Parse to return an error. The option may
+// be given more than once to skip several kinds of element.
+func WithSkipElements(selector string) ParseOption {
+	return func(o *parseOptions) {
+		sel, err := CompileSelector(selector)
+		if err == nil && sel.positional() {
+			err = fmt.Errorf("xmltree: WithSkipElements: position predicates are not supported in %q", selector)
+		}
+		if err != nil {
+			if o.err == nil {
+				o.err = err
+			}
+			return
+		}
+		o.skip = append(o.skip, sel)
+	}
+}
+
+// skipped reports whether child, about to be parsed below the
+// element at depth, matches a WithSkipElements selector.
+func (s *scanner) skipped(child *Element, depth int) bool {
+	if len(s.opts.skip) == 0 || s.wrapper(depth+1) {
+		return false
+	}
+	path := append(s.path[s.outer:depth+1], child)
+	for _, sel := range s.opts.skip {
+		if sel.matchPath(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// cutContent returns the content between begin and end, without the
+// ranges of skipped elements given in cuts as pairs of offsets.
+func cutContent(data []byte, begin, end int64, cuts []int64) []byte {
+	var content []byte
+	for i := 0; i < len(cuts); i += 2 {
+		content = append(content, data[begin:cuts[i]]...)
+		begin = cuts[i+1]
+	}
+	return append(content, data[begin:end]...)
+}
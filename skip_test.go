@@ -0,0 +1,32 @@
+package xmltree
+
+import "testing"
+
+func TestWithSkipElements(t *testing.T) {
+	doc := []byte(`<r xmlns:p="urn:p"><a><big>lots<x/></big><keep/></a><p:big/><b kind="drop">text<i/>more</b><b kind="keep"/></r>`)
+	el, err := Parse(doc,
+		WithSkipElements("a/big"),
+		WithSkipElements("//p:big"),
+		WithSkipElements("b[@kind='drop']/i"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<r xmlns:p="urn:p"><a><keep /></a><b kind="drop">textmore</b><b kind="keep" /></r>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	el, err = Parse(doc, WithSkipElements("//big"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(el.Search("", "big")); n != 0 {
+		t.Errorf("%d big elements were not skipped", n)
+	}
+
+	for _, bad := range []string{"[", "b[2]"} {
+		if _, err := Parse(doc, WithSkipElements(bad)); err == nil {
+			t.Errorf("WithSkipElements(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package soap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+)
+
+// XOPNamespace is the XML namespace of the xop:Include element, which
+// refers to an attachment of an MTOM message.
+const XOPNamespace = "http://www.w3.org/2004/08/xop/include"
+
+// The Content-ID of the root part of the MTOM messages we send.
+const rootContentID = "<root.message@xmltree>"
+
+// An Attachment is a binary part of a SOAP message, sent alongside
+// the envelope with MTOM.
+type Attachment struct {
+	// The Content-ID of the part, without angle brackets.
+	ContentID string
+
+	// The media type of the part, such as "image/png".
+	ContentType string
+
+	Data []byte
+}
+
+// Include returns an xop:Include element referring to the attachment
+// with the given Content-ID. It takes the place of the base64 content
+// of the element that holds the attachment's data:
+//
+//	photo := req.Body().FindOne("//photo")
+//	photo.Children = []xmltree.Element{soap.Include("photo-1")}
+//	req.Attachments = append(req.Attachments, soap.Attachment{
+//		ContentID:   "photo-1",
+//		ContentType: "image/jpeg",
+//		Data:        jpeg,
+//	})
+func Include(contentID string) xmltree.Element {
+	el := xmltree.MustParse([]byte(`<xop:Include xmlns:xop="` + XOPNamespace + `"/>`))
+	el.SetAttr("", "href", "cid:"+url.PathEscape(contentID))
+	return *el
+}
+
+// Attachment returns the data of the attachment an xop:Include href,
+// of the form "cid:id", refers to.
+func (r *Response) Attachment(href string) (io.Reader, error) {
+	if a := findAttachment(r.Attachments, href); a != nil {
+		return bytes.NewReader(a.Data), nil
+	}
+	return nil, fmt.Errorf("soap: no attachment %s", href)
+}
+
+func findAttachment(attachments []Attachment, href string) *Attachment {
+	if !strings.HasPrefix(href, "cid:") {
+		return nil
+	}
+	id, err := url.PathUnescape(href[len("cid:"):])
+	if err != nil {
+		return nil
+	}
+	for i := range attachments {
+		if attachments[i].ContentID == id {
+			return &attachments[i]
+		}
+	}
+	return nil
+}
+
+// encode returns the HTTP body and content type for req.
+func (c *Client) encode(req *Request) ([]byte, string, error) {
+	const plain = "text/xml; charset=utf-8"
+	if len(req.Attachments) == 0 {
+		data, err := xmltree.MarshalAppend(nil, req.Envelope)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, plain, nil
+	}
+	size := 0
+	for _, a := range req.Attachments {
+		size += len(a.Data)
+	}
+	if size <= c.MTOMThreshold {
+		data, err := inlineAttachments(req)
+		return data, plain, err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	root := make(textproto.MIMEHeader)
+	root.Set("Content-Type", `application/xop+xml; charset=UTF-8; type="text/xml"`)
+	root.Set("Content-Transfer-Encoding", "8bit")
+	root.Set("Content-ID", rootContentID)
+	w, err := mw.CreatePart(root)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := xmltree.Encode(w, req.Envelope); err != nil {
+		return nil, "", err
+	}
+	for _, a := range req.Attachments {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", a.ContentType)
+		h.Set("Content-Transfer-Encoding", "binary")
+		h.Set("Content-ID", "<"+a.ContentID+">")
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		w.Write(a.Data)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	contentType := mime.FormatMediaType("multipart/related", map[string]string{
+		"type":       "application/xop+xml",
+		"start":      rootContentID,
+		"start-info": "text/xml",
+		"boundary":   mw.Boundary(),
+	})
+	return buf.Bytes(), contentType, nil
+}
+
+// inlineAttachments encodes the envelope of req with each element
+// holding an xop:Include replaced by one with the base64 encoding of
+// the attachment as its content. The envelope is not modified.
+func inlineAttachments(req *Request) ([]byte, error) {
+	var missing string
+	inline := func(el *xmltree.Element) *xmltree.Element {
+		if len(el.Children) != 1 {
+			return el
+		}
+		inc := &el.Children[0]
+		if inc.Name.Space != XOPNamespace || inc.Name.Local != "Include" {
+			return el
+		}
+		href := inc.Attr("", "href")
+		a := findAttachment(req.Attachments, href)
+		if a == nil {
+			missing = href
+			return el
+		}
+		dup := *el
+		dup.Children = nil
+		dup.SetContentBase64(a.Data)
+		return &dup
+	}
+	data, err := xmltree.MarshalAppend(nil, req.Envelope, xmltree.WithFilter(inline))
+	if err != nil {
+		return nil, err
+	}
+	if missing != "" {
+		return nil, fmt.Errorf("soap: no attachment %s", missing)
+	}
+	return data, nil
+}
+
+// decode parses an HTTP response body with the given content type,
+// which may be an MTOM message.
+func decode(data []byte, contentType string) (*Response, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/related" {
+		env, err := ParseEnvelope(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Envelope: env}, nil
+	}
+
+	resp := new(Response)
+	mr := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		id := part.Header.Get("Content-ID")
+		isRoot := id == params["start"] || params["start"] == "" && resp.Envelope == nil
+		if isRoot && resp.Envelope == nil {
+			if resp.Envelope, err = ParseEnvelope(body); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		resp.Attachments = append(resp.Attachments, Attachment{
+			ContentID:   strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">"),
+			ContentType: part.Header.Get("Content-Type"),
+			Data:        body,
+		})
+	}
+	if resp.Envelope == nil {
+		return nil, fmt.Errorf("soap: MTOM response has no root part")
+	}
+	return resp, nil
+}
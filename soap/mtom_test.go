@@ -0,0 +1,134 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+func TestMTOM(t *testing.T) {
+	image := bytes.Repeat([]byte{0, 1, 2, 0xff}, 100)
+	var gotType, gotBody string
+	var gotParts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("Content-Type")
+		mediaType, params, _ := mime.ParseMediaType(gotType)
+		gotParts = nil
+		if mediaType == "multipart/related" {
+			mr := multipart.NewReader(r.Body, params["boundary"])
+			for {
+				part, err := mr.NextPart()
+				if err != nil {
+					break
+				}
+				data, _ := io.ReadAll(part)
+				gotParts = append(gotParts, part.Header.Get("Content-ID")+" "+string(data))
+			}
+		} else {
+			data, _ := io.ReadAll(r.Body)
+			gotBody = string(data)
+		}
+
+		// Reply with an attachment of our own.
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		root, _ := mw.CreatePart(map[string][]string{
+			"Content-Type": {`application/xop+xml; type="text/xml"`},
+			"Content-ID":   {"<start>"},
+		})
+		io.WriteString(root, `<soap:Envelope xmlns:soap="`+EnvelopeNamespace+`"><soap:Body>`+
+			`<Thumb><xop:Include xmlns:xop="`+XOPNamespace+`" href="cid:thumb%401"/></Thumb>`+
+			`</soap:Body></soap:Envelope>`)
+		part, _ := mw.CreatePart(map[string][]string{
+			"Content-Type": {"image/png"},
+			"Content-ID":   {"<thumb@1>"},
+		})
+		part.Write([]byte("PNG"))
+		mw.Close()
+		w.Header().Set("Content-Type", `multipart/related; type="application/xop+xml"; start="<start>"; boundary=`+mw.Boundary())
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	newRequest := func() *Request {
+		req := NewRequest(srv.URL, "upload", xmltree.MustParse([]byte(`<Upload><Photo/></Upload>`)))
+		photo := req.Body().Children[0].Child("", "Photo")
+		photo.Children = []xmltree.Element{Include("photo@1")}
+		req.Attachments = []Attachment{{ContentID: "photo@1", ContentType: "image/jpeg", Data: image}}
+		return req
+	}
+
+	client := &Client{MTOMThreshold: 1000}
+	resp, err := client.Do(context.Background(), newRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(gotType, "text/xml") {
+		t.Errorf("small attachment sent as %s", gotType)
+	}
+	if want := "<Photo>" + base64.StdEncoding.EncodeToString(image) + "</Photo>"; !strings.Contains(gotBody, want) {
+		t.Errorf("attachment not inlined: %s", gotBody)
+	}
+
+	body, err := resp.Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	href := body.Children[0].Attr("", "href")
+	r, err := resp.Attachment(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "PNG" {
+		t.Errorf("response attachment %q", data)
+	}
+
+	client.MTOMThreshold = 100
+	req := newRequest()
+	if _, err := client.Do(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(gotType, "multipart/related") {
+		t.Fatalf("large attachment sent as %s", gotType)
+	}
+	if len(gotParts) != 2 || !strings.Contains(gotParts[0], `href="cid:photo@1"`) ||
+		gotParts[1] != "<photo@1> "+string(image) {
+		t.Errorf("unexpected parts %q", gotParts)
+	}
+	if req.Body().Children[0].Child("", "Photo").Children == nil {
+		t.Error("request envelope modified")
+	}
+}
+
+// failStore is a ContentStore whose content cannot be read back.
+type failStore struct{}
+
+func (failStore) Put([]byte) (string, error)         { return "key", nil }
+func (failStore) Open(string) (io.ReadCloser, error) { return nil, io.ErrUnexpectedEOF }
+
+func TestEncodeError(t *testing.T) {
+	env, err := xmltree.Parse([]byte(`<Envelope><Body>`+strings.Repeat("x", 200)+`</Body></Envelope>`),
+		xmltree.WithContentSpill(100, failStore{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{MTOMThreshold: 2}
+	for _, attachments := range [][]Attachment{
+		nil,
+		{{ContentID: "a", ContentType: "image/png", Data: []byte("x")}},
+		{{ContentID: "a", ContentType: "image/png", Data: []byte("xyz")}},
+	} {
+		if _, _, err := c.encode(&Request{Envelope: env, Attachments: attachments}); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d attachments: encode returned %v", len(attachments), err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Package soap is a minimal SOAP 1.1 client. Requests and responses
+// are handled as xmltree Elements, and may be inspected or modified
+// on their way to and from the server by interceptors, which is
+// where concerns such as logging, WS-Security headers and retries
+// belong. Binary attachments are sent and received with MTOM.
+package soap // import "github.com/mdejong/xmltree/soap"
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+)
+
+// EnvelopeNamespace is the XML namespace of SOAP 1.1 envelopes.
+const EnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
+
+// maxResponseSize bounds the size of a response read by the
+// default transport. It is a variable so that tests may lower it.
+var maxResponseSize int64 = 64 << 20
+
+// A Request is a SOAP message on its way to a server.
+type Request struct {
+	// The URL the request is posted to.
+	Endpoint string
+
+	// The value of the SOAPAction header, without quotes.
+	Action string
+
+	// The soap:Envelope element, with its Header and Body.
+	Envelope *xmltree.Element
+
+	// Additional HTTP headers to send.
+	Header http.Header
+
+	// Binary parts of the message, referred to from the envelope
+	// by Include elements. See Client.MTOMThreshold.
+	Attachments []Attachment
+}
+
+// A Response is the reply to a Request.
+type Response struct {
+	// The soap:Envelope element of the response.
+	Envelope *xmltree.Element
+
+	// The binary parts of an MTOM response.
+	Attachments []Attachment
+}
+
+// Body returns the first child of the soap:Body of the response, as
+// ResponseBody does.
+func (r *Response) Body() (*xmltree.Element, error) {
+	return ResponseBody(r.Envelope)
+}
+
+// Body returns the soap:Body element of the request's envelope.
+func (r *Request) Body() *xmltree.Element {
+	return r.Envelope.Child(EnvelopeNamespace, "Body")
+}
+
+// AddHeader adds el to the soap:Header element of the request's
+// envelope, such as a WS-Security header. The soap:Header element is
+// created, before the soap:Body, if the envelope has none.
+func (r *Request) AddHeader(el xmltree.Element) {
+	header := r.Envelope.Child(EnvelopeNamespace, "Header")
+	if header == nil {
+		h := xmltree.Element{StartElement: xml.StartElement{
+			Name: xml.Name{Space: EnvelopeNamespace, Local: "Header"},
+		}}
+		if body := r.Body(); body != nil {
+			header = r.Envelope.InsertBefore(&h, body)
+		} else {
+			header = r.Envelope.AppendChild(&h)
+		}
+	}
+	header.AppendChild(&el)
+}
+
+// A Handler sends a request and returns the response.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// An Interceptor is called in place of the next Handler in a chain.
+// It may modify the request, call next any number of times, and
+// inspect or replace the response.
+//
+//	logging := func(ctx context.Context, req *soap.Request, next soap.Handler) (*soap.Response, error) {
+//		log.Printf("%s: %s", req.Action, req.Envelope)
+//		resp, err := next(ctx, req)
+//		if err == nil {
+//			log.Printf("response: %s", resp.Envelope)
+//		}
+//		return resp, err
+//	}
+type Interceptor func(ctx context.Context, req *Request, next Handler) (*Response, error)
+
+// A Client sends SOAP requests. The zero value is usable and posts
+// requests with http.DefaultClient.
+type Client struct {
+	// HTTPClient is used to send requests. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Interceptors are called in order for each request; the
+	// first is the outermost.
+	Interceptors []Interceptor
+
+	// MTOMThreshold is the total size of attachments, in bytes,
+	// above which a request is sent as an MTOM multipart/related
+	// message. Smaller attachments are sent inline, as base64
+	// content in place of their Include elements.
+	MTOMThreshold int
+}
+
+// DefaultClient is the Client used by Call.
+var DefaultClient = &Client{}
+
+// Call sends body to endpoint with DefaultClient.
+func Call(ctx context.Context, endpoint, action string, body *xmltree.Element) (*xmltree.Element, error) {
+	return DefaultClient.Call(ctx, endpoint, action, body)
+}
+
+// Call wraps body in a SOAP envelope, posts it to endpoint with the
+// given SOAPAction, and returns the first child of the response's
+// soap:Body. If the response is a SOAP fault, a *Fault is returned
+// as the error.
+func (c *Client) Call(ctx context.Context, endpoint, action string, body *xmltree.Element) (*xmltree.Element, error) {
+	resp, err := c.Do(ctx, NewRequest(endpoint, action, body))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body()
+}
+
+// NewRequest returns a Request that posts body, wrapped in a SOAP
+// envelope, to endpoint.
+func NewRequest(endpoint, action string, body *xmltree.Element) *Request {
+	return &Request{
+		Endpoint: endpoint,
+		Action:   action,
+		Envelope: NewEnvelope(body),
+		Header:   make(http.Header),
+	}
+}
+
+// Do sends req through the Client's interceptors and returns the
+// response. Unlike Call, Do does not check the response for a fault.
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	return c.chain(0)(ctx, req)
+}
+
+// chain returns the Handler that runs the interceptors from i on.
+func (c *Client) chain(i int) Handler {
+	if i == len(c.Interceptors) {
+		return c.send
+	}
+	next := c.chain(i + 1)
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		return c.Interceptors[i](ctx, req, next)
+	}
+}
+
+// send posts req over HTTP and parses the response.
+func (c *Client) send(ctx context.Context, req *Request) (*Response, error) {
+	data, contentType, err := c.encode(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("POST", req.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	for k, v := range req.Header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Accept", "text/xml, multipart/related")
+	httpReq.Header.Set("SOAPAction", `"`+req.Action+`"`)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxResponseSize {
+		return nil, fmt.Errorf("soap: %s: response larger than %d bytes", req.Endpoint, maxResponseSize)
+	}
+
+	// Faults are sent with a 500 status, so the body is parsed
+	// whatever the status, and the status is only reported if the
+	// body is not a SOAP message.
+	result, err := decode(data, resp.Header.Get("Content-Type"))
+	if err != nil && resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("soap: %s: %s", req.Endpoint, resp.Status)
+	}
+	return result, err
+}
+
+// NewEnvelope returns a soap:Envelope element with an empty
+// soap:Header and a soap:Body containing body, if it is not nil.
+func NewEnvelope(body *xmltree.Element) *xmltree.Element {
+	env := xmltree.MustParse([]byte(`<soap:Envelope xmlns:soap="` + EnvelopeNamespace + `">` +
+		`<soap:Header/><soap:Body/></soap:Envelope>`))
+	if body != nil {
+		env.Child(EnvelopeNamespace, "Body").AppendChild(body)
+	}
+	return env
+}
+
+// ParseEnvelope parses a SOAP message, checking that its root is a
+// soap:Envelope element.
+func ParseEnvelope(data []byte) (*xmltree.Element, error) {
+	env, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if env.Name.Space != EnvelopeNamespace || env.Name.Local != "Envelope" {
+		return nil, fmt.Errorf("soap: root element is {%s}%s, want soap:Envelope", env.Name.Space, env.Name.Local)
+	}
+	return env, nil
+}
+
+// ResponseBody returns the first child of the soap:Body of env, or
+// nil if the body is empty. If the body holds a soap:Fault, it is
+// returned as a *Fault error.
+func ResponseBody(env *xmltree.Element) (*xmltree.Element, error) {
+	body := env.Child(EnvelopeNamespace, "Body")
+	if body == nil {
+		return nil, fmt.Errorf("soap: envelope has no Body")
+	}
+	if len(body.Children) == 0 {
+		return nil, nil
+	}
+	first := &body.Children[0]
+	if first.Name.Space == EnvelopeNamespace && first.Name.Local == "Fault" {
+		return nil, newFault(first)
+	}
+	return first, nil
+}
+
+// A Fault is a SOAP fault returned by a server.
+type Fault struct {
+	Code   xml.Name // faultcode, resolved to a namespace
+	String string   // faultstring
+	Actor  string   // faultactor, if any
+
+	// The detail element, if any.
+	Detail *xmltree.Element
+}
+
+func newFault(el *xmltree.Element) *Fault {
+	f := new(Fault)
+	for i := range el.Children {
+		c := &el.Children[i]
+		text := strings.TrimSpace(string(c.Content))
+		switch c.Name.Local {
+		case "faultcode":
+			f.Code = c.Resolve(text)
+		case "faultstring":
+			f.String = text
+		case "faultactor":
+			f.Actor = text
+		case "detail":
+			f.Detail = c
+		}
+	}
+	return f
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("soap: fault %s: %s", f.Code.Local, f.String)
+}
@@ -0,0 +1,119 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+const testResponse = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+<soap:Body><m:PriceResponse xmlns:m="urn:stock"><m:Price>34.5</m:Price></m:PriceResponse></soap:Body>
+</soap:Envelope>`
+
+const testFault = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+<soap:Body><soap:Fault><faultcode>soap:Client</faultcode><faultstring>unknown symbol</faultstring>
+<detail><code>42</code></detail></soap:Fault></soap:Body>
+</soap:Envelope>`
+
+func TestCall(t *testing.T) {
+	var gotAction, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAction = r.Header.Get("SOAPAction")
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		if strings.Contains(gotBody, "BAD") {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(testFault))
+			return
+		}
+		w.Write([]byte(testResponse))
+	}))
+	defer srv.Close()
+
+	body := xmltree.MustParse([]byte(`<m:GetPrice xmlns:m="urn:stock"><m:Symbol>ACME</m:Symbol></m:GetPrice>`))
+	var calls int
+	client := &Client{Interceptors: []Interceptor{
+		func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+			calls++
+			req.AddHeader(xmltree.Element{
+				StartElement: xml.StartElement{Name: xml.Name{Local: "Token"}},
+				Content:      []byte("secret"),
+			})
+			return next(ctx, req)
+		},
+	}}
+	resp, err := client.Call(context.Background(), srv.URL, "urn:stock#GetPrice", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotAction != `"urn:stock#GetPrice"` {
+		t.Errorf("SOAPAction %s", gotAction)
+	}
+	if !strings.Contains(gotBody, "<soap:Header><Token>secret</Token></soap:Header>") ||
+		!strings.Contains(gotBody, "<m:Symbol>ACME</m:Symbol>") {
+		t.Errorf("unexpected request %s", gotBody)
+	}
+	if calls != 1 {
+		t.Errorf("interceptor called %d times", calls)
+	}
+	if resp.Name.Local != "PriceResponse" || string(resp.Child("urn:stock", "Price").Content) != "34.5" {
+		t.Errorf("unexpected response %s", resp)
+	}
+
+	bad := xmltree.MustParse([]byte(`<GetPrice>BAD</GetPrice>`))
+	_, err = client.Call(context.Background(), srv.URL, "x", bad)
+	var fault *Fault
+	if !errors.As(err, &fault) {
+		t.Fatalf("expected a *Fault, got %v", err)
+	}
+	if fault.Code != (xml.Name{Space: EnvelopeNamespace, Local: "Client"}) || fault.String != "unknown symbol" {
+		t.Errorf("unexpected fault %+v", fault)
+	}
+	if fault.Detail == nil || string(fault.Detail.Child("", "code").Content) != "42" {
+		t.Errorf("fault detail not kept")
+	}
+}
+
+func TestCallHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+	_, err := Call(context.Background(), srv.URL, "x", nil)
+	if err == nil || !strings.Contains(err.Error(), "410") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
+
+func TestAddHeaderCreatesHeader(t *testing.T) {
+	env := xmltree.MustParse([]byte(`<soap:Envelope xmlns:soap="` + EnvelopeNamespace + `">` +
+		`<soap:Body><Ping/></soap:Body></soap:Envelope>`))
+	req := &Request{Envelope: env}
+	req.AddHeader(xmltree.Element{StartElement: xml.StartElement{Name: xml.Name{Local: "Token"}}})
+	req.AddHeader(xmltree.Element{StartElement: xml.StartElement{Name: xml.Name{Local: "Trace"}}})
+	want := `<soap:Envelope xmlns:soap="` + EnvelopeNamespace + `">` +
+		`<soap:Header><Token /><Trace /></soap:Header><soap:Body><Ping /></soap:Body></soap:Envelope>`
+	if got := env.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	defer func(n int64) { maxResponseSize = n }(maxResponseSize)
+	maxResponseSize = int64(len(testResponse)) - 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testResponse))
+	}))
+	defer srv.Close()
+	_, err := Call(context.Background(), srv.URL, "x", nil)
+	if err == nil || !strings.Contains(err.Error(), "response larger than") {
+		t.Errorf("expected size error, got %v", err)
+	}
+}
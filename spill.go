@@ -0,0 +1,129 @@
+package xmltree
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// A ContentStore holds text content that is too large to keep in
+// memory. See WithContentSpill.
+type ContentStore interface {
+	// Put saves content, returning a key that identifies it.
+	Put(content []byte) (key string, err error)
+	// Open returns a reader for the content saved under key.
+	Open(key string) (io.ReadCloser, error)
+}
+
+// spilled refers to the content of an Element kept in a ContentStore.
+type spilled struct {
+	store ContentStore
+	key   string
+	size  int
+}
+
+// WithContentSpill moves the text content of elements larger than
+// threshold bytes to store, instead of keeping it in the Content field.
+// This is useful for documents that embed large blobs, such as base64
+// encoded attachments. The Content field of such elements is nil; use
+// the ContentReader and ContentSize methods to access their content.
+// Content is moved as soon as the end tag of its element is read, so
+// that the tree being built never holds more than one copy of it.
+// Elements with children are affected too, since their content
+// includes that of their children.
+//
+// Encode reads spilled content back from store as needed, and returns
+// any error from it. Marshal and String cannot report such errors;
+// use Encode or MarshalAppend for trees with spilled content.
+func WithContentSpill(threshold int, store ContentStore) ParseOption {
+	return func(o *parseOptions) {
+		o.spillThreshold = threshold
+		o.spillStore = store
+	}
+}
+
+// spill moves the content of el to the ContentStore, if it is
+// configured and the content is larger than the threshold.
+func (o *parseOptions) spill(el *Element) error {
+	if o.spillStore == nil || len(el.Content) <= o.spillThreshold {
+		return nil
+	}
+	key, err := o.spillStore.Put(el.Content)
+	if err != nil {
+		return err
+	}
+	el.spill = &spilled{store: o.spillStore, key: key, size: len(el.Content)}
+	el.Content = nil
+	return nil
+}
+
+// IsSpilled reports whether the content of el was moved to a
+// ContentStore by WithContentSpill.
+func (el *Element) IsSpilled() bool {
+	return el.spill != nil
+}
+
+// ContentSize returns the length of the text content of el, whether
+// it is held in the Content field or a ContentStore.
+func (el *Element) ContentSize() int {
+	if el.spill != nil {
+		return el.spill.size
+	}
+	return len(el.Content)
+}
+
+// ContentReader returns a reader for the text content of el, whether
+// it is held in the Content field or a ContentStore. The caller must
+// close the returned reader.
+func (el *Element) ContentReader() (io.ReadCloser, error) {
+	if el.spill != nil {
+		return el.spill.store.Open(el.spill.key)
+	}
+	return io.NopCloser(bytes.NewReader(el.Content)), nil
+}
+
+// hasContent reports whether el has any text content.
+func (el *Element) hasContent() bool {
+	return len(el.Content) > 0 || el.spill != nil
+}
+
+// A TempFileStore is a ContentStore that saves content to temporary
+// files in Dir, or the default temporary directory if Dir is empty.
+// The files are not removed automatically; call Remove when the
+// parsed document is no longer needed.
+type TempFileStore struct {
+	Dir   string
+	files []string
+}
+
+// Put writes content to a new temporary file, returning its name.
+func (s *TempFileStore) Put(content []byte) (string, error) {
+	f, err := os.CreateTemp(s.Dir, "xmltree-content-")
+	if err != nil {
+		return "", err
+	}
+	s.files = append(s.files, f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// Open opens the temporary file named by key.
+func (s *TempFileStore) Open(key string) (io.ReadCloser, error) {
+	return os.Open(key)
+}
+
+// Remove deletes all files created by the TempFileStore, returning
+// the first error encountered.
+func (s *TempFileStore) Remove() error {
+	var first error
+	for _, name := range s.files {
+		if err := os.Remove(name); err != nil && first == nil {
+			first = err
+		}
+	}
+	s.files = nil
+	return first
+}
@@ -0,0 +1,82 @@
+package xmltree
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestContentSpill(t *testing.T) {
+	blob := strings.Repeat("QUJD", 1000) + "&amp;"
+	doc := []byte(`<msg><small>hi</small><data>` + blob + `</data></msg>`)
+	store := &TempFileStore{Dir: t.TempDir()}
+	defer store.Remove()
+
+	root, err := Parse(doc, WithContentSpill(100, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, data := &root.Children[0], &root.Children[1]
+	if small.IsSpilled() || string(small.Content) != "hi" {
+		t.Errorf("small content was spilled")
+	}
+	if !data.IsSpilled() || data.Content != nil {
+		t.Fatalf("large content was not spilled")
+	}
+	if data.ContentSize() != len(blob)-4 {
+		t.Errorf("ContentSize = %d, want %d", data.ContentSize(), len(blob)-4)
+	}
+	r, err := data.ContentReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(content), "QUJD&") {
+		t.Errorf("spilled content was not decoded: ...%s", content[len(content)-10:])
+	}
+	if out := Marshal(root); !bytes.Equal(out, doc) {
+		t.Errorf("Marshal did not restore spilled content")
+	}
+	if n, err := root.EncodedSize(); err != nil || n != int64(len(doc)) {
+		t.Errorf("EncodedSize = %d, want %d", n, len(doc))
+	}
+	// The root holds the blob too, so it is spilled as well.
+	if !root.IsSpilled() || root.Content != nil {
+		t.Errorf("content of the root was not spilled")
+	}
+}
+
+// failStore is a ContentStore whose content cannot be read back.
+type failStore struct{}
+
+func (failStore) Put([]byte) (string, error)         { return "key", nil }
+func (failStore) Open(string) (io.ReadCloser, error) { return nil, errors.New("disk error") }
+
+func TestContentSpillError(t *testing.T) {
+	root, err := Parse([]byte(`<msg><data>`+strings.Repeat("x", 200)+`</data></msg>`),
+		WithContentSpill(100, failStore{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(io.Discard, root); err == nil || err.Error() != "disk error" {
+		t.Errorf("Encode returned %v, want disk error", err)
+	}
+	if _, err := MarshalAppend(nil, root); err == nil {
+		t.Error("MarshalAppend returned no error")
+	}
+	if out := Marshal(root); out != nil {
+		t.Errorf("Marshal returned %q, want nil", out)
+	}
+	if _, err := root.EncodedSize(); err == nil {
+		t.Error("EncodedSize returned no error")
+	}
+	if s := root.String(); s != "" {
+		t.Errorf("String returned %q", s)
+	}
+}
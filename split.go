@@ -0,0 +1,142 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// EncodeSplit writes root to one or more files, none of them larger
+// than maxSize bytes, for formats such as sitemaps that limit the
+// size of each file. The children of root are divided between the
+// files in order, and each file is a complete document: an XML
+// declaration, the start tag of root with its attributes and
+// namespace declarations, some of the children, and the end tag of
+// root. Files are created as needed by calling create with their
+// index, counting from 0, and are closed by EncodeSplit. It returns
+// the number of files written.
+//
+// EncodeSplit fails if a single child, with the surrounding context,
+// does not fit within maxSize; the files written so far are left in
+// place. Options are applied as for Encode; with WithEncodeProgress,
+// the reported byte counts are those of the whole tree, not of any
+// one file.
+//
+//	n, err := xmltree.EncodeSplit(urlset, 50<<20, func(i int) (io.WriteCloser, error) {
+//		return os.Create(fmt.Sprintf("sitemap%d.xml", i))
+//	})
+func EncodeSplit(root *Element, maxSize int, create func(i int) (io.WriteCloser, error), opts ...EncodeOption) (int, error) {
+	var buf bytes.Buffer
+	e := encoder{w: &buf}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	root, err := e.prepare(root)
+	if err != nil || root == nil {
+		return 0, err
+	}
+	if e.progress != nil {
+		e.counter = &progressWriter{writer: e.w}
+		e.w = e.counter
+	}
+
+	if !e.hasChildren(root) {
+		buf.WriteString(xml.Header)
+		if err := e.encode(root, nil, make(map[*Element]struct{})); err != nil {
+			return 0, err
+		}
+		if buf.Len() > maxSize {
+			return 0, fmt.Errorf("xmltree: EncodeSplit: %s is %d bytes, larger than %d", root.Name.Local, buf.Len(), maxSize)
+		}
+		if err := writeFile(create, 0, buf.Bytes()); err != nil {
+			return 0, err
+		}
+		return 1, e.finish()
+	}
+
+	buf.WriteString(xml.Header)
+	e.encodeOpenTag(root, nil, e.scope(root, nil), 0)
+	head := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	e.encodeCloseTag(root, 0)
+	tail := append([]byte(nil), buf.Bytes()...)
+
+	var (
+		w     io.WriteCloser
+		files int
+		size  int
+	)
+	closeFile := func() error {
+		if w == nil {
+			return nil
+		}
+		_, err := w.Write(tail)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		w = nil
+		return err
+	}
+	visited := map[*Element]struct{}{root: {}}
+	for i := range root.Children {
+		child := e.visible(&root.Children[i])
+		if child == nil {
+			continue
+		}
+		buf.Reset()
+		if err := e.encode(child, root, visited); err != nil {
+			return files, err
+		}
+		if len(head)+buf.Len()+len(tail) > maxSize {
+			closeFile()
+			return files, fmt.Errorf("xmltree: EncodeSplit: child %d of %s is %d bytes, too large for a file of %d",
+				i, root.Name.Local, buf.Len(), maxSize)
+		}
+		if w != nil && size+buf.Len()+len(tail) > maxSize {
+			if err := closeFile(); err != nil {
+				return files, err
+			}
+		}
+		if w == nil {
+			if w, err = create(files); err != nil {
+				return files, err
+			}
+			files++
+			if _, err := w.Write(head); err != nil {
+				w.Close()
+				return files, err
+			}
+			size = len(head)
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			w.Close()
+			return files, err
+		}
+		size += buf.Len()
+	}
+	if err := closeFile(); err != nil {
+		return files, err
+	}
+	return files, e.finish()
+}
+
+// finish reports the end of encoding to WithEncodeProgress.
+func (e *encoder) finish() error {
+	if e.progress == nil {
+		return nil
+	}
+	return e.progress.finish(e.counter.n)
+}
+
+func writeFile(create func(int) (io.WriteCloser, error), i int, data []byte) error {
+	w, err := create(i)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestEncodeSplit(t *testing.T) {
+	var doc strings.Builder
+	doc.WriteString(`<urlset xmlns="urn:sitemap" v="1">`)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&doc, "<url><loc>http://example.com/%d</loc></url>", i)
+	}
+	doc.WriteString(`</urlset>`)
+	root := parseDoc(t, []byte(doc.String()))
+
+	var files []*bytes.Buffer
+	create := func(i int) (io.WriteCloser, error) {
+		if i != len(files) {
+			t.Errorf("created file %d, want %d", i, len(files))
+		}
+		files = append(files, new(bytes.Buffer))
+		return nopCloser{files[i]}, nil
+	}
+	const max = 250
+	n, err := EncodeSplit(root, max, create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(files) || n < 2 {
+		t.Fatalf("EncodeSplit returned %d, created %d files", n, len(files))
+	}
+	var locs []string
+	for i, f := range files {
+		if f.Len() > max {
+			t.Errorf("file %d is %d bytes", i, f.Len())
+		}
+		if !bytes.HasPrefix(f.Bytes(), []byte(xml.Header)) {
+			t.Errorf("file %d has no XML declaration", i)
+		}
+		part := parseDoc(t, f.Bytes())
+		if part.Name != root.Name || part.Attr("", "v") != "1" {
+			t.Errorf("file %d has root %v %v", i, part.Name, part.StartElement.Attr)
+		}
+		for _, url := range part.Children {
+			locs = append(locs, string(url.Children[0].Content))
+		}
+	}
+	if len(locs) != 10 || locs[9] != "http://example.com/9" {
+		t.Errorf("children not preserved in order: %q", locs)
+	}
+
+	files = nil
+	if _, err := EncodeSplit(root, 100, create); err == nil {
+		t.Error("no error for a child larger than the limit")
+	}
+}
@@ -0,0 +1,236 @@
+package xmltree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseReader is like Parse, but reads the document from r. The
+// input is read into memory and parsed, but is not retained by the
+// returned tree, so it may be garbage collected once ParseReader
+// returns. Because the Content of an element includes the markup of
+// its children, a tree built from the whole document is about as
+// large as the document itself; to process documents that do not
+// fit in memory, use ParseStream.
+func ParseReader(r io.Reader, opts ...ParseOption) (*Element, error) {
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewParser(opts...).Parse(doc)
+}
+
+// A StreamParser reads the elements at a fixed depth of a document
+// one at a time, without holding the rest of the document in memory.
+// It is suited to large exports, which typically consist of a root
+// element containing many independent records. Successive calls to
+// the Next method step through the elements.
+//
+//	records := xmltree.ParseStream(r, 1)
+//	for records.Next() {
+//		rec := records.Element()
+//		// ...
+//	}
+//	if err := records.Err(); err != nil {
+//		// ...
+//	}
+type StreamParser struct {
+	d     *xml.Decoder
+	rec   recorder
+	base  int64 // input offset of rec.buf[0]
+	depth int
+	opts  []ParseOption
+	ns    [][]xml.Attr // namespace declarations of open ancestors
+	el    *Element
+	err   error
+}
+
+// ParseStream returns a StreamParser that reads the elements of the
+// document in r that are depth levels below the root; a depth of 1
+// selects the children of the root element. Each element is parsed
+// with the given options, in the scope of the namespace declarations
+// of its ancestors, and everything outside the selected elements is
+// discarded as it is read. The options apply to each element as if
+// it were the root of a document, so WithSkipElements selectors are
+// relative to it, and progress is reported for each element in turn.
+// The input must be encoded in UTF-8.
+func ParseStream(r io.Reader, depth int, opts ...ParseOption) *StreamParser {
+	s := &StreamParser{
+		rec:   recorder{r: bufio.NewReader(r)},
+		depth: depth,
+		opts:  opts,
+	}
+	s.d = newStreamDecoder(&s.rec)
+	if depth < 0 {
+		s.err = fmt.Errorf("xmltree: negative stream depth %d", depth)
+	}
+	return s
+}
+
+// newStreamDecoder returns a Decoder whose input offsets match the
+// bytes saved by rec. Since the recorded bytes are parsed again
+// without their XML declaration, only UTF-8 input is accepted.
+func newStreamDecoder(rec *recorder) *xml.Decoder {
+	d := xml.NewDecoder(rec)
+	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		if l := strings.ToLower(label); l != "utf-8" && l != "utf8" {
+			return nil, fmt.Errorf("xmltree: cannot stream document in encoding %q", label)
+		}
+		return r, nil
+	}
+	return d
+}
+
+// Next reads and parses the next element at the StreamParser's
+// depth, which is then available through the Element method. Next
+// returns false when the end of the input is reached or an error
+// occurs.
+func (s *StreamParser) Next() bool {
+	s.el = nil
+	if s.err != nil {
+		return false
+	}
+	for {
+		s.discard()
+		begin := s.d.InputOffset()
+		tok, err := s.d.RawToken()
+		if err == io.EOF {
+			if len(s.ns) > 0 {
+				s.err = io.ErrUnexpectedEOF
+			}
+			return false
+		} else if err != nil {
+			s.err = err
+			return false
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(s.ns) == s.depth {
+				s.el, s.err = s.parseFrom(begin)
+				return s.err == nil
+			}
+			s.ns = append(s.ns, namespaceDecls(tok.Attr))
+		case xml.EndElement:
+			if len(s.ns) == 0 {
+				s.err = fmt.Errorf("xmltree: unexpected </%s>", tok.Name.Local)
+				return false
+			}
+			s.ns = s.ns[:len(s.ns)-1]
+		}
+	}
+}
+
+// parseFrom reads the rest of the element whose start tag begins at
+// offset begin, and parses it.
+func (s *StreamParser) parseFrom(begin int64) (*Element, error) {
+	for open := 1; open > 0; {
+		tok, err := s.d.RawToken()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			open++
+		case xml.EndElement:
+			open--
+		}
+	}
+	fragment := s.rec.buf[begin-s.base : s.d.InputOffset()-s.base]
+	el, err := parseInScope(fragment, s.ns, s.opts)
+	if err != nil {
+		return nil, err
+	}
+	if el == nil {
+		return nil, fmt.Errorf("xmltree: malformed element at offset %d", begin)
+	}
+	return el, nil
+}
+
+// parseInScope parses a single element, given the namespace
+// declarations of its ancestors, outermost first. The element is
+// parsed inside a copy of the declarations, so that its Scope is the
+// same as if the whole document had been parsed; the options apply to
+// the element as if it were the root. parseInScope returns nil if
+// fragment does not contain exactly one element.
+func parseInScope(fragment []byte, ns [][]xml.Attr, opts []ParseOption) (*Element, error) {
+	var doc bytes.Buffer
+	doc.WriteString("<stream")
+	seen := make(map[xml.Name]bool)
+	for i := len(ns) - 1; i >= 0; i-- {
+		for _, a := range ns[i] {
+			if seen[a.Name] {
+				continue
+			}
+			seen[a.Name] = true
+			doc.WriteByte(' ')
+			if a.Name.Space != "" {
+				doc.WriteString(a.Name.Space + ":")
+			}
+			doc.WriteString(a.Name.Local + `="`)
+			xml.EscapeText(&doc, []byte(a.Value))
+			doc.WriteByte('"')
+		}
+	}
+	doc.WriteByte('>')
+	doc.Write(fragment)
+	doc.WriteString("</stream>")
+
+	root, err := NewParser(opts...).parse(doc.Bytes(), "stream")
+	if err != nil {
+		return nil, err
+	}
+	if len(root.Children) != 1 {
+		return nil, nil
+	}
+	el := &root.Children[0]
+	el.parent = nil
+	return el, nil
+}
+
+// ParseFragment parses fragment, a single element taken from a larger
+// document, in the namespace scope of its ancestors. Their start tags
+// are given outermost first, as returned by the Token or RawToken
+// methods of an xml.Decoder; only their namespace declarations are
+// used. The options apply to the element as if it were the root of a
+// document, as with ParseStream. This allows tools that stream a
+// document themselves to parse parts of it without losing the
+// namespaces declared outside them.
+func ParseFragment(fragment []byte, ancestors []xml.StartElement, opts ...ParseOption) (*Element, error) {
+	ns := make([][]xml.Attr, len(ancestors))
+	for i, tag := range ancestors {
+		ns[i] = namespaceDecls(tag.Attr)
+	}
+	el, err := parseInScope(fragment, ns, opts)
+	if err != nil {
+		return nil, err
+	}
+	if el == nil {
+		return nil, errors.New("xmltree: fragment does not contain exactly one element")
+	}
+	return el, nil
+}
+
+// discard drops recorded input that has already been processed.
+func (s *StreamParser) discard() {
+	n := s.d.InputOffset() - s.base
+	s.rec.buf = append(s.rec.buf[:0], s.rec.buf[n:]...)
+	s.base += n
+}
+
+// Element returns the element parsed by the most recent call to Next.
+func (s *StreamParser) Element() *Element {
+	return s.el
+}
+
+// Err returns the first error encountered by Next. The end of the
+// input is not considered an error.
+func (s *StreamParser) Err() error {
+	return s.err
+}
@@ -0,0 +1,171 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseReader(t *testing.T) {
+	el, err := ParseReader(strings.NewReader(`<a><b>x</b></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Marshal(el)); got != `<a><b>x</b></a>` {
+		t.Errorf("got %s", got)
+	}
+	if _, err := ParseReader(strings.NewReader(`<a><b></a>`)); err == nil {
+		t.Error("expected error for malformed document")
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+	<export xmlns="urn:export" xmlns:m="urn:meta">
+		<!-- records -->
+		<record id="1"><m:owner>ann</m:owner></record>
+		<group xmlns:m="urn:meta2">
+			<record id="2"><m:owner>bob</m:owner></record>
+		</group>
+		<record id="3"/>
+	</export>`
+
+	records := ParseStream(strings.NewReader(input), 1)
+	var got []string
+	for records.Next() {
+		el := records.Element()
+		got = append(got, el.Name.Local+":"+el.Attr("", "id"))
+		if el.Name.Space != "urn:export" {
+			t.Errorf("%s: namespace %q, want urn:export", el.Name.Local, el.Name.Space)
+		}
+	}
+	if err := records.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(got, ","); s != "record:1,group:,record:3" {
+		t.Errorf("got %s", s)
+	}
+
+	records = ParseStream(strings.NewReader(input), 2)
+	var owners []string
+	for records.Next() {
+		for _, o := range records.Element().Search("", "owner") {
+			owners = append(owners, o.Name.Space+" "+string(o.Content))
+		}
+	}
+	if err := records.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(owners, ","); s != "urn:meta2 bob" {
+		t.Errorf("got owners %q", s)
+	}
+}
+
+func TestParseStreamOptions(t *testing.T) {
+	input := `<r><rec><b/><skip/></rec><rec><skip><b/></skip></rec></r>`
+	var hooks []string
+	var progress []Progress
+	records := ParseStream(strings.NewReader(input), 1,
+		WithOnElement(func(el *Element) error {
+			hooks = append(hooks, el.Name.Local)
+			return nil
+		}),
+		WithSkipElements("skip"),
+		WithParseProgress(1<<20, func(p Progress) error {
+			progress = append(progress, p)
+			return nil
+		}))
+	var got []string
+	for records.Next() {
+		got = append(got, string(Marshal(records.Element())))
+	}
+	if err := records.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(got, ","); s != "<rec><b /></rec>,<rec />" {
+		t.Errorf("got %s", s)
+	}
+	if s := strings.Join(hooks, " "); s != "b rec rec" {
+		t.Errorf("hooks called for %s, want b rec rec", s)
+	}
+	// Offsets are relative to each record.
+	want := []Progress{{Bytes: 5, Elements: 1}, {Bytes: 22, Elements: 2}, {Bytes: 5, Elements: 1}, {Bytes: 28, Elements: 1}}
+	if len(progress) != len(want) {
+		t.Fatalf("progress %v, want %v", progress, want)
+	}
+	for i := range want {
+		if progress[i] != want[i] {
+			t.Errorf("progress %v, want %v", progress, want)
+			break
+		}
+	}
+
+	var events []string
+	err := ParseEvents(strings.NewReader(input), EventFuncs{
+		OnStartElement: func(start xml.StartElement) error {
+			if start.Name.Local == "rec" {
+				return Materialize
+			}
+			return nil
+		},
+		OnElement: func(el *Element) error {
+			events = append(events, string(Marshal(el)))
+			return nil
+		},
+	}, WithSkipElements("skip"), WithOnElement(func(el *Element) error {
+		if el.Name.Local != "rec" && el.Name.Local != "b" {
+			t.Errorf("hook called for %s", el.Name.Local)
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(events, ","); s != "<rec><b /></rec>,<rec />" {
+		t.Errorf("got events %s", s)
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	ancestors := []xml.StartElement{
+		{Name: xml.Name{Local: "doc"}, Attr: []xml.Attr{
+			{Name: xml.Name{Space: "xmlns", Local: "a"}, Value: "urn:a"},
+			{Name: xml.Name{Local: "id"}, Value: "1"},
+		}},
+		{Name: xml.Name{Local: "list"}, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: "urn:d"},
+		}},
+	}
+	el, err := ParseFragment([]byte(`<item a:n="1"><a:sub/></item>`), ancestors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if el.Name.Space != "urn:d" || el.Attr("urn:a", "n") != "1" || el.Child("urn:a", "sub") == nil {
+		t.Errorf("fragment not parsed in scope: %s", el)
+	}
+	if len(el.StartElement.Attr) != 1 {
+		t.Errorf("attributes of ancestors copied: %v", el.StartElement.Attr)
+	}
+	if _, err := ParseFragment([]byte(`<a/><b/>`), nil); err == nil {
+		t.Error("no error for two elements")
+	}
+}
+
+func TestParseStreamErrors(t *testing.T) {
+	records := ParseStream(strings.NewReader(`<a><b/><c>`), 1)
+	if !records.Next() {
+		t.Fatalf("expected first element, got error %v", records.Err())
+	}
+	if records.Next() {
+		t.Fatal("expected truncated element to fail")
+	}
+	if records.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, records.Err())
+	}
+
+	records = ParseStream(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?><a><b/></a>`), 1)
+	if records.Next() || records.Err() == nil {
+		t.Error("expected error for non-UTF-8 input")
+	}
+}
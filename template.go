@@ -0,0 +1,151 @@
+package xmltree
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// A TemplateSet holds parsed XML templates, keyed by file name. The
+// Elements in the map are shared; use Get to obtain a copy that can be
+// modified safely.
+type TemplateSet map[string]*Element
+
+// ParseFS parses the files in fsys matching any of the patterns, using
+// the syntax of fs.Glob. It is intended for loading templates embedded
+// with go:embed at program startup. Every element and attribute name in
+// each template must use a namespace prefix that is declared within the
+// template; ParseFS returns an error if one is not.
+func ParseFS(fsys fs.FS, patterns ...string) (TemplateSet, error) {
+	set := make(TemplateSet)
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("xmltree: pattern matches no files: %q", pattern)
+		}
+		for _, name := range names {
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			root, err := Parse(data)
+			if err != nil {
+				return nil, fmt.Errorf("xmltree: %s: %v", name, err)
+			}
+			if err := checkNamespaces(root); err != nil {
+				return nil, fmt.Errorf("xmltree: %s: %v", name, err)
+			}
+			set[name] = root
+		}
+	}
+	return set, nil
+}
+
+// Get returns a copy of the named template, or nil if there is no
+// such template.
+func (set TemplateSet) Get(name string) *Element {
+	if el, ok := set[name]; ok {
+		return el.Clone()
+	}
+	return nil
+}
+
+// checkNamespaces reports an element or attribute in the tree whose
+// namespace was never declared. encoding/xml leaves an undeclared
+// prefix in the Space field of the name.
+func checkNamespaces(root *Element) error {
+	for _, el := range append([]*Element{root}, root.Flatten()...) {
+		if !el.declares(el.Name.Space) {
+			return fmt.Errorf("%s: undeclared namespace prefix %q", root.PathTo(el), el.Name.Space)
+		}
+		for _, a := range el.StartElement.Attr {
+			if !el.declares(a.Name.Space) {
+				return fmt.Errorf("%s: attribute %s: undeclared namespace prefix %q",
+					root.PathTo(el), a.Name.Local, a.Name.Space)
+			}
+		}
+	}
+	return nil
+}
+
+func (scope *Scope) declares(space string) bool {
+	switch space {
+	case "", xmlLangURI, xmlNamespaceURI:
+		return true
+	}
+	for _, ns := range scope.ns {
+		if ns.Space == space {
+			return true
+		}
+	}
+	return false
+}
+
+// Instantiate returns a copy of tmpl with placeholders of the form
+// ${name} replaced by subs[name]. Placeholders are recognized in
+// attribute values, in element content and in the text between
+// children kept by WithMixedContent, and the substituted text is
+// escaped when the tree is encoded. If a placeholder has no entry
+// in subs, Instantiate returns an error and no partial result; tmpl is
+// never modified.
+func Instantiate(tmpl *Element, subs map[string]string) (*Element, error) {
+	root := tmpl.Clone()
+	for _, el := range append([]*Element{root}, root.Flatten()...) {
+		for i, a := range el.StartElement.Attr {
+			v, err := expandPlaceholders(a.Value, subs)
+			if err != nil {
+				return nil, fmt.Errorf("xmltree: %s: attribute %s: %v", root.PathTo(el), a.Name.Local, err)
+			}
+			el.StartElement.Attr[i].Value = v
+		}
+		if len(el.Children) == 0 && bytes.Contains(el.Content, []byte("${")) {
+			v, err := expandPlaceholders(string(el.Content), subs)
+			if err != nil {
+				return nil, fmt.Errorf("xmltree: %s: %v", root.PathTo(el), err)
+			}
+			el.Content = []byte(v)
+		}
+		for i, m := range el.Misc {
+			if m.Kind != MiscText || !bytes.Contains(m.Data, []byte("${")) {
+				continue
+			}
+			v, err := expandPlaceholders(string(m.Data), subs)
+			if err != nil {
+				return nil, fmt.Errorf("xmltree: %s: %v", root.PathTo(el), err)
+			}
+			el.Misc[i].Data = []byte(v)
+		}
+	}
+	return root, nil
+}
+
+func expandPlaceholders(s string, subs map[string]string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var buf strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder %q", s[start:])
+		}
+		key := s[start+2 : start+end]
+		v, ok := subs[key]
+		if !ok {
+			return "", fmt.Errorf("no substitution for placeholder ${%s}", key)
+		}
+		buf.WriteString(s[:start])
+		buf.WriteString(v)
+		s = s[start+end+1:]
+	}
+	buf.WriteString(s)
+	return buf.String(), nil
+}
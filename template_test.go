@@ -0,0 +1,83 @@
+package xmltree
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tmpl/request.xml": {Data: []byte(`<env:Envelope xmlns:env="urn:env"><env:Body><op>x</op></env:Body></env:Envelope>`)},
+		"tmpl/notify.xml":  {Data: []byte(`<notify xml:lang="en"/>`)},
+		"bad/prefix.xml":   {Data: []byte(`<a><b:c/></a>`)},
+		"bad/attr.xml":     {Data: []byte(`<a q:x="1"/>`)},
+	}
+	set, err := ParseFS(fsys, "tmpl/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("got %d templates, want 2", len(set))
+	}
+	a := set.Get("tmpl/request.xml")
+	a.Children[0].Children[0].Content = []byte("changed")
+	b := set.Get("tmpl/request.xml")
+	if s := string(b.Children[0].Children[0].Content); s != "x" {
+		t.Errorf("Get returned shared template; content %q", s)
+	}
+	if set.Get("missing.xml") != nil {
+		t.Error("Get returned a missing template")
+	}
+
+	for _, pattern := range []string{"bad/prefix.xml", "bad/attr.xml", "none/*.xml", "["} {
+		if _, err := ParseFS(fsys, pattern); err == nil {
+			t.Errorf("ParseFS(%q) succeeded", pattern)
+		} else if strings.HasPrefix(pattern, "bad") && !strings.Contains(err.Error(), "undeclared") {
+			t.Errorf("ParseFS(%q): %v", pattern, err)
+		}
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	tmpl := parseDoc(t, []byte(`<req id="${id}" v="2"><user>${first} ${last}</user><note>$5 &amp; up</note></req>`))
+	el, err := Instantiate(tmpl, map[string]string{"id": "42", "first": "Ada", "last": "<L>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<req id="42" v="2"><user>Ada &lt;L&gt;</user><note>$5 &amp; up</note></req>`
+	if got := string(Marshal(el)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if tmpl.Attr("", "id") != "${id}" {
+		t.Error("template was modified")
+	}
+
+	_, err = Instantiate(tmpl, map[string]string{"id": "42", "first": "Ada"})
+	if err == nil || !strings.Contains(err.Error(), "${last}") || !strings.Contains(err.Error(), "/req/user") {
+		t.Errorf("missing substitution gave %v", err)
+	}
+	if tmpl.Children[0].Content == nil || !strings.Contains(string(tmpl.Children[0].Content), "${first}") {
+		t.Error("template was modified by failed Instantiate")
+	}
+	if _, err := Instantiate(parseDoc(t, []byte(`<a>${x</a>`)), nil); err == nil {
+		t.Error("unterminated placeholder accepted")
+	}
+}
+
+func TestInstantiateMixed(t *testing.T) {
+	tmpl := MustParse([]byte(`<p>hello ${name} <b>x</b> ${other}</p>`), WithMixedContent())
+	el, err := Instantiate(tmpl, map[string]string{"name": "Ada", "other": "&"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := el.String(), `<p>hello Ada <b>x</b> &amp;</p>`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if _, err := Instantiate(tmpl, map[string]string{"name": "Ada"}); err == nil || !strings.Contains(err.Error(), "${other}") {
+		t.Errorf("unresolved placeholder in mixed text: %v", err)
+	}
+	if got := tmpl.String(); got != `<p>hello ${name} <b>x</b> ${other}</p>` {
+		t.Errorf("template modified: %s", got)
+	}
+}
@@ -0,0 +1,179 @@
+// Package tmx processes TMX translation memory files one translation
+// unit at a time, so that memories much larger than available RAM
+// can be filtered.
+//
+// Only the tu element being examined is held as an xmltree.Element;
+// the rest of the document streams from input to output unchanged.
+package tmx // import "github.com/mdejong/xmltree/tmx"
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+)
+
+// A Unit is a tu (translation unit) element.
+type Unit struct {
+	*xmltree.Element
+}
+
+// Segment returns the content of the seg element for the given
+// language, and whether one was found. Language tags are compared
+// without regard to case. Inline markup within the segment, such as
+// bpt and ept elements, is included, as in the Content field of the
+// seg Element.
+func (u Unit) Segment(lang string) (string, bool) {
+	for _, tuv := range u.Search("", "tuv") {
+		// Matches both xml:lang and the lang attribute of TMX 1.1.
+		if !strings.EqualFold(tuv.Attr("", "lang"), lang) {
+			continue
+		}
+		if seg := tuv.Search("", "seg"); len(seg) > 0 {
+			return string(seg[0].Content), true
+		}
+	}
+	return "", false
+}
+
+// Filter copies the TMX document from r to w, calling keep for each
+// translation unit. Units for which keep returns false are left out;
+// all other content, including the header and kept units, is copied
+// byte for byte. srcLang is the srclang attribute of the header,
+// which is read before the first unit.
+func Filter(w io.Writer, r io.Reader, keep func(u Unit, srcLang string) (bool, error)) error {
+	rec := &recorder{r: bufio.NewReader(r)}
+	d := xml.NewDecoder(rec)
+	var (
+		srcLang string
+		written int64 // input offset up to which input is handled
+		base    int64 // input offset of rec.buf[0]
+		unit    int64 = -1
+
+		// start tags of the open elements
+		open []xml.StartElement
+	)
+	flush := func(upto int64, copyOut bool) error {
+		if copyOut {
+			if _, err := w.Write(rec.buf[written-base : upto-base]); err != nil {
+				return err
+			}
+		}
+		written = upto
+		rec.buf = append(rec.buf[:0], rec.buf[upto-base:]...)
+		base = upto
+		return nil
+	}
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			open = append(open, tok.Copy())
+			switch tok.Name.Local {
+			case "header":
+				for _, a := range tok.Attr {
+					if a.Name.Local == "srclang" {
+						srcLang = a.Value
+					}
+				}
+			case "tu":
+				if unit < 0 {
+					if err := flush(start, true); err != nil {
+						return err
+					}
+					unit = start
+				}
+			}
+		case xml.EndElement:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+			if tok.Name.Local != "tu" || unit < 0 {
+				continue
+			}
+			end := d.InputOffset()
+			el, err := xmltree.ParseFragment(rec.buf[unit-base:end-base], open)
+			if err != nil {
+				return fmt.Errorf("tmx: unit at offset %d: %v", unit, err)
+			}
+			ok, err := keep(Unit{el}, srcLang)
+			if err != nil {
+				return err
+			}
+			if err := flush(end, ok); err != nil {
+				return err
+			}
+			unit = -1
+		}
+	}
+	if len(open) != 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return flush(d.InputOffset(), true)
+}
+
+// Stats reports the work done by Dedupe.
+type Stats struct {
+	Units      int // translation units read
+	Duplicates int // units removed
+}
+
+// Dedupe copies the TMX document from r to w, leaving out every
+// translation unit whose source segment is identical to that of an
+// earlier unit. The source language is taken from the srclang
+// attribute of the header; if it is "*all*" or missing, the first
+// segment of each unit is used. Only a hash of each distinct source
+// segment is kept in memory.
+func Dedupe(w io.Writer, r io.Reader) (Stats, error) {
+	var stats Stats
+	seen := make(map[[sha256.Size]byte]bool)
+	err := Filter(w, r, func(u Unit, srcLang string) (bool, error) {
+		stats.Units++
+		source, ok := u.Segment(srcLang)
+		if !ok {
+			if segs := u.Search("", "seg"); len(segs) > 0 {
+				source = string(segs[0].Content)
+			}
+		}
+		sum := sha256.Sum256([]byte(strings.TrimSpace(source)))
+		if seen[sum] {
+			stats.Duplicates++
+			return false, nil
+		}
+		seen[sum] = true
+		return true, nil
+	})
+	return stats, err
+}
+
+// A recorder saves every byte read from it. Because it implements
+// io.ByteReader, an xml.Decoder will not read ahead of the tokens it
+// returns.
+type recorder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, c)
+	}
+	return c, err
+}
@@ -0,0 +1,74 @@
+package tmx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const memory = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header srclang="en" datatype="plaintext" segtype="sentence" adminlang="en" o-tmf="x" creationtool="t" creationtoolversion="1"/>
+  <body>
+    <tu tuid="1"><tuv xml:lang="en"><seg>Hello</seg></tuv><tuv xml:lang="fr"><seg>Bonjour</seg></tuv></tu>
+    <tu tuid="2"><tuv xml:lang="fr"><seg>Salut</seg></tuv><tuv xml:lang="EN"><seg>Hello</seg></tuv></tu>
+    <tu tuid="3"><tuv xml:lang="en"><seg>Bye <bpt i="1">&lt;b&gt;</bpt>now<ept i="1">&lt;/b&gt;</ept></seg></tuv></tu>
+    <tu tuid="4"><tuv xml:lang="en"><seg>Goodbye</seg></tuv></tu>
+    <tu tuid="5"><tuv xml:lang="en"><seg> Goodbye </seg></tuv></tu>
+  </body>
+</tmx>
+`
+
+func TestDedupe(t *testing.T) {
+	var out bytes.Buffer
+	stats, err := Dedupe(&out, strings.NewReader(memory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Units: 5, Duplicates: 2}) {
+		t.Errorf("stats %+v", stats)
+	}
+	want := strings.Replace(memory, `<tu tuid="2"><tuv xml:lang="fr"><seg>Salut</seg></tuv><tuv xml:lang="EN"><seg>Hello</seg></tuv></tu>`, "", 1)
+	want = strings.Replace(want, `<tu tuid="5"><tuv xml:lang="en"><seg> Goodbye </seg></tuv></tu>`, "", 1)
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var out bytes.Buffer
+	var segments []string
+	err := Filter(&out, strings.NewReader(memory), func(u Unit, srcLang string) (bool, error) {
+		seg, _ := u.Segment(srcLang)
+		segments = append(segments, seg)
+		return u.Attr("", "tuid") == "3", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segments[2] != `Bye <bpt i="1"><b></bpt>now<ept i="1"></b></ept>` {
+		t.Errorf("inline markup not preserved: %q", segments[2])
+	}
+	if strings.Count(out.String(), "<tu ") != 1 || !strings.Contains(out.String(), "<header") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	if err := Filter(&out, strings.NewReader(`<tmx><body><tu>`), func(Unit, string) (bool, error) { return true, nil }); err == nil {
+		t.Error("truncated input accepted")
+	}
+}
+
+func TestFilterNamespaces(t *testing.T) {
+	const doc = `<tmx version="1.4" xmlns:x="urn:example:ext"><header srclang="en"/><body>` +
+		`<tu x:id="a"><tuv xml:lang="en"><seg>One</seg></tuv><x:note>keep</x:note></tu>` +
+		`<tu x:id="b"><tuv xml:lang="en"><seg>Two</seg></tuv></tu></body></tmx>`
+	var out bytes.Buffer
+	err := Filter(&out, strings.NewReader(doc), func(u Unit, srcLang string) (bool, error) {
+		return u.Child("urn:example:ext", "note") != nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, `x:id="a"`) || strings.Contains(got, `x:id="b"`) {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
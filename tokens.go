@@ -0,0 +1,145 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TokenReader returns an xml.TokenReader that produces the tokens of
+// the tree rooted at el, without encoding it as text. Names carry
+// their namespace URIs. As with Marshal, the content of an element
+// with children is not included; only leaf elements and the text
+// items in Misc produce character data. Comments and processing
+// instructions in Misc are produced in their place.
+func (el *Element) TokenReader() xml.TokenReader {
+	return &tokenReader{root: el}
+}
+
+// A tokenReader walks a tree of Elements, depth-first.
+type tokenReader struct {
+	root  *Element
+	stack []tokenFrame
+	done  bool
+}
+
+type tokenFrame struct {
+	el       *Element
+	next     int  // index of the next child to visit
+	misc     int  // index of the next Misc item to visit
+	textDone bool // character data has been returned
+}
+
+func (r *tokenReader) Token() (xml.Token, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if r.stack == nil {
+		return r.push(r.root), nil
+	}
+	top := &r.stack[len(r.stack)-1]
+	if tok := top.nextMisc(); tok != nil {
+		return tok, nil
+	}
+	if len(top.el.Children) == 0 && !top.textDone {
+		top.textDone = true
+		content, err := top.el.contentBytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > 0 {
+			return xml.CharData(content), nil
+		}
+	}
+	if top.next < len(top.el.Children) && len(r.stack) <= recursionLimit {
+		child := &top.el.Children[top.next]
+		top.next++
+		return r.push(child), nil
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+	r.done = len(r.stack) == 0
+	return xml.EndElement{Name: top.el.Name}, nil
+}
+
+// nextMisc returns the token for the next item of f.el.Misc, if it
+// comes before the next child.
+func (f *tokenFrame) nextMisc() xml.Token {
+	for ; f.misc < len(f.el.Misc); f.misc++ {
+		m := &f.el.Misc[f.misc]
+		if m.Index > f.next && f.next < len(f.el.Children) {
+			return nil
+		}
+		if m.Index >= 0 {
+			f.misc++
+			return m.token()
+		}
+	}
+	return nil
+}
+
+func (r *tokenReader) push(el *Element) xml.Token {
+	r.stack = append(r.stack, tokenFrame{el: el})
+	return el.StartElement.Copy()
+}
+
+// Unmarshal stores the contents of the Element in the value pointed
+// to by v, following the same rules as xml.Unmarshal. The tree is
+// fed to the xml.Decoder as tokens, so it is neither encoded nor
+// parsed again, unless v has a field tagged ",innerxml": the decoder
+// can only fill such fields from the text of a document, so the
+// Element is encoded and parsed as it was before TokenReader existed.
+func (el *Element) Unmarshal(v interface{}) error {
+	if wantsInnerXML(reflect.TypeOf(v)) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, el); err != nil {
+			return err
+		}
+		return xml.Unmarshal(buf.Bytes(), v)
+	}
+	return xml.NewTokenDecoder(el.TokenReader()).Decode(v)
+}
+
+// Results of wantsInnerXML, by type.
+var innerXMLTypes sync.Map
+
+// wantsInnerXML reports whether a value of type t holds a struct with
+// a field tagged ",innerxml", at any depth.
+func wantsInnerXML(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	if v, ok := innerXMLTypes.Load(t); ok {
+		return v.(bool)
+	}
+	found := findInnerXML(t, make(map[reflect.Type]bool))
+	innerXMLTypes.Store(t, found)
+	return found
+}
+
+func findInnerXML(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("xml")
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			for _, flag := range strings.Split(tag[j+1:], ",") {
+				if flag == "innerxml" {
+					return true
+				}
+			}
+		}
+		if tag != "-" && findInnerXML(f.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestTokenReader(t *testing.T) {
+	root := parseDoc(t, []byte(`<a xmlns="urn:a" x="1"><b>t &amp; u</b><c/></a>`))
+	r := root.TokenReader()
+	var got []xml.Token
+	for {
+		tok, err := r.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok)
+	}
+	name := func(local string) xml.Name { return xml.Name{Space: "urn:a", Local: local} }
+	want := []xml.Token{
+		xml.StartElement{Name: name("a"), Attr: []xml.Attr{{Name: xml.Name{Local: "x"}, Value: "1"}}},
+		xml.StartElement{Name: name("b"), Attr: []xml.Attr{}},
+		xml.CharData("t & u"),
+		xml.EndElement{Name: name("b")},
+		xml.StartElement{Name: name("c"), Attr: []xml.Attr{}},
+		xml.EndElement{Name: name("c")},
+		xml.EndElement{Name: name("a")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if s, ok := g.(xml.StartElement); ok && len(s.Attr) == 0 {
+			s.Attr = []xml.Attr{}
+			g = s
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("token %d: got %#v, want %#v", i, g, w)
+		}
+	}
+}
+
+func TestElementUnmarshal(t *testing.T) {
+	root := parseDoc(t, []byte(`<r xmlns:p="urn:p"><p:v n="2">a &lt;b&gt; &amp; c</p:v><w>x</w><w>y</w></r>`))
+	var v struct {
+		V struct {
+			N    int    `xml:"n,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"urn:p v"`
+		W []string `xml:"w"`
+	}
+	if err := root.Unmarshal(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.V.N != 2 || v.V.Text != "a <b> & c" || len(v.W) != 2 || v.W[1] != "y" {
+		t.Errorf("unexpected result %+v", v)
+	}
+}
+
+func TestElementUnmarshalInnerXML(t *testing.T) {
+	root := parseDoc(t, []byte(`<a><b>hi</b><c x="1"/></a>`))
+	var v struct {
+		Inner string `xml:",innerxml"`
+	}
+	if err := Unmarshal(root, &v); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<b>hi</b><c x="1" />`; v.Inner != want {
+		t.Errorf("innerxml = %q, want %q", v.Inner, want)
+	}
+	var nested struct {
+		B []struct {
+			Raw string `xml:",innerxml"`
+		} `xml:"b"`
+	}
+	if err := root.Unmarshal(&nested); err != nil {
+		t.Fatal(err)
+	}
+	if len(nested.B) != 1 || nested.B[0].Raw != "hi" {
+		t.Errorf("nested innerxml = %+v", nested)
+	}
+}
@@ -0,0 +1,245 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortChildrenBy sorts the children of every element matching
+// parentSelector, relative to el, by the string returned from key.
+// The sort is stable, so children with equal keys keep their
+// original order. See Selector for the selector syntax. If
+// parentSelector is the empty string, the children of el are sorted.
+// Comments, processing instructions and text runs in the Misc field
+// of a parent move with the child that follows them.
+//
+// The children of MathML elements, whose order is significant, are
+// not sorted. WithInlineOrder sets the namespaces treated this way.
+//
+// Sorting moves Elements within their parent's Children slice, so
+// pointers to descendants of the sorted elements must not be
+// retained across a call to SortChildrenBy.
+func (el *Element) SortChildrenBy(parentSelector string, key func(*Element) string, opts ...TransformOption) error {
+	parents, err := el.transformTargets(parentSelector, transformConfig(opts))
+	if err != nil {
+		return err
+	}
+	defer ReleaseElements(parents)
+	for _, parent := range *parents {
+		keys := make([]string, len(parent.Children))
+		orig := make([]int, len(parent.Children))
+		for i := range parent.Children {
+			keys[i] = key(&parent.Children[i])
+			orig[i] = i
+		}
+		sort.Stable(byKey{parent.Children, keys, orig})
+		if len(parent.Misc) > 0 {
+			// Misc items move with the child they precede.
+			pos := make([]int, len(orig))
+			for j, i := range orig {
+				pos[i] = j
+			}
+			parent.moveMisc(func(i int) int { return pos[i] }, len(parent.Children))
+		}
+		parent.invalidateChildIndex()
+		parent.relinkChildren()
+	}
+	return nil
+}
+
+type byKey struct {
+	children []Element
+	keys     []string
+	orig     []int // original positions of the children
+}
+
+func (b byKey) Len() int           { return len(b.children) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.children[i], b.children[j] = b.children[j], b.children[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+	b.orig[i], b.orig[j] = b.orig[j], b.orig[i]
+}
+
+// GroupChildrenBy partitions the children of every element matching
+// parentSelector by the string returned from key. The children in
+// each group are moved under a new element, created by calling
+// newParent with the group's key, which takes the place of the
+// group's first member. Children for which key returns the empty
+// string are left in place. Groups keep the document order of their
+// members. Misc items move with the child that follows them, into its
+// group if it has one. As with SortChildrenBy, the children of MathML
+// elements, or of the namespaces given to WithInlineOrder, are not
+// grouped.
+func (el *Element) GroupChildrenBy(parentSelector string, key func(*Element) string, newParent func(key string) xml.StartElement, opts ...TransformOption) error {
+	parents, err := el.transformTargets(parentSelector, transformConfig(opts))
+	if err != nil {
+		return err
+	}
+	defer ReleaseElements(parents)
+	for _, parent := range *parents {
+		var result []Element
+		groups := make(map[string]int)
+		// where each child went: its position in result, and
+		// its position within its group, or -1
+		pos := make([]int, len(parent.Children))
+		member := make([]int, len(parent.Children))
+		for c, child := range parent.Children {
+			k := key(&child)
+			if k == "" {
+				pos[c], member[c] = len(result), -1
+				result = append(result, child)
+				continue
+			}
+			i, ok := groups[k]
+			if !ok {
+				i = len(result)
+				groups[k] = i
+				result = append(result, Element{
+					StartElement: newParent(k),
+					Scope:        parent.Scope,
+				})
+			}
+			pos[c], member[c] = i, len(result[i].Children)
+			result[i].Children = append(result[i].Children, child)
+		}
+		// Misc items move with the child they precede, into its
+		// group if it has one.
+		var misc []Misc
+		for _, m := range parent.Misc {
+			if m.Index < 0 || m.Index >= len(pos) {
+				if m.Index >= 0 {
+					m.Index = len(result)
+				}
+				misc = append(misc, m)
+			} else if g := pos[m.Index]; member[m.Index] < 0 {
+				m.Index = g
+				misc = append(misc, m)
+			} else {
+				m.Index = member[m.Index]
+				result[g].Misc = append(result[g].Misc, m)
+			}
+		}
+		if parent.Misc != nil {
+			parent.Misc = misc
+			sortMisc(parent.Misc)
+		}
+		parent.Children = result
+		parent.relinkChildren()
+		for _, i := range groups {
+			result[i].relinkChildren()
+		}
+	}
+	return nil
+}
+
+// transformTargets returns the elements matching selector, other
+// than those in the inline namespaces of o, ordered so that descendants come
+// before their ancestors. Modifying the children of an element then
+// never moves an element that has yet to be visited. The caller must
+// pass the list to ReleaseElements when it is done with it.
+func (el *Element) transformTargets(selector string, o transformOptions) (*[]*Element, error) {
+	list, err := el.attrTargets(selector)
+	if err != nil {
+		return nil, err
+	}
+	targets := (*list)[:0]
+	for _, t := range *list {
+		if !o.inline[t.Name.Space] {
+			targets = append(targets, t)
+		}
+	}
+	for i, j := 0, len(targets)-1; i < j; i, j = i+1, j-1 {
+		targets[i], targets[j] = targets[j], targets[i]
+	}
+	*list = targets
+	return list, nil
+}
+
+// SetAttrAll sets the attribute name to value on every element
+// matching selector, replacing any existing value as SetAttr does,
+// and returns the number of elements changed. An empty selector
+// refers to el itself. The name may have a namespace prefix, which
+// is resolved once, in the scope of el; it is an error if the prefix
+// is not declared there, or if name is not a valid attribute name.
+//
+//	// stamp every record with the schema version
+//	n, err := root.SetAttrAll("//record", "version", "2")
+func (el *Element) SetAttrAll(selector, name, value string) (int, error) {
+	attr, err := el.resolveAttrName(name)
+	if err != nil {
+		return 0, err
+	}
+	targets, err := el.attrTargets(selector)
+	if err != nil {
+		return 0, err
+	}
+	defer ReleaseElements(targets)
+	for _, t := range *targets {
+		t.SetAttr(attr.Space, attr.Local, value)
+	}
+	return len(*targets), nil
+}
+
+// RemoveAttrAll removes the attribute name from every element
+// matching selector, and returns the number of elements that had it.
+// The selector and name are interpreted as in SetAttrAll; a name
+// without a prefix removes attributes with that local name in any
+// namespace.
+func (el *Element) RemoveAttrAll(selector, name string) (int, error) {
+	attr, err := el.resolveAttrName(name)
+	if err != nil {
+		return 0, err
+	}
+	targets, err := el.attrTargets(selector)
+	if err != nil {
+		return 0, err
+	}
+	defer ReleaseElements(targets)
+	n := 0
+	for _, t := range *targets {
+		if t.RemoveAttr(attr.Space, attr.Local) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// attrTargets returns the elements matching selector, or el itself if
+// selector is empty, in a list from AcquireElements.
+func (el *Element) attrTargets(selector string) (*[]*Element, error) {
+	if selector == "" {
+		list := AcquireElements()
+		*list = append(*list, el)
+		return list, nil
+	}
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := AcquireElements()
+	*list = sel.MatchAppend(*list, el)
+	return list, nil
+}
+
+// resolveAttrName resolves a possibly prefixed attribute name in the
+// scope of el.
+func (el *Element) resolveAttrName(qname string) (xml.Name, error) {
+	prefix, local := "", qname
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		prefix, local = qname[:i], qname[i+1:]
+	}
+	if !IsValidName(local) || prefix != "" && !IsValidName(prefix) || prefix == "xmlns" || qname == "xmlns" {
+		return xml.Name{}, fmt.Errorf("xmltree: invalid attribute name %q", qname)
+	}
+	if prefix == "" {
+		return xml.Name{Local: local}, nil
+	}
+	name, ok := el.ResolveNS(qname)
+	if !ok {
+		return xml.Name{}, fmt.Errorf("xmltree: undeclared namespace prefix in attribute name %q", qname)
+	}
+	return name, nil
+}
@@ -0,0 +1,129 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestSortChildrenBy(t *testing.T) {
+	root := parseDoc(t, []byte(`<r><list><i n="c"><j n="2"/><j n="1"/></i><i n="a"/><i n="b"/><i n="a" x="2"/></list></r>`))
+	byName := func(el *Element) string { return el.Attr("", "n") }
+	if err := root.SortChildrenBy("//*", byName); err != nil {
+		t.Fatal(err)
+	}
+	want := `<r><list><i n="a" /><i n="a" x="2" /><i n="b" /><i n="c"><j n="1" /><j n="2" /></i></list></r>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if err := root.SortChildrenBy("[", byName); err == nil {
+		t.Error("invalid selector accepted")
+	}
+}
+
+func TestGroupChildrenBy(t *testing.T) {
+	root := parseDoc(t, []byte(`<r><p k="x"/><q/><p k="y"/><p k="x" n="2"/></r>`))
+	err := root.GroupChildrenBy("", func(el *Element) string {
+		return el.Attr("", "k")
+	}, func(key string) xml.StartElement {
+		return xml.StartElement{
+			Name: xml.Name{Local: "group"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<r><group key="x"><p k="x" /><p k="x" n="2" /></group><q /><group key="y"><p k="y" /></group></r>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestSortGroupChildrenMisc(t *testing.T) {
+	input := `<!-- prolog --><r><!-- about b --><b/><!-- about a --><a/>` +
+		`<m>one <u>b</u> two <u>a</u> three</m><!-- end --></r>`
+	root, err := Parse([]byte(input), WithComments(), WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := func(el *Element) string { return el.Name.Local }
+	if err := root.SortChildrenBy("", byName); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SortChildrenBy("//m", func(el *Element) string { return string(el.Content) }); err != nil {
+		t.Fatal(err)
+	}
+	// Text runs, like comments, move with the element they precede.
+	want := "<!-- prolog -->\n<r><!-- about a --><a /><!-- about b --><b />" +
+		"<m> two <u>a</u>one <u>b</u> three</m><!-- end --></r>"
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("sorted:\ngot  %s\nwant %s", got, want)
+	}
+
+	root, err = Parse([]byte(`<r><!-- x1 --><p k="x"/>text<q/><!-- x2 --><p k="x"/>tail</r>`),
+		WithComments(), WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = root.GroupChildrenBy("", func(el *Element) string {
+		return el.Attr("", "k")
+	}, func(key string) xml.StartElement {
+		return xml.StartElement{Name: xml.Name{Local: "g"}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `<r><g><!-- x1 --><p k="x" /><!-- x2 --><p k="x" /></g>text<q />tail</r>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("grouped:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestSetAttrAll(t *testing.T) {
+	root := parseDoc(t, []byte(`<r xmlns:v="urn:v"><rec/><rec version="1"/><other/><g><rec/></g></r>`))
+	n, err := root.SetAttrAll("//rec", "version", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("changed %d elements, want 3", n)
+	}
+	if _, err := root.SetAttrAll("//rec", "v:stamp", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if got := root.FindAll("//rec")[2].Attr("urn:v", "stamp"); got != "x" {
+		t.Errorf("prefixed attribute not set: %q", got)
+	}
+	want := `<r xmlns:v="urn:v"><rec version="2" v:stamp="x" /><rec version="2" v:stamp="x" /><other /><g><rec version="2" v:stamp="x" /></g></r>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	for _, name := range []string{"", "xmlns", "xmlns:a", "q:x", "a b", "1a"} {
+		if _, err := root.SetAttrAll("rec", name, "x"); err == nil {
+			t.Errorf("SetAttrAll accepted attribute name %q", name)
+		}
+	}
+}
+
+func TestRemoveAttrAll(t *testing.T) {
+	doc := []byte(`<r id="r"><a id="1" k="x"/><a k="y"/><b id="2"/></r>`)
+	root := parseDoc(t, doc)
+	shared := *root
+	n, err := root.RemoveAttrAll("//*", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("removed from %d elements, want 2", n)
+	}
+	want := `<r id="r"><a k="x" /><a k="y" /><b /></r>`
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if n, _ := root.RemoveAttrAll("", "id"); n != 1 || len(root.StartElement.Attr) != 0 {
+		t.Errorf("removing from el itself: n=%d, attrs %v", n, root.StartElement.Attr)
+	}
+	if shared.Attr("", "id") != "r" {
+		t.Error("removal modified attributes shared with a copy")
+	}
+}
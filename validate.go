@@ -0,0 +1,202 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Problems reported by ValidateTree, wrapped in a *TreeError.
+var (
+	ErrInvalidName      = errors.New("invalid name")
+	ErrInvalidChar      = errors.New("invalid character")
+	ErrDuplicateAttr    = errors.New("duplicate attribute")
+	ErrUnboundNamespace = errors.New("namespace not in scope")
+	ErrInvalidMarkup    = errors.New("invalid markup")
+)
+
+// A TreeError describes a problem in a tree that would cause it to be
+// encoded as something other than well-formed, namespace-well-formed
+// XML.
+type TreeError struct {
+	Path   string   // the location of the element, in the form used by PathTo
+	Attr   xml.Name // the attribute at fault, if any
+	Err    error    // one of the errors above
+	Detail string   // the offending value, or other specifics
+}
+
+func (e *TreeError) Error() string {
+	where := e.Path
+	if e.Attr.Local != "" {
+		where += "/@" + formatName(e.Attr)
+	}
+	return fmt.Sprintf("xmltree: %s: %v: %s", where, e.Err, e.Detail)
+}
+
+func (e *TreeError) Unwrap() error { return e.Err }
+
+// ValidateTree checks el and its descendants for problems that the
+// encoder cannot correct, and returns an error for each one, in
+// document order, or nil if there are none. It is meant for trees
+// that are built or modified by a program, so that mistakes are
+// found before invalid XML is written. It reports:
+//
+//   - element and attribute local names, and namespace prefixes,
+//     that are not valid XML names, or that contain a colon
+//   - characters not permitted in XML, or invalid UTF-8, in
+//     attribute values and in the content of elements without
+//     children
+//   - more than one attribute with the same name on an element
+//   - elements and attributes in a namespace that is not bound to a
+//     prefix in the element's Scope, and elements in no namespace
+//     within the scope of a default namespace
+//   - Misc items of an unknown kind, comments that contain "--" or
+//     end with "-", processing instructions whose data contains "?>"
+//     or whose target is not a valid name or is "xml", and invalid
+//     characters in any of them
+//
+// Each error is a *TreeError.
+func ValidateTree(el *Element) []error {
+	var v validator
+	v.element(el, 0)
+	return v.errs
+}
+
+type validator struct {
+	stack []*Element // el and its ancestors
+	errs  []error
+}
+
+func (v *validator) fail(el *Element, attr xml.Name, err error, format string, args ...interface{}) {
+	v.errs = append(v.errs, &TreeError{
+		Path:   v.path(),
+		Attr:   attr,
+		Err:    err,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// path is like PathTo, but names elements whose namespace has no
+// prefix as {uri}local.
+func (v *validator) path() string {
+	var b strings.Builder
+	for i, el := range v.stack {
+		b.WriteByte('/')
+		if el.Name.Space == "" || el.bound(el.Name.Space, true) {
+			b.WriteString(el.Prefix(el.Name))
+		} else {
+			b.WriteString(formatName(el.Name))
+		}
+		if i == 0 {
+			continue
+		}
+		pos, count := 0, 0
+		for j := range v.stack[i-1].Children {
+			sibling := &v.stack[i-1].Children[j]
+			if sibling.Name == el.Name {
+				count++
+				if sibling == el {
+					pos = count
+				}
+			}
+		}
+		if count > 1 {
+			fmt.Fprintf(&b, "[%d]", pos)
+		}
+	}
+	return b.String()
+}
+
+func (v *validator) element(el *Element, depth int) {
+	if depth > recursionLimit {
+		return
+	}
+	v.stack = append(v.stack[:depth], el)
+	none := xml.Name{}
+	for _, ns := range el.ns {
+		if ns.Local != "" && !IsValidName(ns.Local) {
+			v.fail(el, none, ErrInvalidName, "namespace prefix %q", ns.Local)
+		}
+	}
+	if !IsValidName(el.Name.Local) {
+		v.fail(el, none, ErrInvalidName, "element name %q", el.Name.Local)
+	}
+	if def, ok := el.binding(""); el.Name.Space == "" && ok && def != "" {
+		v.fail(el, none, ErrUnboundNamespace, "element in no namespace within default namespace %q", def)
+	} else if el.Name.Space != "" && el.Name.Space != xmlLangURI && !el.bound(el.Name.Space, true) {
+		v.fail(el, none, ErrUnboundNamespace, "element namespace %q", el.Name.Space)
+	}
+
+	for i, a := range el.StartElement.Attr {
+		if !IsValidName(a.Name.Local) {
+			v.fail(el, a.Name, ErrInvalidName, "attribute name %q", a.Name.Local)
+		}
+		if a.Name.Space != "" && a.Name.Space != xmlLangURI && !el.bound(a.Name.Space, false) {
+			v.fail(el, a.Name, ErrUnboundNamespace, "attribute namespace %q", a.Name.Space)
+		}
+		for _, b := range el.StartElement.Attr[:i] {
+			if b.Name == a.Name {
+				v.fail(el, a.Name, ErrDuplicateAttr, "%q and %q", b.Value, a.Value)
+				break
+			}
+		}
+		if r, ok := invalidChar([]byte(a.Value)); !ok {
+			v.fail(el, a.Name, ErrInvalidChar, "%U in attribute value", r)
+		}
+	}
+	if len(el.Children) == 0 {
+		if r, ok := invalidChar(el.Content); !ok {
+			v.fail(el, none, ErrInvalidChar, "%U in content", r)
+		}
+	}
+	for i := range el.Misc {
+		if detail, err := el.Misc[i].check(); err != nil {
+			v.fail(el, none, err, "%s", detail)
+		}
+	}
+	for i := range el.Children {
+		v.element(&el.Children[i], depth+1)
+	}
+}
+
+// bound reports whether the namespace uri is bound to a prefix in
+// the scope, taking the default namespace into account if def is
+// true. A binding hidden by a later declaration of the same prefix
+// does not count.
+func (scope *Scope) bound(uri string, def bool) bool {
+	for i := len(scope.ns) - 1; i >= 0; i-- {
+		ns := scope.ns[i]
+		if ns.Space != uri || ns.Local == "" && !def {
+			continue
+		}
+		if space, _ := scope.binding(ns.Local); space == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidChar returns the first character in b that may not appear in
+// an XML document, and false, or true if there are none. Invalid
+// UTF-8 is reported as utf8.RuneError.
+func invalidChar(b []byte) (rune, bool) {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+			return r, false
+		}
+		b = b[size:]
+	}
+	return 0, true
+}
+
+// isXMLChar reports whether r is in the Char production of the XML
+// 1.0 specification.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
@@ -0,0 +1,56 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func TestValidateTree(t *testing.T) {
+	root := parseDoc(t, []byte(`<r xmlns="urn:d" xmlns:p="urn:p" p:a="1"><c>ok</c><p:c/></r>`))
+	if errs := ValidateTree(root); errs != nil {
+		t.Fatalf("parsed tree reported invalid: %v", errs)
+	}
+
+	c := &root.Children[0]
+	c.Name.Local = "1c"
+	c.Content = []byte("bad \x01 char")
+	c.StartElement.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "x"}, Value: "1"},
+		{Name: xml.Name{Local: "x"}, Value: "2"},
+		{Name: xml.Name{Space: "urn:d", Local: "y"}, Value: "default namespaces do not apply to attributes"},
+		{Name: xml.Name{Space: "urn:p", Local: "z"}, Value: "\xff"},
+	}
+	root.Children[1].Name.Space = ""
+	root.Children = append(root.Children, Element{
+		StartElement: xml.StartElement{Name: xml.Name{Space: "urn:nowhere", Local: "e"}},
+		Scope:        root.Scope,
+	})
+
+	want := []struct {
+		path string
+		attr string
+		err  error
+	}{
+		{"/r/1c", "", ErrInvalidName},
+		{"/r/1c", "x", ErrDuplicateAttr},
+		{"/r/1c", "y", ErrUnboundNamespace},
+		{"/r/1c", "z", ErrInvalidChar},
+		{"/r/1c", "", ErrInvalidChar},
+		{"/r/c", "", ErrUnboundNamespace},
+		{"/r/{urn:nowhere}e", "", ErrUnboundNamespace},
+	}
+	errs := ValidateTree(root)
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, err := range errs {
+		var te *TreeError
+		if !errors.As(err, &te) {
+			t.Fatalf("%v is not a *TreeError", err)
+		}
+		if te.Path != want[i].path || te.Attr.Local != want[i].attr || !errors.Is(err, want[i].err) {
+			t.Errorf("error %d: got %v, want %+v", i, err, want[i])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Typed accessors for common lexical types. Values are trimmed of
+// surrounding white space before they are parsed. Invalid values
+// are reported with a *ValueError.
+
+var (
+	errInvalidIP   = errors.New("not an IPv4 or IPv6 address")
+	errInvalidUUID = errors.New("not in 8-4-4-4-12 hexadecimal format")
+)
+
+// ContentURL parses the content of el as an xs:anyURI.
+func (el *Element) ContentURL() (*url.URL, error) {
+	content, err := el.contentBytes()
+	if err != nil {
+		return nil, err
+	}
+	return el.parseURL(string(content), xml.Name{})
+}
+
+// AttrURL parses the value of an attribute, found as by Attr, as an
+// xs:anyURI.
+func (el *Element) AttrURL(space, local string) (*url.URL, error) {
+	return el.parseURL(el.Attr(space, local), xml.Name{Space: space, Local: local})
+}
+
+// ContentIP parses the content of el as an IPv4 or IPv6 address.
+func (el *Element) ContentIP() (net.IP, error) {
+	content, err := el.contentBytes()
+	if err != nil {
+		return nil, err
+	}
+	return el.parseIP(string(content), xml.Name{})
+}
+
+// AttrIP parses the value of an attribute, found as by Attr, as an
+// IPv4 or IPv6 address.
+func (el *Element) AttrIP(space, local string) (net.IP, error) {
+	return el.parseIP(el.Attr(space, local), xml.Name{Space: space, Local: local})
+}
+
+// ContentUUID checks that the content of el is a UUID in the standard
+// 8-4-4-4-12 hexadecimal format, and returns it in lower case.
+func (el *Element) ContentUUID() (string, error) {
+	content, err := el.contentBytes()
+	if err != nil {
+		return "", err
+	}
+	return el.parseUUID(string(content), xml.Name{})
+}
+
+// AttrUUID is like ContentUUID, but for the value of an attribute,
+// found as by Attr.
+func (el *Element) AttrUUID(space, local string) (string, error) {
+	return el.parseUUID(el.Attr(space, local), xml.Name{Space: space, Local: local})
+}
+
+func (el *Element) parseURL(s string, attr xml.Name) (*url.URL, error) {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, el.attrValueError("anyURI", s, attr, errors.Unwrap(err))
+	}
+	return u, nil
+}
+
+func (el *Element) parseIP(s string, attr xml.Name) (net.IP, error) {
+	s = strings.TrimSpace(s)
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, el.attrValueError("IP address", s, attr, errInvalidIP)
+	}
+	return ip, nil
+}
+
+func (el *Element) parseUUID(s string, attr xml.Name) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) != 36 {
+		return "", el.attrValueError("UUID", s, attr, errInvalidUUID)
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", el.attrValueError("UUID", s, attr, errInvalidUUID)
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return "", el.attrValueError("UUID", s, attr, errInvalidUUID)
+			}
+		}
+	}
+	return strings.ToLower(s), nil
+}
+
+func (el *Element) attrValueError(typ, value string, attr xml.Name, err error) *ValueError {
+	e := el.valueError(typ, value, err)
+	e.Attr = attr
+	return e
+}
@@ -0,0 +1,75 @@
+package xmltree
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTypedValues(t *testing.T) {
+	root := parseDoc(t, []byte(`<config>
+	  <server addr=" 10.0.0.1 " id="6F9619FF-8B86-D011-B42D-00C04FC964FF">
+	    <url>https://example.com/x?y=1</url>
+	    <ip>::1</ip>
+	  </server>
+	  <server addr="10.0.0.999" id="nope">
+	    <url>http://[bad</url>
+	  </server>
+	</config>`))
+	good, bad := &root.Children[0], &root.Children[1]
+
+	if ip, err := good.AttrIP("", "addr"); err != nil || ip.String() != "10.0.0.1" {
+		t.Errorf("AttrIP = %v, %v", ip, err)
+	}
+	if id, err := good.AttrUUID("", "id"); err != nil || id != "6f9619ff-8b86-d011-b42d-00c04fc964ff" {
+		t.Errorf("AttrUUID = %v, %v", id, err)
+	}
+	if u, err := good.Children[0].ContentURL(); err != nil || u.Host != "example.com" {
+		t.Errorf("ContentURL = %v, %v", u, err)
+	}
+	if ip, err := good.Children[1].ContentIP(); err != nil || !ip.IsLoopback() {
+		t.Errorf("ContentIP = %v, %v", ip, err)
+	}
+
+	var verr *ValueError
+	_, err := bad.AttrIP("", "addr")
+	if !errors.As(err, &verr) || verr.Attr.Local != "addr" {
+		t.Fatalf("expected *ValueError for attribute, got %v", err)
+	}
+	if verr.Path != "/config/server[2]" {
+		t.Errorf("Path = %q, want /config/server[2]", verr.Path)
+	}
+	sub := *bad
+	_, err = sub.AttrIP("", "addr")
+	if !errors.As(err, &verr) || verr.Path != "/server" {
+		t.Errorf("Path of a copy = %q, want /server", verr.Path)
+	}
+	verr.SetPath(root, bad)
+	if !strings.Contains(err.Error(), "/config/server[2]/@addr") {
+		t.Errorf("error does not contain path: %v", err)
+	}
+	if _, err := bad.AttrUUID("", "id"); !errors.As(err, &verr) {
+		t.Errorf("expected *ValueError for invalid UUID, got %v", err)
+	}
+	if _, err := bad.Children[0].ContentURL(); !errors.As(err, &verr) || verr.Type != "anyURI" {
+		t.Errorf("expected *ValueError for invalid URL, got %v", err)
+	} else if verr.Path != "/config/server[2]/url" {
+		t.Errorf("Path = %q, want /config/server[2]/url", verr.Path)
+	}
+}
+
+func TestPathTo(t *testing.T) {
+	root := parseDoc(t, []byte(`<a><b/><c><d/></c><c><d/><e/></c></a>`))
+	tests := map[*Element]string{
+		root:                          "/a",
+		&root.Children[0]:             "/a/b",
+		&root.Children[2].Children[0]: "/a/c[2]/d",
+		&root.Children[2].Children[1]: "/a/c[2]/e",
+		new(Element):                  "",
+	}
+	for el, want := range tests {
+		if got := root.PathTo(el); got != want {
+			t.Errorf("PathTo(<%s>) = %q, want %q", el.Name.Local, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package xmltree
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// WatchOptions configures WatchFile. The zero value is usable.
+type WatchOptions struct {
+	// Interval is how often the file is checked for changes. The
+	// default is one second.
+	Interval time.Duration
+
+	// Parse holds the options used to parse the file.
+	Parse []ParseOption
+
+	// Validate, if not nil, is called with each newly parsed
+	// document. A document for which it returns an error is
+	// rejected, as if it could not be parsed.
+	Validate func(*Document) error
+}
+
+// A Watcher holds the latest valid version of a file watched by
+// WatchFile.
+type Watcher struct {
+	path    string
+	opts    WatchOptions
+	current atomic.Value // *Document
+	sum     [sha256.Size]byte
+	mod     time.Time
+	size    int64
+}
+
+// WatchFile parses the XML file at path, and then checks it for
+// changes until ctx is done, for services that reload their
+// configuration without restarting. WatchFile returns an error if
+// the file cannot be read, parsed or validated at first; otherwise,
+// each time the file's contents change, it is parsed and validated
+// again and onChange is called, from a goroutine started by
+// WatchFile, with either the new document or the error that
+// prevented it from being loaded. In the latter case the previous
+// document remains current.
+//
+// Changes are detected by polling the file's size and modification
+// time, and a file rewritten with the same contents is not reported.
+// Replacing the file by renaming a new one over it is the safest way
+// to update it, since a file may be read while it is being written;
+// a partially written file is usually reported as a parse error,
+// followed by the complete document.
+//
+//	w, err := xmltree.WatchFile(ctx, "config.xml", nil, func(doc *xmltree.Document, err error) {
+//		if err != nil {
+//			log.Printf("config not reloaded: %v", err)
+//		}
+//	})
+//	// ...
+//	cfg := w.Current()
+func WatchFile(ctx context.Context, path string, opts *WatchOptions, onChange func(*Document, error)) (*Watcher, error) {
+	w := &Watcher{path: path}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = time.Second
+	}
+	if _, err := w.check(); err != nil {
+		return nil, err
+	}
+	go func() {
+		ticker := time.NewTicker(w.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			changed, err := w.check()
+			if changed || err != nil {
+				onChange(w.Current(), err)
+			}
+		}
+	}()
+	return w, nil
+}
+
+// Current returns the most recent document that was loaded
+// successfully. It is safe to call from any goroutine. The document
+// is replaced, not modified, when the file changes, so callers
+// should treat it as read-only.
+func (w *Watcher) Current() *Document {
+	doc, _ := w.current.Load().(*Document)
+	return doc
+}
+
+// check loads the file if it has changed, reporting whether a new
+// document was loaded. Errors from a file that has not changed since
+// the last error are not repeated.
+func (w *Watcher) check() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, w.failed(err)
+	}
+	if info.ModTime().Equal(w.mod) && info.Size() == w.size {
+		return false, nil
+	}
+	w.mod, w.size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(data)
+	if sum == w.sum && w.Current() != nil {
+		return false, nil
+	}
+	w.sum = sum
+	doc, err := ParseDocument(data, w.opts.Parse...)
+	if err != nil {
+		return false, err
+	}
+	if w.opts.Validate != nil {
+		if err := w.opts.Validate(doc); err != nil {
+			return false, err
+		}
+	}
+	w.current.Store(doc)
+	return true, nil
+}
+
+// failed records that the file could not be examined, so that it is
+// read again once it reappears, and returns err.
+func (w *Watcher) failed(err error) error {
+	if w.mod.IsZero() && w.Current() != nil {
+		return nil
+	}
+	w.mod, w.size = time.Time{}, 0
+	return err
+}
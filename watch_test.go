@@ -0,0 +1,85 @@
+package xmltree
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.xml")
+	write := func(s string, mod time.Time) {
+		// Replace the file in one step, so that the watcher never
+		// sees it half written.
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// ensure the change is visible on file systems with coarse timestamps
+		os.Chtimes(tmp, mod, mod)
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write(`<config v="1"/>`, start)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type change struct {
+		doc *Document
+		err error
+	}
+	changes := make(chan change, 10)
+	opts := &WatchOptions{
+		Interval: 5 * time.Millisecond,
+		Validate: func(doc *Document) error {
+			if doc.Element().Attr("", "v") == "" {
+				return errors.New("missing version")
+			}
+			return nil
+		},
+	}
+	w, err := WatchFile(ctx, path, opts, func(doc *Document, err error) {
+		changes <- change{doc, err}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := w.Current().Element().Attr("", "v"); v != "1" {
+		t.Fatalf("initial version %q", v)
+	}
+	next := func() change {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change reported")
+		}
+		return change{}
+	}
+
+	write(`<config v="2"/>`, start.Add(time.Minute))
+	if c := next(); c.err != nil || c.doc.Element().Attr("", "v") != "2" {
+		t.Errorf("got %v, %v", c.doc, c.err)
+	}
+	write(`<config/>`, start.Add(2*time.Minute))
+	if c := next(); c.err == nil || c.doc.Element().Attr("", "v") != "2" {
+		t.Errorf("invalid document: got %v, %v", c.doc, c.err)
+	}
+	write(`<config`, start.Add(3*time.Minute))
+	if c := next(); c.err == nil {
+		t.Error("no error for malformed document")
+	}
+	if v := w.Current().Element().Attr("", "v"); v != "2" {
+		t.Errorf("current version %q after errors", v)
+	}
+
+	if _, err := WatchFile(ctx, filepath.Join(dir, "missing.xml"), nil, nil); err == nil {
+		t.Error("no error for missing file")
+	}
+}
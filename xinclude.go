@@ -0,0 +1,99 @@
+package xmltree
+
+import (
+	"bufio"
+	"io"
+)
+
+// XIncludeNamespace is the namespace of the XInclude elements written
+// by WithXInclude.
+const XIncludeNamespace = "http://www.w3.org/2001/XInclude"
+
+// An xinclude holds the state of the WithXInclude option.
+type xinclude struct {
+	sel     *Selector
+	create  func(i int, el *Element) (href string, w io.WriteCloser, err error)
+	matched map[*Element]bool
+	n       int
+}
+
+// WithXInclude moves the elements matching selector, relative to the
+// element being encoded, to separate documents, and writes an
+// xi:include element referring to each one in its place. For each
+// matching element, create is called with its index, counting from
+// 0, and the element; it returns the href to write in the reference
+// and a writer for the external document, which the encoder closes.
+// This splits a large document, such as a monolithic configuration
+// file, into pieces that an XInclude processor can reassemble.
+//
+// Elements are matched before WithFilter is applied; an element
+// replaced by the filter is written in place. EncodedSize calls
+// create too when this option is given. Matching elements within an
+// externalized element are themselves externalized, and referred to
+// from its document.
+//
+//	opt := xmltree.WithXInclude("//service", func(i int, el *xmltree.Element) (string, io.WriteCloser, error) {
+//		href := el.Attr("", "name") + ".xml"
+//		f, err := os.Create(filepath.Join(dir, href))
+//		return href, f, err
+//	})
+func WithXInclude(selector string, create func(i int, el *Element) (href string, w io.WriteCloser, err error)) EncodeOption {
+	return func(e *encoder) {
+		sel, err := CompileSelector(selector)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.xinclude = &xinclude{sel: sel, create: create}
+	}
+}
+
+// findIncluded records the elements below root that WithXInclude
+// should externalize.
+func (e *encoder) findIncluded(root *Element) {
+	x := e.xinclude
+	x.matched = make(map[*Element]bool)
+	x.n = 0
+	for _, el := range x.sel.MatchAll(root) {
+		x.matched[el] = true
+	}
+}
+
+// encodeIncluded writes el to an external document and a reference
+// to it in its place.
+func (e *encoder) encodeIncluded(el *Element, depth int) error {
+	x := e.xinclude
+	href, w, err := x.create(x.n, el)
+	if err != nil {
+		return err
+	}
+	x.n++
+	bw := bufio.NewWriter(w)
+	// The included document declares its own namespaces.
+	saved, hoisted := e.w, e.hoisted
+	e.w, e.hoisted = bw, nil
+	err = e.encode(el, nil, make(map[*Element]struct{}))
+	e.w, e.hoisted = saved, hoisted
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if e.pretty {
+		for i := 0; i < depth; i++ {
+			e.w.WriteString(e.indent)
+		}
+	}
+	e.w.WriteString(`<xi:include href="`)
+	escapeString(e.w, href)
+	e.w.WriteString(`" xmlns:xi="` + XIncludeNamespace + `" />`)
+	if e.pretty {
+		e.w.WriteByte('\n')
+	}
+	return nil
+}
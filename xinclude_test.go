@@ -0,0 +1,55 @@
+package xmltree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestXInclude(t *testing.T) {
+	root := parseDoc(t, []byte(`<config xmlns:x="urn:x"><name>app</name><service id="a"><x:port>1</x:port></service><service id="b"><plugin/></service></config>`))
+	if err := EncodeTo(new(bytes.Buffer), root, WithXInclude("[", nil)); err == nil {
+		t.Error("invalid selector accepted")
+	}
+
+	files := make(map[string]*bytes.Buffer)
+	opt := WithXInclude("//*[@id]", func(i int, el *Element) (string, io.WriteCloser, error) {
+		href := fmt.Sprintf("%s%d.xml", el.Name.Local, i)
+		files[href] = new(bytes.Buffer)
+		return href, nopCloser{files[href]}, nil
+	})
+	got := string(Marshal(root, opt))
+	want := `<config xmlns:x="urn:x"><name>app</name>` +
+		`<xi:include href="service0.xml" xmlns:xi="http://www.w3.org/2001/XInclude" />` +
+		`<xi:include href="service1.xml" xmlns:xi="http://www.w3.org/2001/XInclude" /></config>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	for href, want := range map[string]string{
+		"service0.xml": `<service id="a" xmlns:x="urn:x"><x:port>1</x:port></service>`,
+		"service1.xml": `<service id="b" xmlns:x="urn:x"><plugin /></service>`,
+	} {
+		if got := files[href].String(); got != want {
+			t.Errorf("%s: got %s, want %s", href, got, want)
+		}
+	}
+}
+
+func TestXIncludeNested(t *testing.T) {
+	root := parseDoc(t, []byte(`<a><b><c/></b></a>`))
+	files := make(map[string]*bytes.Buffer)
+	opt := WithXInclude("//*", func(i int, el *Element) (string, io.WriteCloser, error) {
+		href := el.Name.Local + ".xml"
+		files[href] = new(bytes.Buffer)
+		return href, nopCloser{files[href]}, nil
+	})
+	Marshal(root, opt)
+	want := `<b><xi:include href="c.xml" xmlns:xi="http://www.w3.org/2001/XInclude" /></b>`
+	if got := files["b.xml"].String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := files["c.xml"].String(); got != "<c />" {
+		t.Errorf("c.xml: got %s", got)
+	}
+}
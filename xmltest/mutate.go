@@ -0,0 +1,109 @@
+package xmltest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+)
+
+// oddStrings are injected into text and attribute values by MutateTree.
+// Some are valid but unusual, others are not allowed in XML at all.
+var oddStrings = []string{
+	"\x00",                      // not allowed in XML
+	"\x1b[31m",                  // terminal escape
+	"\uFFFE",                    // noncharacter
+	"\xff\xfe",                  // invalid UTF-8
+	"]]>",                       // CDATA terminator
+	"&amp;&lt;",                 // pre-escaped text
+	"\u202Egnp.exe",             // right-to-left override
+	"\u200B\u200D",              // zero-width characters
+	"'; DROP TABLE x; --",       // SQL injection
+	"<script>alert(1)</script>", // markup
+	strings.Repeat("A", 1<<16),  // long value
+	"\r\n\t ",                   // white space needing normalization
+}
+
+// MutateTree applies a single random structural mutation to el, in
+// place, and returns a description of it. Mutations include swapping,
+// duplicating, deleting and renaming elements, duplicating
+// attributes, nesting elements deeply, and injecting unusual or
+// invalid characters into text and attribute values. The result is
+// intended for fuzzing consumers of XML with realistic but hostile
+// input; it may not be well-formed when encoded.
+func MutateTree(r *rand.Rand, el *xmltree.Element) string {
+	elements := append([]*xmltree.Element{el}, el.Flatten()...)
+	target := elements[r.Intn(len(elements))]
+	path := target.Prefix(target.Name)
+
+	switch r.Intn(7) {
+	case 0:
+		if n := len(target.Children); n >= 2 {
+			i, j := r.Intn(n), r.Intn(n)
+			target.Children[i], target.Children[j] = target.Children[j], target.Children[i]
+			return fmt.Sprintf("swapped children %d and %d of <%s>", i, j, path)
+		}
+	case 1:
+		if n := len(target.StartElement.Attr); n > 0 {
+			a := target.StartElement.Attr[r.Intn(n)]
+			if r.Intn(2) == 0 {
+				a.Value = oddStrings[r.Intn(len(oddStrings))]
+			}
+			target.StartElement.Attr = append(target.StartElement.Attr, a)
+			return fmt.Sprintf("duplicated attribute %s of <%s>", a.Name.Local, path)
+		}
+	case 2:
+		if n := len(target.Children); n > 0 {
+			i := r.Intn(n)
+			target.Children = append(target.Children[:i], target.Children[i+1:]...)
+			return fmt.Sprintf("deleted child %d of <%s>", i, path)
+		}
+	case 3:
+		if n := len(target.Children); n > 0 {
+			i := r.Intn(n)
+			dup := target.Children[i]
+			target.Children = append(target.Children, dup)
+			return fmt.Sprintf("duplicated child %d of <%s>", i, path)
+		}
+	case 4:
+		old := target.Name.Local
+		target.Name.Local = oddNames[r.Intn(len(oddNames))]
+		return fmt.Sprintf("renamed <%s> to %q", old, target.Name.Local)
+	case 5:
+		depth := 1 + r.Intn(1000)
+		inner := *target
+		for i := 0; i < depth; i++ {
+			inner = xmltree.Element{
+				StartElement: xml.StartElement{Name: xml.Name{Local: "nest"}},
+				Scope:        target.Scope,
+				Children:     []xmltree.Element{inner},
+			}
+		}
+		target.Children = inner.Children[:1:1]
+		target.Content = nil
+		return fmt.Sprintf("nested contents of <%s> %d levels deep", path, depth)
+	}
+	// Fallback for mutations that did not apply to the target.
+	odd := oddStrings[r.Intn(len(oddStrings))]
+	if n := len(target.StartElement.Attr); n > 0 && r.Intn(2) == 0 {
+		i := r.Intn(n)
+		target.StartElement.Attr[i].Value += odd
+		return fmt.Sprintf("injected %q into attribute %s of <%s>", abbrev(odd), target.StartElement.Attr[i].Name.Local, path)
+	}
+	target.Content = append(append([]byte(nil), target.Content...), odd...)
+	return fmt.Sprintf("injected %q into content of <%s>", abbrev(odd), path)
+}
+
+// oddNames are unusual or invalid element names.
+var oddNames = []string{
+	"", "1invalid", "a b", "xml-reserved", "ns:too:many", "é", "a\x00b",
+}
+
+func abbrev(s string) string {
+	if len(s) > 20 {
+		return s[:20] + "..."
+	}
+	return s
+}
@@ -0,0 +1,27 @@
+package xmltest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+func TestMutateTree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	kinds := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		el := GenerateRandom(r, DefaultProfile)
+		desc := MutateTree(r, el)
+		if desc == "" {
+			t.Fatal("MutateTree returned empty description")
+		}
+		kinds[desc[:8]]++
+		// Mutated trees must still be encodable, though the
+		// result may not be well-formed.
+		xmltree.Marshal(el)
+	}
+	if len(kinds) < 5 {
+		t.Errorf("expected a variety of mutations, got %v", kinds)
+	}
+}
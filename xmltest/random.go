@@ -0,0 +1,191 @@
+package xmltest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+
+	"github.com/mdejong/xmltree"
+)
+
+// A CharClass is a set of characters that may appear in the text
+// content and attribute values of generated documents.
+type CharClass int
+
+const (
+	ASCII   CharClass = 1 << iota // letters, digits and spaces
+	Markup                        // characters that must be escaped: & < > "
+	Unicode                       // non-ASCII characters, including those outside the BMP
+)
+
+// A Profile controls the shape of the trees produced by GenerateRandom.
+type Profile struct {
+	MaxDepth    int // maximum nesting depth below the root
+	MaxChildren int // maximum children per element
+	MaxAttrs    int // maximum attributes per element
+	MaxTextLen  int // maximum length, in characters, of text and attribute values
+
+	// The probability, from 0 to 1, that an element declares a new
+	// namespace, and that an element or attribute name uses one
+	// of the namespaces in scope.
+	NamespaceDensity float64
+
+	// Characters used in text and attribute values. If zero,
+	// ASCII is used.
+	Chars CharClass
+}
+
+// DefaultProfile produces small trees using every feature.
+var DefaultProfile = Profile{
+	MaxDepth:         4,
+	MaxChildren:      4,
+	MaxAttrs:         3,
+	MaxTextLen:       16,
+	NamespaceDensity: 0.3,
+	Chars:            ASCII | Markup | Unicode,
+}
+
+var (
+	asciiChars   = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 ")
+	markupChars  = []rune(`&<>"`)
+	unicodeChars = []rune("éüßøΩЖ中文日本語🙂€")
+	nameStart    = "abcdefghijklmnopqrstuvwxyz"
+	nameChars    = "abcdefghijklmnopqrstuvwxyz0123456789-._"
+)
+
+// GenerateRandom produces a random, well-formed tree according to
+// profile, using r as the source of randomness. The same seed and
+// profile always produce the same tree. The tree is produced by
+// generating an XML document and parsing it, so it is equivalent to
+// the result of Parse, including its namespace scopes.
+func GenerateRandom(r *rand.Rand, profile Profile) *xmltree.Element {
+	g := generator{r: r, p: profile}
+	if g.p.Chars == 0 {
+		g.p.Chars = ASCII
+	}
+	for c := ASCII; c <= Unicode; c <<= 1 {
+		if g.p.Chars&c == 0 {
+			continue
+		}
+		switch c {
+		case ASCII:
+			g.chars = append(g.chars, asciiChars...)
+		case Markup:
+			g.chars = append(g.chars, markupChars...)
+		case Unicode:
+			g.chars = append(g.chars, unicodeChars...)
+		}
+	}
+	g.element(0, nil)
+	el, err := xmltree.Parse(g.buf.Bytes())
+	if err != nil {
+		// This is a bug in the generator
+		panic(fmt.Sprintf("xmltest: generated invalid XML: %v\n%s", err, g.buf.Bytes()))
+	}
+	return el
+}
+
+type generator struct {
+	r     *rand.Rand
+	p     Profile
+	buf   bytes.Buffer
+	chars []rune
+	nsSeq int
+}
+
+func (g *generator) chance(p float64) bool {
+	return g.r.Float64() < p
+}
+
+func (g *generator) name() string {
+	n := 1 + g.r.Intn(8)
+	b := []byte{nameStart[g.r.Intn(len(nameStart))]}
+	for i := 1; i < n; i++ {
+		b = append(b, nameChars[g.r.Intn(len(nameChars))])
+	}
+	return string(b)
+}
+
+func (g *generator) text() string {
+	if g.p.MaxTextLen <= 0 {
+		return ""
+	}
+	n := g.r.Intn(g.p.MaxTextLen + 1)
+	s := make([]rune, n)
+	for i := range s {
+		s[i] = g.chars[g.r.Intn(len(g.chars))]
+	}
+	return string(s)
+}
+
+// escape writes s with the only entity references understood by
+// xmltree.Parse.
+func (g *generator) escape(s string) {
+	for _, c := range s {
+		switch c {
+		case '&':
+			g.buf.WriteString("&amp;")
+		case '<':
+			g.buf.WriteString("&lt;")
+		case '>':
+			g.buf.WriteString("&gt;")
+		case '"':
+			g.buf.WriteString("&quot;")
+		default:
+			g.buf.WriteRune(c)
+		}
+	}
+}
+
+// element writes a random element. prefixes is the list of namespace
+// prefixes in scope.
+func (g *generator) element(depth int, prefixes []string) {
+	var decls []string
+	if g.chance(g.p.NamespaceDensity) {
+		g.nsSeq++
+		p := fmt.Sprintf("p%d", g.nsSeq)
+		decls = append(decls, p)
+		prefixes = append(prefixes[:len(prefixes):len(prefixes)], p)
+	}
+	qname := func() string {
+		if len(prefixes) > 0 && g.chance(g.p.NamespaceDensity) {
+			return prefixes[g.r.Intn(len(prefixes))] + ":" + g.name()
+		}
+		return g.name()
+	}
+	name := qname()
+	g.buf.WriteString("<" + name)
+	for _, p := range decls {
+		fmt.Fprintf(&g.buf, ` xmlns:%s="urn:xmltest:%s"`, p, p)
+	}
+	seen := make(map[string]bool)
+	nattrs := 0
+	if g.p.MaxAttrs > 0 {
+		nattrs = g.r.Intn(g.p.MaxAttrs + 1)
+	}
+	for i := 0; i < nattrs; i++ {
+		attr := qname()
+		// Two prefixes may be bound to different namespaces, so
+		// checking the qualified name is sufficient.
+		if seen[attr] {
+			continue
+		}
+		seen[attr] = true
+		g.buf.WriteString(" " + attr + `="`)
+		g.escape(g.text())
+		g.buf.WriteString(`"`)
+	}
+	g.buf.WriteString(">")
+
+	nchildren := 0
+	if depth < g.p.MaxDepth && g.p.MaxChildren > 0 {
+		nchildren = g.r.Intn(g.p.MaxChildren + 1)
+	}
+	if nchildren == 0 {
+		g.escape(g.text())
+	}
+	for i := 0; i < nchildren; i++ {
+		g.element(depth+1, prefixes)
+	}
+	g.buf.WriteString("</" + name + ">")
+}
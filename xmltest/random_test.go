@@ -0,0 +1,26 @@
+package xmltest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+func TestGenerateRandomRoundTrip(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		el := GenerateRandom(rand.New(rand.NewSource(seed)), DefaultProfile)
+		out := xmltree.Marshal(el)
+		if !AssertEqualXML(t, el, out) {
+			t.Fatalf("seed %d: round trip failed:\n%s", seed, out)
+		}
+	}
+}
+
+func TestGenerateRandomDeterministic(t *testing.T) {
+	a := xmltree.Marshal(GenerateRandom(rand.New(rand.NewSource(7)), DefaultProfile))
+	b := xmltree.Marshal(GenerateRandom(rand.New(rand.NewSource(7)), DefaultProfile))
+	if string(a) != string(b) {
+		t.Errorf("same seed produced different trees:\n%s\n%s", a, b)
+	}
+}
@@ -0,0 +1,256 @@
+package xmltest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mdejong/xmltree"
+)
+
+// A line is one line of the canonical form of a tree, with the path
+// of the element it belongs to.
+type line struct {
+	text, path string
+}
+
+// canonical renders a tree one tag per line, indented by depth, with
+// attributes sorted by name and namespace declarations left out, so
+// that insignificant differences do not appear in a textual diff.
+func (c *config) canonical(el *xmltree.Element) []line {
+	var lines []line
+	c.canonicalLines(&lines, "/"+el.Prefix(el.Name), el, 0)
+	return lines
+}
+
+func (c *config) canonicalLines(lines *[]line, path string, el *xmltree.Element, depth int) {
+	indent := strings.Repeat("  ", depth)
+	name := el.Prefix(el.Name)
+	var attrs []string
+	for _, a := range el.StartElement.Attr {
+		if isNS(a) {
+			continue
+		}
+		var value bytes.Buffer
+		xml.EscapeText(&value, []byte(a.Value))
+		attrs = append(attrs, fmt.Sprintf(` %s="%s"`, el.Prefix(a.Name), value.String()))
+	}
+	sort.Strings(attrs)
+	open := "<" + name + strings.Join(attrs, "")
+
+	if len(el.Children) == 0 {
+		text := c.text(el.Content)
+		if text == "" {
+			*lines = append(*lines, line{indent + open + "/>", path})
+			return
+		}
+		var content bytes.Buffer
+		xml.EscapeText(&content, []byte(text))
+		*lines = append(*lines, line{indent + open + ">" + content.String() + "</" + name + ">", path})
+		return
+	}
+	*lines = append(*lines, line{indent + open + ">", path})
+	seen := make(map[xml.Name]int)
+	for _, child := range c.order(el.Children) {
+		seen[child.Name]++
+		childPath := path + "/" + child.Prefix(child.Name)
+		if count(el.Children, child.Name) > 1 {
+			childPath = fmt.Sprintf("%s[%d]", childPath, seen[child.Name])
+		}
+		c.canonicalLines(lines, childPath, child, depth+1)
+	}
+	*lines = append(*lines, line{indent + "</" + name + ">", path})
+}
+
+// An edit is one step in transforming the lines of one document into
+// another: a line kept (' '), removed ('-') or added ('+').
+type edit struct {
+	op   byte
+	line line
+}
+
+// diffLines computes a shortest edit script between two lists of
+// lines, using the longest common subsequence. Common leading and
+// trailing lines are removed first, so that the quadratic part of the
+// computation covers only the region that changed.
+func diffLines(a, b []line) []edit {
+	var head, tail []edit
+	for len(a) > 0 && len(b) > 0 && a[0].text == b[0].text {
+		head = append(head, edit{' ', b[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1].text == b[len(b)-1].text {
+		tail = append(tail, edit{' ', b[len(b)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	// lcs[i][j] is the length of the longest common subsequence
+	// of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i].text == b[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	edits := head
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i].text == b[j].text:
+			edits = append(edits, edit{' ', b[j]})
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for k := len(tail) - 1; k >= 0; k-- {
+		edits = append(edits, tail[k])
+	}
+	return edits
+}
+
+// UnifiedDiff returns a unified diff between the canonical forms of
+// two trees, with context lines of unchanged text around each
+// change. In the canonical form, each tag is on its own line, and
+// attributes are sorted by name; the options IgnoreWhitespace and
+// IgnoreChildOrder are applied before comparing. Each hunk header is
+// followed by the path of the element where the hunk's first change
+// occurs. If the trees are equivalent, UnifiedDiff returns the empty
+// string.
+func UnifiedDiff(want, got *xmltree.Element, context int, opts ...Option) string {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	edits := diffLines(c.canonical(want), c.canonical(got))
+
+	var out strings.Builder
+	aLine, bLine := 1, 1 // line numbers at edits[i]
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			aLine, bLine = aLine+1, bLine+1
+			i++
+			continue
+		}
+		// Find the extent of the hunk: changes separated by no
+		// more than 2*context unchanged lines.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end, quiet := i, 0
+		for k := i; k < len(edits) && quiet <= 2*context; k++ {
+			if edits[k].op == ' ' {
+				quiet++
+			} else {
+				quiet, end = 0, k
+			}
+		}
+		end += context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		aStart, bStart := aLine-(i-start), bLine-(i-start)
+		var aCount, bCount int
+		var body strings.Builder
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+			fmt.Fprintf(&body, "%c%s\n", e.op, e.line.text)
+		}
+		if out.Len() == 0 {
+			out.WriteString("--- want\n+++ got\n")
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@ %s\n", aStart, aCount, bStart, bCount, edits[i].line.path)
+		out.WriteString(body.String())
+		for _, e := range edits[i:end] {
+			if e.op != '+' {
+				aLine++
+			}
+			if e.op != '-' {
+				bLine++
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// WriteHTMLDiff writes an HTML table showing the canonical forms of
+// two trees side by side, as used by UnifiedDiff. Removed lines are in
+// cells of class "del", added lines in cells of class "ins", and each
+// row has a title attribute giving the path of its element. The
+// caller supplies any style sheet.
+func WriteHTMLDiff(w io.Writer, want, got *xmltree.Element, opts ...Option) error {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	edits := diffLines(c.canonical(want), c.canonical(got))
+
+	var out bytes.Buffer
+	out.WriteString("<table class=\"xmldiff\">\n<tr><th>want</th><th>got</th></tr>\n")
+	cell := func(class string, l *line) {
+		if l == nil {
+			out.WriteString(`<td class="empty"></td>`)
+			return
+		}
+		fmt.Fprintf(&out, `<td class="%s"><pre>%s</pre></td>`, class, html.EscapeString(l.text))
+	}
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			fmt.Fprintf(&out, `<tr title="%s">`, html.EscapeString(edits[i].line.path))
+			cell("same", &edits[i].line)
+			cell("same", &edits[i].line)
+			out.WriteString("</tr>\n")
+			i++
+			continue
+		}
+		// Pair a run of removed lines with the following run
+		// of added lines.
+		var dels, inss []line
+		for ; i < len(edits) && edits[i].op == '-'; i++ {
+			dels = append(dels, edits[i].line)
+		}
+		for ; i < len(edits) && edits[i].op == '+'; i++ {
+			inss = append(inss, edits[i].line)
+		}
+		for k := 0; k < len(dels) || k < len(inss); k++ {
+			var d, n *line
+			path := ""
+			if k < len(inss) {
+				n, path = &inss[k], inss[k].path
+			}
+			if k < len(dels) {
+				d, path = &dels[k], dels[k].path
+			}
+			fmt.Fprintf(&out, `<tr title="%s">`, html.EscapeString(path))
+			cell("del", d)
+			cell("ins", n)
+			out.WriteString("</tr>\n")
+		}
+	}
+	out.WriteString("</table>\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
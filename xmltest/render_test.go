@@ -0,0 +1,65 @@
+package xmltest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+func mustParse(t *testing.T, s string) *xmltree.Element {
+	t.Helper()
+	el, err := xmltree.Parse([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return el
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	want := mustParse(t, `<cfg><a x="1" y="2"/><b>one</b><c/><d/><e/><f/><g>7</g></cfg>`)
+	got := mustParse(t, `<cfg><a y="2" x="1"/><b>two</b><c/><d/><e/><f/><g>8</g><h/></cfg>`)
+	diff := UnifiedDiff(want, got, 1)
+	expected := `--- want
++++ got
+@@ -2,3 +2,3 @@ /cfg/b
+   <a x="1" y="2"/>
+-  <b>one</b>
++  <b>two</b>
+   <c/>
+@@ -7,3 +7,4 @@ /cfg/g
+   <f/>
+-  <g>7</g>
++  <g>8</g>
++  <h/>
+ </cfg>
+`
+	if diff != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", diff, expected)
+	}
+	if d := UnifiedDiff(want, want, 3); d != "" {
+		t.Errorf("identical trees gave diff:\n%s", d)
+	}
+	spaced := mustParse(t, `<cfg><a x="1" y="2"/><b> one </b><c/><d/><e/><f/><g>7</g></cfg>`)
+	if d := UnifiedDiff(want, spaced, 3, IgnoreWhitespace()); d != "" {
+		t.Errorf("IgnoreWhitespace was not applied:\n%s", d)
+	}
+}
+
+func TestWriteHTMLDiff(t *testing.T) {
+	want := mustParse(t, `<r><a>1 &amp; 2</a><b/></r>`)
+	got := mustParse(t, `<r><a>3</a></r>`)
+	var buf strings.Builder
+	if err := WriteHTMLDiff(&buf, want, got); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		`<tr title="/r/a"><td class="del"><pre>  &lt;a&gt;1 &amp;amp; 2&lt;/a&gt;</pre></td><td class="ins"><pre>  &lt;a&gt;3&lt;/a&gt;</pre></td></tr>`,
+		`<tr title="/r/b"><td class="del"><pre>  &lt;b/&gt;</pre></td><td class="empty"></td></tr>`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output lacks %s:\n%s", s, out)
+		}
+	}
+}
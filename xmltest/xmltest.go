@@ -0,0 +1,255 @@
+// Package xmltest provides helpers for testing code that produces XML
+// documents, built on the xmltree package.
+package xmltest // import "github.com/mdejong/xmltree/xmltest"
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+// maxReported is the number of differences reported by AssertEqualXML
+// before the rest are summarized.
+const maxReported = 20
+
+// An Option modifies the comparison made by Diff and AssertEqualXML.
+type Option func(*config)
+
+type config struct {
+	ignoreWhitespace bool
+	ignoreChildOrder bool
+	strictAttrOrder  bool
+}
+
+// IgnoreWhitespace trims leading and trailing white space from text
+// content, and collapses runs of white space within it, before
+// comparing.
+func IgnoreWhitespace() Option {
+	return func(c *config) { c.ignoreWhitespace = true }
+}
+
+// IgnoreChildOrder compares the children of each element without
+// regard to their order.
+func IgnoreChildOrder() Option {
+	return func(c *config) { c.ignoreChildOrder = true }
+}
+
+// StrictAttrOrder requires attributes to appear in the same order.
+// By default, the order of attributes is ignored, as it is not
+// significant in XML.
+func StrictAttrOrder() Option {
+	return func(c *config) { c.strictAttrOrder = true }
+}
+
+// A Difference describes a single difference between two trees.
+type Difference struct {
+	// Path of the element where the difference was found, such
+	// as /envelope/body/item[2].
+	Path string
+	// What differs; for example "content" or "attribute id".
+	What string
+	// Expected and actual values. An empty string means the item
+	// is absent.
+	Want, Got string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s: want %q, got %q", d.Path, d.What, d.Want, d.Got)
+}
+
+// Diff compares two trees and returns the differences between them.
+// Namespace prefixes and xmlns declarations are ignored; elements and
+// attributes are compared by their resolved names.
+func Diff(want, got *xmltree.Element, opts ...Option) []Difference {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	var diffs []Difference
+	c.diff(&diffs, "/"+want.Prefix(want.Name), want, got)
+	return diffs
+}
+
+// AssertEqualXML reports a test error, listing every difference found,
+// if want and got are not equivalent XML documents. want and got may
+// each be a string, a []byte, or an *xmltree.Element. AssertEqualXML
+// returns true if the documents are equivalent.
+func AssertEqualXML(t testing.TB, want, got interface{}, opts ...Option) bool {
+	t.Helper()
+	w, err := toElement(want)
+	if err != nil {
+		t.Errorf("xmltest: parsing expected document: %v", err)
+		return false
+	}
+	g, err := toElement(got)
+	if err != nil {
+		t.Errorf("xmltest: parsing actual document: %v", err)
+		return false
+	}
+	diffs := Diff(w, g, opts...)
+	if len(diffs) == 0 {
+		return true
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "XML documents differ (%d differences):", len(diffs))
+	for i, d := range diffs {
+		if i == maxReported {
+			fmt.Fprintf(&msg, "\n\t... and %d more", len(diffs)-maxReported)
+			break
+		}
+		fmt.Fprintf(&msg, "\n\t%s", d)
+	}
+	t.Error(msg.String())
+	return false
+}
+
+func toElement(v interface{}) (*xmltree.Element, error) {
+	switch v := v.(type) {
+	case *xmltree.Element:
+		return v, nil
+	case []byte:
+		return xmltree.Parse(v)
+	case string:
+		return xmltree.Parse([]byte(v))
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+func (c *config) text(b []byte) string {
+	if c.ignoreWhitespace {
+		return strings.Join(strings.Fields(string(b)), " ")
+	}
+	return string(b)
+}
+
+func (c *config) diff(diffs *[]Difference, path string, want, got *xmltree.Element) {
+	if want.Name != got.Name {
+		*diffs = append(*diffs, Difference{
+			Path: path,
+			What: "element name",
+			Want: want.Prefix(want.Name),
+			Got:  got.Prefix(got.Name),
+		})
+		return
+	}
+	c.diffAttrs(diffs, path, want, got)
+
+	if len(want.Children) == 0 && len(got.Children) == 0 {
+		if w, g := c.text(want.Content), c.text(got.Content); w != g {
+			*diffs = append(*diffs, Difference{Path: path, What: "content", Want: w, Got: g})
+		}
+		return
+	}
+
+	wantChildren, gotChildren := c.order(want.Children), c.order(got.Children)
+	seen := make(map[xml.Name]int)
+	for i := 0; i < len(wantChildren) || i < len(gotChildren); i++ {
+		var w, g *xmltree.Element
+		if i < len(wantChildren) {
+			w = wantChildren[i]
+		}
+		if i < len(gotChildren) {
+			g = gotChildren[i]
+		}
+		ref := w
+		if ref == nil {
+			ref = g
+		}
+		seen[ref.Name]++
+		childPath := fmt.Sprintf("%s/%s", path, ref.Prefix(ref.Name))
+		if count(want.Children, ref.Name) > 1 || count(got.Children, ref.Name) > 1 {
+			childPath = fmt.Sprintf("%s[%d]", childPath, seen[ref.Name])
+		}
+		switch {
+		case g == nil:
+			*diffs = append(*diffs, Difference{Path: childPath, What: "missing element", Want: string(xmltree.Marshal(w))})
+		case w == nil:
+			*diffs = append(*diffs, Difference{Path: childPath, What: "unexpected element", Got: string(xmltree.Marshal(g))})
+		default:
+			c.diff(diffs, childPath, w, g)
+		}
+	}
+}
+
+func count(children []xmltree.Element, name xml.Name) int {
+	n := 0
+	for i := range children {
+		if children[i].Name == name {
+			n++
+		}
+	}
+	return n
+}
+
+// order returns pointers to a list of children, sorted if the
+// order of children is to be ignored.
+func (c *config) order(children []xmltree.Element) []*xmltree.Element {
+	list := make([]*xmltree.Element, len(children))
+	keys := make(map[*xmltree.Element]string, len(children))
+	for i := range children {
+		list[i] = &children[i]
+	}
+	if !c.ignoreChildOrder {
+		return list
+	}
+	key := func(el *xmltree.Element) string {
+		if k, ok := keys[el]; ok {
+			return k
+		}
+		k := el.Name.Space + " " + el.Name.Local + " " + string(xmltree.Marshal(el))
+		keys[el] = k
+		return k
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return key(list[i]) < key(list[j])
+	})
+	return list
+}
+
+func isNS(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns")
+}
+
+func (c *config) diffAttrs(diffs *[]Difference, path string, want, got *xmltree.Element) {
+	gotAttrs := make(map[xml.Name]string)
+	var wantOrder, gotOrder []string
+	for _, a := range got.StartElement.Attr {
+		if isNS(a) {
+			continue
+		}
+		gotAttrs[a.Name] = a.Value
+		gotOrder = append(gotOrder, got.Prefix(a.Name))
+	}
+	for _, a := range want.StartElement.Attr {
+		if isNS(a) {
+			continue
+		}
+		wantOrder = append(wantOrder, want.Prefix(a.Name))
+		what := "attribute " + want.Prefix(a.Name)
+		if v, ok := gotAttrs[a.Name]; !ok {
+			*diffs = append(*diffs, Difference{Path: path, What: what, Want: a.Value})
+		} else if v != a.Value {
+			*diffs = append(*diffs, Difference{Path: path, What: what, Want: a.Value, Got: v})
+		}
+		delete(gotAttrs, a.Name)
+	}
+	for _, a := range got.StartElement.Attr {
+		if _, ok := gotAttrs[a.Name]; ok {
+			*diffs = append(*diffs, Difference{
+				Path: path,
+				What: "attribute " + got.Prefix(a.Name),
+				Got:  a.Value,
+			})
+		}
+	}
+	if c.strictAttrOrder {
+		w, g := strings.Join(wantOrder, " "), strings.Join(gotOrder, " ")
+		if w != g && len(wantOrder) == len(gotOrder) {
+			*diffs = append(*diffs, Difference{Path: path, What: "attribute order", Want: w, Got: g})
+		}
+	}
+}
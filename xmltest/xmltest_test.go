@@ -0,0 +1,62 @@
+package xmltest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recorder captures errors reported by AssertEqualXML.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqualXML(t *testing.T) {
+	want := `<order xmlns="urn:o"><id>1</id><item sku="a">x</item><item sku="b">y</item></order>`
+	same := `<o:order xmlns:o="urn:o"><o:id>1</o:id><o:item sku="a">x</o:item><o:item sku="b">y</o:item></o:order>`
+	if !AssertEqualXML(t, want, same) {
+		t.Fatal("documents differing only in prefixes reported as different")
+	}
+	reordered := `<order xmlns="urn:o"><item sku="b">y</item><id>
+		1
+	</id><item sku="a">x</item></order>`
+	AssertEqualXML(t, want, reordered, IgnoreChildOrder(), IgnoreWhitespace())
+
+	r := new(recorder)
+	got := `<order xmlns="urn:o"><id>2</id><item sku="a" extra="1">x</item></order>`
+	if AssertEqualXML(r, want, got) {
+		t.Fatal("AssertEqualXML reported different documents as equal")
+	}
+	if len(r.errors) != 1 {
+		t.Fatalf("expected one error report, got %d", len(r.errors))
+	}
+	for _, s := range []string{
+		`/order/id: content: want "1", got "2"`,
+		`/order/item[1]: attribute extra: want "", got "1"`,
+		`/order/item[2]: missing element`,
+	} {
+		if !strings.Contains(r.errors[0], s) {
+			t.Errorf("report does not contain %q:\n%s", s, r.errors[0])
+		}
+	}
+}
+
+func TestStrictAttrOrder(t *testing.T) {
+	a, b := `<a x="1" y="2"/>`, `<a y="2" x="1"/>`
+	AssertEqualXML(t, a, b)
+	r := new(recorder)
+	if AssertEqualXML(r, a, b, StrictAttrOrder()) {
+		t.Error("StrictAttrOrder ignored attribute order")
+	}
+}
@@ -14,8 +14,7 @@ import (
 	"io"
 	"sort"
 	"strings"
-
-	"golang.org/x/net/html/charset"
+	"sync/atomic"
 )
 
 const (
@@ -47,8 +46,36 @@ type Element struct {
 	// The raw content contained within this element's start and
 	// end tags. Uses the underlying byte array passed to Parse.
 	Content []byte
+	// If true, Content is written as a CDATA section rather than
+	// as escaped text. See WithCDATA.
+	CDATA bool
 	// Sub-elements contained within this element.
 	Children []Element
+	// Comments and other markup that is not an element, in
+	// document order, when requested with a ParseOption such as
+	// WithComments. See Misc.
+	Misc []Misc
+
+	// content moved to a ContentStore by WithContentSpill
+	spill *spilled
+
+	// namespace declarations, as they appeared in the start tag
+	xmlns []xml.Attr
+
+	// the prefix of the element's name in the source document
+	prefix string
+
+	// the prefixes of its namespaced attribute names in the source
+	// document, each in the Value of an attribute of the same name
+	attrPrefixes []xml.Attr
+
+	// lazily built *childIndex used by Child and ChildrenNamed
+	index atomic.Value
+
+	// the element containing this one, and the position of this
+	// element in its Children; see Parent
+	parent *Element
+	pos    int
 }
 
 // Attr gets the value of the first attribute whose name matches the
@@ -74,12 +101,12 @@ func (outer *Scope) JoinScope(inner *Scope) *Scope {
 	return &Scope{append(outer.ns, inner.ns...)}
 }
 
-// Unmarshal parses the XML encoding of the Element and stores the result
-// in the value pointed to by v. Unmarshal follows the same rules as
-// xml.Unmarshal, but only parses the portion of the XML document
-// contained by the Element.
+// Unmarshal stores the contents of the Element in the value pointed
+// to by v. Unmarshal follows the same rules as xml.Unmarshal, but only
+// considers the portion of the XML document contained by the Element.
+// It is equivalent to el.Unmarshal(v).
 func Unmarshal(el *Element, v interface{}) error {
-	return xml.Unmarshal(Marshal(el), v)
+	return el.Unmarshal(v)
 }
 
 // A Scope represents the xml namespace scope at a given position in
@@ -137,7 +164,8 @@ func (scope *Scope) ResolveDefault(qname, defaultns string) xml.Name {
 // Prefix is the inverse of Resolve. It uses the closest prefix
 // defined for a namespace to create a string of the form
 // prefix:local. If the namespace cannot be found, or is the
-// default namespace, an unqualified name is returned.
+// default namespace, an unqualified name is returned. Prefixes that
+// have been bound again to another namespace are not used.
 func (scope *Scope) Prefix(name xml.Name) (qname string) {
 	switch name.Space {
 	case "":
@@ -148,7 +176,7 @@ func (scope *Scope) Prefix(name xml.Name) (qname string) {
 		return "xmlns:" + name.Local
 	}
 	for i := len(scope.ns) - 1; i >= 0; i-- {
-		if scope.ns[i].Space == name.Space {
+		if scope.ns[i].Space == name.Space && !scope.shadowed(i) {
 			if scope.ns[i].Local == "" {
 				// Favor default NS if there is an extra
 				// qualified NS declaration
@@ -161,6 +189,41 @@ func (scope *Scope) Prefix(name xml.Name) (qname string) {
 	return qname
 }
 
+// shadowed reports whether the prefix of the i'th binding in the
+// scope is bound again by a later one.
+func (scope *Scope) shadowed(i int) bool {
+	for _, later := range scope.ns[i+1:] {
+		if later.Local == scope.ns[i].Local {
+			return true
+		}
+	}
+	return false
+}
+
+// NamespaceDecls returns the xmlns and xmlns:prefix attributes from
+// the element's start tag, in the order they appeared, exactly as
+// they were parsed. Parse removes these attributes from the Attr
+// field and records their effect in the element's Scope, which is
+// sorted and includes the declarations of ancestors; NamespaceDecls
+// allows tools that must reproduce the original placement of
+// declarations, such as those verifying signatures, to do so. It
+// returns nil for elements that were not produced by Parse, and is
+// not updated when the tree is modified.
+func (el *Element) NamespaceDecls() []xml.Attr {
+	return el.xmlns
+}
+
+// namespaceDecls returns the namespace declarations among attrs.
+func namespaceDecls(attrs []xml.Attr) []xml.Attr {
+	var decls []xml.Attr
+	for _, attr := range attrs {
+		if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+			decls = append(decls, attr)
+		}
+	}
+	return decls
+}
+
 func (scope *Scope) pushNS(tag xml.StartElement) []xml.Attr {
 	var ns []xml.Name
 	var newAttrs []xml.Attr
@@ -190,94 +253,349 @@ func (scope *Scope) pushNS(tag xml.StartElement) []xml.Attr {
 // Save some typing when scanning xml
 type scanner struct {
 	*xml.Decoder
-	tok xml.Token
-	err error
+	tok  xml.Token
+	err  error
+	opts *parseOptions
+
+	// interned name strings
+	names map[string]string
+
+	// Children of the elements being parsed are collected in
+	// stack[depth], then copied to a slice of the exact size,
+	// carved from slab, when the element ends.
+	stack    [][]Element
+	slab     []Element
+	slabHint int
+
+	// This parse's copy of the WithParseProgress state
+	progress *progress
+
+	// Estimated memory used by the tree, for WithMaxMemory
+	memory int64
+
+	// The element being parsed and its ancestors, kept only when
+	// matching WithSkipElements selectors.
+	path []*Element
+
+	// Input offset at which the current token begins
+	tokStart int64
+
+	// When parsing a fragment wrapped in an element that is not part
+	// of the document, outer is 1, and base is the offset of the end
+	// of the wrapper's start tag.
+	outer int
+	base  int64
+}
+
+// offset returns the input offset of the decoder, relative to the
+// start of the document or fragment being parsed.
+func (s *scanner) offset() int64 {
+	return s.InputOffset() - s.base
+}
+
+// wrapper reports whether the element at depth is the wrapper around
+// a fragment.
+func (s *scanner) wrapper(depth int) bool {
+	return depth < s.outer
+}
+
+func (s *scanner) pushChild(depth int, child Element) {
+	for len(s.stack) <= depth {
+		s.stack = append(s.stack, make([]Element, 0, s.opts.childrenHint))
+	}
+	s.stack[depth] = append(s.stack[depth], child)
+}
+
+// childCount returns the number of children of the element at
+// depth that have been parsed so far.
+func (s *scanner) childCount(depth int) int {
+	if depth >= len(s.stack) {
+		return 0
+	}
+	return len(s.stack[depth])
+}
+
+func (s *scanner) popChildren(depth int) []Element {
+	if depth >= len(s.stack) || len(s.stack[depth]) == 0 {
+		return nil
+	}
+	pending := s.stack[depth]
+	n := len(pending)
+	if len(s.slab) < n {
+		size := n
+		if s.slabHint > size {
+			size = s.slabHint
+		}
+		// Only the first allocation is sized by the hint; if
+		// it turns out to be too small, grow in smaller steps.
+		s.slabHint /= 4
+		s.slab = make([]Element, size)
+	}
+	children := s.slab[:n:n]
+	s.slab = s.slab[n:]
+	copy(children, pending)
+	for i := range pending {
+		pending[i] = Element{}
+	}
+	s.stack[depth] = pending[:0]
+	return children
+}
+
+// intern replaces the strings in the names of a start tag and its
+// attributes with previously seen copies, if possible.
+func (s *scanner) intern(tag *xml.StartElement) {
+	if s.opts.noIntern {
+		return
+	}
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	tag.Name.Space = s.internString(tag.Name.Space)
+	tag.Name.Local = s.internString(tag.Name.Local)
+	for i := range tag.Attr {
+		tag.Attr[i].Name.Space = s.internString(tag.Attr[i].Name.Space)
+		tag.Attr[i].Name.Local = s.internString(tag.Attr[i].Name.Local)
+	}
+}
+
+func (s *scanner) internString(str string) string {
+	if v, ok := s.names[str]; ok {
+		return v
+	}
+	s.names[str] = str
+	return str
 }
 
 func (s *scanner) scan() bool {
 	if s.err != nil {
 		return false
 	}
+	s.tokStart = s.InputOffset()
 	s.tok, s.err = s.Token()
 	return s.err == nil
 }
 
-// Parse builds a tree of Elements by reading an XML document.  The
-// byte slice passed to Parse is expected to be a valid XML document
-// with a single root element.
-func Parse(doc []byte) (*Element, error) {
-	d := xml.NewDecoder(bytes.NewReader(doc))
-
-	// The xmltree package, when constructing the tree, takes slices
-	// of the source document for chardata (data between tags). To do
-	// this, it takes the position of the Decoder in the utf-8 input
-	// stream. If the source document is not utf8, the position may be
-	// incorrect and cause invalid data or a run-time panic. So we copy
-	// the utf8 conversion to an internal buffer.
-	utf8buf := bytes.NewBuffer(doc[:0])
-	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
-		utf8input, err := charset.NewReaderLabel(label, r)
-		if err != nil {
-			return nil, err
+// tagPrefix returns the namespace prefix in the name of the start
+// tag that was just scanned, reading it from the source document.
+// Like the slices taken for Content, it may reach past the length of
+// data, up to its capacity.
+func (s *scanner) tagPrefix(data []byte) string {
+	data = data[:cap(data)]
+	if s.tokStart >= int64(len(data)) || data[s.tokStart] != '<' {
+		return ""
+	}
+	for i, c := range data[s.tokStart+1:] {
+		switch c {
+		case ':':
+			prefix := data[s.tokStart+1 : s.tokStart+1+int64(i)]
+			if v, ok := s.names[string(prefix)]; ok {
+				return v
+			}
+			str := string(prefix)
+			if s.names != nil {
+				s.names[str] = str
+			}
+			return str
+		case ' ', '\t', '\r', '\n', '/', '>':
+			return ""
 		}
-		// At this point, the encoding/xml package has already
-		// parsed the <?xml?> header. To be able to index
-		// into the document, we need to account for this.
-		padding := make([]byte, int(d.InputOffset()))
-		utf8buf.Write(padding)
+	}
+	return ""
+}
 
-		_, err = io.Copy(utf8buf, utf8input)
-		if err != nil {
-			return nil, err
+// attrPrefixes returns the prefixes of the namespaced attributes in
+// attrs, which are those of the start tag being parsed, in the form
+// stored in Element.attrPrefixes.
+func (s *scanner) attrPrefixes(data []byte, attrs []xml.Attr) []xml.Attr {
+	namespaced := false
+	for _, a := range attrs {
+		if a.Name.Space != "" && a.Name.Space != "xmlns" {
+			namespaced = true
 		}
-		return bytes.NewReader(utf8buf.Bytes()[len(padding)+1:]), nil
 	}
-	scanner := scanner{Decoder: d}
-	root := new(Element)
-
-	for scanner.scan() {
-		if start, ok := scanner.tok.(xml.StartElement); ok {
-			root.StartElement = start
+	if !namespaced {
+		return nil
+	}
+	data = data[:cap(data)]
+	end := s.InputOffset()
+	if s.tokStart >= end || end > int64(len(data)) || data[s.tokStart] != '<' {
+		return nil
+	}
+	tag := data[s.tokStart:end]
+	skip := func(i int, stop func(c byte) bool) int {
+		for i < len(tag) && !stop(tag[i]) {
+			i++
+		}
+		return i
+	}
+	isEnd := func(c byte) bool { return isSpace(c) || c == '/' || c == '>' }
+	var prefixes []xml.Attr
+	i := skip(1, isEnd)
+	for _, a := range attrs {
+		i = skip(i, func(c byte) bool { return !isSpace(c) })
+		begin := i
+		i = skip(i, func(c byte) bool { return c == '=' || isSpace(c) })
+		qname := tag[begin:i]
+		i = skip(i, func(c byte) bool { return c == '"' || c == '\'' })
+		if i >= len(tag) {
 			break
 		}
+		quote := tag[i]
+		i = skip(i+1, func(c byte) bool { return c == quote }) + 1
+		if a.Name.Space == "" || a.Name.Space == "xmlns" {
+			continue
+		}
+		if n := bytes.IndexByte(qname, ':'); n > 0 {
+			prefix := string(qname[:n])
+			if s.names != nil {
+				prefix = s.internString(prefix)
+			}
+			prefixes = append(prefixes, xml.Attr{Name: a.Name, Value: prefix})
+		}
 	}
-	if scanner.err != nil {
-		return nil, scanner.err
+	return prefixes
+}
+
+// Parse builds a tree of Elements by reading an XML document.  The
+// byte slice passed to Parse is expected to be a valid XML document
+// with a single root element. The behavior of Parse may be modified
+// with one or more ParseOptions. The root of the tree comes from
+// AcquireElement, and may be passed to ReleaseElement once the tree
+// is no longer needed; the rest of the tree is not pooled.
+func Parse(doc []byte, opts ...ParseOption) (*Element, error) {
+	return NewParser(opts...).Parse(doc)
+}
+
+// trailing checks that nothing but white space, comments and
+// processing instructions follow the root element.
+func (s *scanner) trailing() error {
+	for s.scan() {
+		switch tok := s.tok.(type) {
+		case xml.Comment, xml.ProcInst:
+			continue
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) == 0 {
+				continue
+			}
+		}
+		return fmt.Errorf("%w at offset %d", ErrTrailingContent, s.InputOffset())
 	}
-	if err := root.parse(&scanner, utf8buf.Bytes(), 0); err != nil {
-		return nil, err
+	if s.err != io.EOF {
+		return s.err
 	}
-	return root, nil
+	return nil
 }
 
 func (el *Element) parse(scanner *scanner, data []byte, depth int) error {
-	if depth > recursionLimit {
+	if depth-scanner.outer > recursionLimit {
 		return errDeepXML
 	}
+	wrapper := scanner.wrapper(depth)
+	if scanner.progress != nil && !wrapper {
+		if err := scanner.progress.update(scanner.offset()); err != nil {
+			return err
+		}
+	}
+	el.attrPrefixes = scanner.attrPrefixes(data, el.StartElement.Attr)
+	if !wrapper {
+		if err := scanner.opts.checkLimits(el.StartElement, scanner.offset()); err != nil {
+			return err
+		}
+		attrs, err := scanner.opts.checkAttrs(el.StartElement, scanner.offset())
+		if err != nil {
+			return err
+		}
+		el.StartElement.Attr = attrs
+	}
+	scanner.intern(&el.StartElement)
+	el.xmlns = namespaceDecls(el.StartElement.Attr)
+	el.prefix = scanner.tagPrefix(data)
 	el.StartElement.Attr = el.pushNS(el.StartElement)
+	if !wrapper {
+		if err := scanner.account(el, el.startFootprint()); err != nil {
+			return err
+		}
+	}
+	if len(scanner.opts.skip) > 0 {
+		scanner.path = append(scanner.path[:depth], el)
+	}
 
 	begin := scanner.InputOffset()
 	end := begin
+	var cuts []int64 // offsets of skipped children
+	var cdata []byte // text of CDATA sections, for WithCDATA
+	sections, text := 0, false
 walk:
 	for scanner.scan() {
 		switch tok := scanner.tok.(type) {
 		case xml.StartElement:
 			child := Element{StartElement: tok.Copy(), Scope: el.Scope}
+			if scanner.skipped(&child, depth) {
+				cuts = append(cuts, end)
+				if err := scanner.Skip(); err != nil {
+					return err
+				}
+				cuts = append(cuts, scanner.InputOffset())
+				break
+			}
 			if err := child.parse(scanner, data, depth+1); err != nil {
 				return err
 			}
-			el.Children = append(el.Children, child)
+			scanner.pushChild(depth, child)
+		case xml.CharData:
+			if scanner.opts.keepText(el) {
+				el.text(tok, scanner.childCount(depth))
+			}
+			if !scanner.opts.cdata {
+				break
+			}
+			if scanner.isCDATA(data) {
+				cdata = append(cdata, tok...)
+				sections++
+			} else {
+				text = true
+			}
+		case xml.Comment, xml.ProcInst:
+			if m, ok := scanner.misc(scanner.childCount(depth)); ok {
+				el.Misc = append(el.Misc, m)
+				cuts = append(cuts, scanner.tokStart, scanner.InputOffset())
+			}
 		case xml.EndElement:
 			if tok.Name != el.Name {
 				return fmt.Errorf("Expecting </%s>, got </%s>", el.Prefix(el.Name), el.Prefix(tok.Name))
 			}
+			el.Children = scanner.popChildren(depth)
+			if scanner.opts.keepText(el) && len(el.Children) == 0 {
+				el.dropText()
+			}
 			el.Content = data[int(begin):int(end)]
+			if cuts != nil {
+				el.Content = cutContent(data, begin, end, cuts)
+			}
 			contentStr := string(el.Content)
 			encStr, encErr := xmlDecodeString(contentStr)
 			if encErr != nil {
 				return encErr
 			}
 			el.Content = []byte(encStr)
+			if sections > 0 && !text && len(el.Children) == 0 {
+				el.Content, el.CDATA = cdata, true
+			}
+			if wrapper {
+				break walk
+			}
+			if err := scanner.opts.spill(el); err != nil {
+				return err
+			}
+			used := int64(cap(el.Content)) + int64(len(el.Children))*elementSize + miscFootprint(el.Misc)
+			if err := scanner.account(el, used); err != nil {
+				return err
+			}
+			for _, fn := range scanner.opts.onElement {
+				if err := fn(el); err != nil {
+					return err
+				}
+			}
 			break walk
 		}
 		end = scanner.InputOffset()
@@ -319,6 +637,26 @@ func (el *Element) SetAttr(space, local, value string) {
 	})
 }
 
+// RemoveAttr removes every attribute of el matching space and local,
+// with the same matching rules as Attr, and reports whether there
+// were any. The attribute slice may be shared with other trees, so
+// it is copied rather than modified.
+func (el *Element) RemoveAttr(space, local string) bool {
+	var attrs []xml.Attr
+	removed := false
+	for _, a := range el.StartElement.Attr {
+		if a.Name.Local == local && (space == "" || a.Name.Space == space) {
+			removed = true
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	if removed {
+		el.StartElement.Attr = attrs
+	}
+	return removed
+}
+
 // walkFunc is the type of the function called for each of an Element's
 // children.
 type walkFunc func(*Element)
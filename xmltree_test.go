@@ -3,6 +3,7 @@ package xmltree
 import (
 	"encoding/xml"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -340,3 +341,42 @@ func TestExistingNSAttrs(t *testing.T) {
 		found[attr.Name] = true
 	}
 }
+
+func TestNamespaceDecls(t *testing.T) {
+	root := parseDoc(t, []byte(`<a xmlns:z="urn:z" id="1" xmlns="urn:d" xmlns:b="urn:b"><b:c/><d xmlns:b="urn:b2"/></a>`))
+	want := []xml.Attr{
+		{Name: xml.Name{Space: "xmlns", Local: "z"}, Value: "urn:z"},
+		{Name: xml.Name{Local: "xmlns"}, Value: "urn:d"},
+		{Name: xml.Name{Space: "xmlns", Local: "b"}, Value: "urn:b"},
+	}
+	if got := root.NamespaceDecls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("root: got %v, want %v", got, want)
+	}
+	if got := root.Children[0].NamespaceDecls(); got != nil {
+		t.Errorf("c: got %v, want none", got)
+	}
+	want = []xml.Attr{{Name: xml.Name{Space: "xmlns", Local: "b"}, Value: "urn:b2"}}
+	if got := root.Children[1].NamespaceDecls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("d: got %v, want %v", got, want)
+	}
+	if got := NewDocument(root).Element().NamespaceDecls(); len(got) != 3 {
+		t.Errorf("Document round trip lost declarations: %v", got)
+	}
+}
+
+func TestAttrHelpers(t *testing.T) {
+	root := parseDoc(t, []byte(`<a xmlns:x="urn:x" id="1" x:id="2"/>`))
+	if v := root.Attr("urn:x", "id"); v != "2" {
+		t.Errorf("x:id = %q", v)
+	}
+	root.SetAttr("urn:x", "id", "3")
+	if root.RemoveAttr("urn:y", "id") {
+		t.Error("removed an attribute in another namespace")
+	}
+	if !root.RemoveAttr("", "id") {
+		t.Error("attributes not removed")
+	}
+	if len(root.StartElement.Attr) != 0 {
+		t.Errorf("attributes left: %v", root.StartElement.Attr)
+	}
+}
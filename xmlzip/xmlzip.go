@@ -0,0 +1,201 @@
+// Package xmlzip reads and writes zip containers of XML documents,
+// such as OpenDocument and EPUB files, built on the xmltree package.
+//
+// Members of an Archive are kept in their original order, with their
+// original compression method, so that an archive can be rewritten
+// without disturbing members that were not changed.
+package xmlzip // import "github.com/mdejong/xmltree/xmlzip"
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mdejong/xmltree"
+)
+
+// An Archive is an in-memory zip container.
+type Archive struct {
+	files []*File
+}
+
+// A File is a single member of an Archive.
+type File struct {
+	Name string
+	// The compression method used when the archive is written,
+	// zip.Store or zip.Deflate.
+	Method uint16
+
+	data []byte
+	tree *xmltree.Element
+}
+
+// Bytes returns the contents of the file. If the file has been
+// replaced by a tree with SetTree, or its tree has been obtained
+// with Tree, the encoding of the tree is returned, along with any
+// error encountered encoding it.
+func (f *File) Bytes() ([]byte, error) {
+	if f.tree != nil {
+		return xmltree.MarshalAppend([]byte(xml.Header), f.tree)
+	}
+	return f.data, nil
+}
+
+// Open reads a zip archive from r, which is size bytes long. The
+// contents of every member are read into memory.
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	a := new(Archive)
+	for _, zf := range zr.File {
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("xmlzip: %s: %v", zf.Name, err)
+		}
+		a.files = append(a.files, &File{Name: zf.Name, Method: zf.Method, data: data})
+	}
+	return a, nil
+}
+
+// OpenFile reads the zip archive in the named file.
+func OpenFile(name string) (*Archive, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Open(bytes.NewReader(data), int64(len(data)))
+}
+
+// Files returns the members of the archive, in order.
+func (a *Archive) Files() []*File {
+	return a.files
+}
+
+// File returns the named member, or nil if there is none.
+func (a *Archive) File(name string) *File {
+	for _, f := range a.files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Tree parses the named member as an XML document. The tree is
+// cached, so that later calls return the same Element, and changes
+// made to it are saved when the archive is written.
+//
+// So that a document survives being read and written back, the
+// member is parsed with WithMixedContent, WithComments,
+// WithProcInsts and WithCDATA, followed by any opts. The options are
+// used only by the call that parses the member.
+func (a *Archive) Tree(name string, opts ...xmltree.ParseOption) (*xmltree.Element, error) {
+	f := a.File(name)
+	if f == nil {
+		return nil, fmt.Errorf("xmlzip: %s: %w", name, os.ErrNotExist)
+	}
+	if f.tree == nil {
+		opts = append([]xmltree.ParseOption{
+			xmltree.WithMixedContent(),
+			xmltree.WithComments(),
+			xmltree.WithProcInsts(),
+			xmltree.WithCDATA(),
+		}, opts...)
+		el, err := xmltree.Parse(f.data, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("xmlzip: %s: %v", name, err)
+		}
+		f.tree = el
+	}
+	return f.tree, nil
+}
+
+// SetTree replaces the named member with the encoding of el, adding
+// it to the end of the archive if it does not exist.
+func (a *Archive) SetTree(name string, el *xmltree.Element) {
+	f := a.add(name)
+	f.tree, f.data = el, nil
+}
+
+// SetBytes replaces the named member with data, adding it to the end
+// of the archive if it does not exist.
+func (a *Archive) SetBytes(name string, data []byte) {
+	f := a.add(name)
+	f.tree, f.data = nil, data
+}
+
+func (a *Archive) add(name string) *File {
+	if f := a.File(name); f != nil {
+		return f
+	}
+	f := &File{Name: name, Method: zip.Deflate}
+	a.files = append(a.files, f)
+	return f
+}
+
+// Remove deletes the named member, reporting whether it existed.
+func (a *Archive) Remove(name string) bool {
+	for i, f := range a.files {
+		if f.Name == name {
+			a.files = append(a.files[:i], a.files[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// MoveFirst moves the named member to the start of the archive.
+// Some formats, such as OpenDocument and EPUB, require a particular
+// member to be first.
+func (a *Archive) MoveFirst(name string) {
+	for i, f := range a.files {
+		if f.Name == name {
+			copy(a.files[1:i+1], a.files[:i])
+			a.files[0] = f
+			return
+		}
+	}
+}
+
+// WriteTo writes the archive to w in zip format. If a tree cannot be
+// encoded, WriteTo stops and returns the error.
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	zw := zip.NewWriter(cw)
+	for _, f := range a.files {
+		data, err := f.Bytes()
+		if err != nil {
+			return cw.n, fmt.Errorf("xmlzip: %s: %v", f.Name, err)
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method})
+		if err != nil {
+			return cw.n, err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return cw.n, err
+		}
+	}
+	err := zw.Close()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,116 @@
+package xmlzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mdejong/xmltree"
+)
+
+func makeZip(t *testing.T, files ...string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[i+1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestArchive(t *testing.T) {
+	r := makeZip(t, "a.txt", "plain", "doc.xml", `<doc><v>1</v></doc>`)
+	a, err := Open(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := a.Tree("doc.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Children[0].Content = []byte("2")
+	a.SetBytes("first", []byte("x"))
+	a.MoveFirst("first")
+	if _, err := a.Tree("missing.xml"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing member gave %v", err)
+	}
+	if _, err := a.Tree("a.txt"); err == nil {
+		t.Error("parsed a non-XML member")
+	}
+
+	var buf bytes.Buffer
+	if _, err := a.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range b.Files() {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "first,a.txt,doc.xml" {
+		t.Errorf("member order %s", got)
+	}
+	if got, _ := b.File("doc.xml").Bytes(); !strings.Contains(string(got), "<v>2</v>") {
+		t.Errorf("modified tree not saved: %s", got)
+	}
+	if !b.Remove("a.txt") || b.Remove("a.txt") || b.File("a.txt") != nil {
+		t.Error("Remove failed")
+	}
+}
+
+func TestTreeRoundTrip(t *testing.T) {
+	const doc = `<text:p xmlns:text="urn:text">Hello <text:span>big</text:span> world<!--c--><?pi x?><text:s><![CDATA[<raw>]]></text:s></text:p>`
+	r := makeZip(t, "content.xml", doc)
+	a, err := Open(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Tree("content.xml"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.File("content.xml").Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := xml.Header + doc; string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+// failStore is a ContentStore whose content cannot be read back.
+type failStore struct{}
+
+func (failStore) Put([]byte) (string, error)         { return "key", nil }
+func (failStore) Open(string) (io.ReadCloser, error) { return nil, errors.New("disk error") }
+
+func TestWriteToEncodeError(t *testing.T) {
+	r := makeZip(t, "doc.xml", `<doc>`+strings.Repeat("x", 100)+`</doc>`)
+	a, err := Open(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Tree("doc.xml", xmltree.WithContentSpill(10, failStore{})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.File("doc.xml").Bytes(); err == nil {
+		t.Error("Bytes returned no error")
+	}
+	if _, err := a.WriteTo(io.Discard); err == nil || !strings.Contains(err.Error(), "disk error") {
+		t.Errorf("WriteTo returned %v, want disk error", err)
+	}
+}